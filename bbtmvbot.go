@@ -4,8 +4,11 @@ import (
 	"bbtmvbot/config"
 	"bbtmvbot/database"
 	"bbtmvbot/website"
+	"context"
 	"fmt"
 	"log"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-co-op/gocron"
@@ -13,8 +16,13 @@ import (
 )
 
 var (
-	db *database.Database
-	tb *telebot.Bot
+	db     *database.Database
+	tb     *telebot.Bot
+	sites  []*website.Site
+	cities []*website.City
+
+	// Location of scheduled jobs and digest times
+	location *time.Location
 )
 
 func Start(c *config.Config, dbPath *string) {
@@ -25,13 +33,27 @@ func Start(c *config.Config, dbPath *string) {
 		log.Fatalln(err)
 	}
 
+	// Setup websites
+	cities, err = website.ConfiguredCities(c)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	sites, err = website.New(c)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
 	// Connect to Telegram
 	poller := &telebot.LongPoller{Timeout: 10 * time.Second}
 	middlewarePoller := telebot.NewMiddlewarePoller(poller, func(upd *telebot.Update) bool {
-		if upd.Message == nil {
+		switch {
+		case upd.Message != nil:
+			db.EnsureUserInDB(upd.Message.Chat.ID) // This ensures that user is always in DB
+		case upd.Callback != nil && upd.Callback.Message != nil:
+			db.EnsureUserInDB(upd.Callback.Message.Chat.ID)
+		default:
 			return false
 		}
-		db.EnsureUserInDB(upd.Message.Chat.ID) // This ensures that user is always in DB
 		return true
 	})
 	tb, err = telebot.NewBot(telebot.Settings{Token: c.Telegram.ApiKey, Poller: middlewarePoller})
@@ -39,49 +61,175 @@ func Start(c *config.Config, dbPath *string) {
 		log.Fatalln(err)
 	}
 	initTelegramHandlers()
+	initNotifiers(c)
 
 	// Start telegram bot
 	go tb.Start()
+	go runOutbox()
 
 	// Setup cronjob
-	location, _ := time.LoadLocation("Europe/Vilnius")
+	location, _ = time.LoadLocation("Europe/Vilnius")
 	s := gocron.NewScheduler(location)
 	s.Every("3m").Do(refreshWebsites) // Retrieve new posts, send to users
+	s.Every("1m").Do(sendDigests)     // Send hourly and daily digests
 	//s.Every("24h").Do(cleanup)        // Cleanup (remove posts that are not seen in the last 30 days)
 
 	// Start cronjob and block execution
 	s.StartBlocking()
 }
 
+// Retrieving a single website must finish before the next refresh starts
+const retrieveTimeout = 150 * time.Second
+
 func refreshWebsites() {
-	for title, site := range website.Websites {
+	// Subscriptions changed during the refresh are used in the next one
+	subs := db.LoadSubscriptions()
+	for _, site := range sites {
 
-		go func(title string, site website.Website) {
-			posts := site.Retrieve(db)
+		go func(site *website.Site) {
+			ctx, cancel := context.WithTimeout(context.Background(), retrieveTimeout)
+			defer cancel()
+
+			title := site.Name + "/" + site.City.ID
+			posts, err := site.Retrieve(ctx, db, wantedStub(subs, site.City))
+			if err != nil {
+				kind := website.ErrorKind(err)
+				count := scrapeErrors.add(title, kind)
+				log.Printf("failed to retrieve posts from '%s' (%s error #%d): %s", title, kind, count, err)
+			}
 			for _, post := range posts {
-				go processPost(post)
+				go processPost(subs, post)
 			}
-		}(title, site)
+		}(site)
+	}
+}
+
+// wantedStub returns function reporting whether it is worth retrieving
+// details of the stub, so posts that nobody is interested in do not cost
+// a request to the portal.
+func wantedStub(subs *database.Subscriptions, city *website.City) func(*website.Stub) bool {
+	return func(stub *website.Stub) bool {
+		return subs.AnyInterested(stub.Price, stub.Rooms, city.ID)
+	}
+}
+
+// errorCounter counts scraping errors per website and error kind.
+type errorCounter struct {
+	mu     sync.Mutex
+	counts map[string]map[string]int
+}
+
+var scrapeErrors = &errorCounter{counts: map[string]map[string]int{}}
+
+// add increments counter of given website and error kind and returns
+// the new value.
+func (c *errorCounter) add(site, kind string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.counts[site] == nil {
+		c.counts[site] = map[string]int{}
 	}
+	c.counts[site][kind]++
+	return c.counts[site][kind]
 }
 
-func processPost(post *website.Post) {
+func processPost(subs *database.Subscriptions, post *website.Post) {
+	// Post is retrieved again only if its price changed
+	if known := db.GetPostByLink(post.Link); known != nil {
+		processPriceChange(subs, known, post)
+		return
+	}
+
 	if post.IsExcludable() {
-		db.AddPost(post.Link)
+		db.AddPost(post)
 		return
 	}
 
-	insertedPostID := db.AddPost(post.Link)
+	insertedPostID, duplicated := db.InsertPost(post, time.Now().Add(-duplicateWindow))
+	if duplicated != nil {
+		processDuplicate(duplicated, insertedPostID, post)
+		return
+	}
+
+	original := db.FindRelisted(post)
 
-	telegramIDs := db.GetInterestedTelegramIDs(post.Price, post.Rooms, post.Year, post.Floor, post.IsWithFee())
-	for _, telegramID := range telegramIDs {
-		sendTelegram(telegramID, post.FormatTelegramMessage(insertedPostID))
+	msg := post.FormatTelegramMessage(insertedPostID)
+	if original != nil {
+		msg = post.FormatRelistedMessage(insertedPostID, original.ID, original.Price)
 	}
+	notifyInterested(subs, post, insertedPostID, msg)
 
 	log.Println(fmt.Sprintf(
-		"\tID:%d Tel:%s Desc:%d Addr:%d Heat:%d Fl:%d FlTot:%d Area:%d Price:%d Room:%d Year:%d WithFees:%t Link:%s",
-		insertedPostID, post.Phone, len(post.Description), len(post.Address), len(post.Heating), post.Floor, post.FloorTotal, post.Area, post.Price, post.Rooms, post.Year, post.IsWithFee(), post.Link,
+		"\tID:%d City:%s Tel:%s Desc:%d Addr:%d Heat:%d Fl:%d FlTot:%d Area:%d Price:%d Room:%d Year:%d WithFees:%t Link:%s",
+		insertedPostID, post.City, post.Phone, len(post.Description), len(post.Address), len(post.Heating), post.Floor, post.FloorTotal, post.Area, post.Price, post.Rooms, post.Year, post.IsWithFee(), post.Link,
 	))
+	if original != nil {
+		log.Printf("\tID:%d is relisted post ID:%d", insertedPostID, original.ID)
+	}
+}
+
+// processPriceChange updates stored post and notifies interested users if
+// price dropped. Price increases are only recorded. Users were notified only
+// about the original of merged duplicates, so its ID is used in the message.
+func processPriceChange(subs *database.Subscriptions, known *database.Post, post *website.Post) {
+	db.UpdatePost(known.ID, post)
+	if post.IsExcludable() || post.Price == known.Price {
+		return
+	}
+
+	log.Printf("\tID:%d price changed from %d to %d", known.ID, known.Price, post.Price)
+	if post.Price < known.Price {
+		original := db.GetOriginal(known)
+		notifyInterested(subs, post, original.ID, post.FormatPriceDropMessage(original.ID, known.Price))
+	}
+}
+
+// Posts of the same flat in different websites are merged if they were
+// posted within this period
+const duplicateWindow = 72 * time.Hour
+
+// processDuplicate adds link to the post, already stored as a duplicate of
+// notified one, to the sent notifications instead of notifying again. Edits
+// are sent through the outbox too.
+func processDuplicate(original *database.Post, insertedPostID int64, post *website.Post) {
+	log.Printf("\tID:%d is duplicate of ID:%d Link:%s", insertedPostID, original.ID, post.Link)
+
+	duplicates := db.GetDuplicates(original.ID)
+	alsoOn := make([]*website.Post, 0, len(duplicates))
+	for _, d := range duplicates {
+		alsoOn = append(alsoOn, &d.Post)
+	}
+	// Linked channels cannot edit sent messages, so they get a short message
+	followUp := fmt.Sprintf("%d. %s\n", original.ID, original.Link) + website.FormatAlsoOn([]*website.Post{post})
+	for _, n := range db.GetNotifications(original.ID) {
+		// Sent text keeps headers, like relisted post or matched searches
+		text := n.Text
+		if text == "" {
+			text = original.FormatTelegramMessage(original.ID)
+		}
+		db.EnqueueEdit(n.TelegramID, n.MessageID, text+website.FormatAlsoOn(alsoOn), followUp, original.ID)
+	}
+}
+
+// notifyInterested queues message about the post to all interested users.
+// Post is added to the digest of users receiving digests or having quiet
+// hours instead.
+func notifyInterested(subs *database.Subscriptions, post *website.Post, postID int64, msg string) {
+	now := time.Now().In(location)
+	for _, match := range subs.Interested(post) {
+		delivery := db.GetDelivery(match.TelegramID)
+		if delivery.Holds(now) {
+			db.QueuePost(match.TelegramID, postID, match.Searches)
+			continue
+		}
+
+		text := msg
+		if len(match.Searches) > 0 {
+			text = "🔎 *Search:* " + strings.Join(match.Searches, ", ") + "\n" + msg
+		}
+		queueTelegram(match.TelegramID, text, delivery.Silent(now), postID)
+	}
 }
 
 func cleanup() {
@@ -0,0 +1,142 @@
+package bbtmvbot
+
+import (
+	"bbtmvbot/database"
+	"log"
+	"strconv"
+	"strings"
+
+	telebot "gopkg.in/tucnak/telebot.v2"
+)
+
+// Unique names of notification buttons. Button data is ID of the post.
+const (
+	saveUnique  = "save"
+	hideUnique  = "hide"
+	phoneUnique = "phone"
+	muteUnique  = "mute"
+)
+
+func initButtonHandlers() {
+	tb.Handle(&telebot.InlineButton{Unique: saveUnique}, handleSaveButton)
+	tb.Handle(&telebot.InlineButton{Unique: hideUnique}, handleHideButton)
+	tb.Handle(&telebot.InlineButton{Unique: phoneUnique}, handlePhoneButton)
+	tb.Handle(&telebot.InlineButton{Unique: muteUnique}, handleMuteButton)
+}
+
+// postMarkup returns inline keyboard of a notification about the post. New
+// markup must be created for every message.
+func postMarkup(postID int64) *telebot.ReplyMarkup {
+	id := strconv.FormatInt(postID, 10)
+	return &telebot.ReplyMarkup{InlineKeyboard: [][]telebot.InlineButton{
+		{
+			{Unique: saveUnique, Text: "⭐ Save", Data: id},
+			{Unique: hideUnique, Text: "🙈 Hide similar", Data: id},
+		},
+		{
+			{Unique: phoneUnique, Text: "📞 Show phone", Data: id},
+			{Unique: muteUnique, Text: "🚫 Mute this portal", Data: id},
+		},
+	}}
+}
+
+// respond answers button press with a short notification.
+func respond(c *telebot.Callback, text string) {
+	if err := tb.Respond(c, &telebot.CallbackResponse{Text: text}); err != nil {
+		log.Printf("failed to answer callback in chat %d: %s", c.Message.Chat.ID, err)
+	}
+}
+
+// callbackPost returns post, which ID is in button data, or nil if it does
+// not exist anymore.
+func callbackPost(c *telebot.Callback) *database.Post {
+	id, err := strconv.ParseInt(c.Data, 10, 64)
+	if err != nil {
+		return nil
+	}
+	return db.GetPost(id)
+}
+
+const postNotFoundText = "This post is too old and was already removed."
+
+func handleSaveButton(c *telebot.Callback) {
+	post := callbackPost(c)
+	if post == nil {
+		respond(c, postNotFoundText)
+		return
+	}
+	if !db.SavePost(c.Message.Chat.ID, post.ID) {
+		respond(c, "This post is already saved.")
+		return
+	}
+	respond(c, "⭐ Saved!")
+}
+
+func handleHideButton(c *telebot.Callback) {
+	post := callbackPost(c)
+	if post == nil {
+		respond(c, postNotFoundText)
+		return
+	}
+	if !db.HideSimilar(c.Message.Chat.ID, &post.Post) {
+		respond(c, "This post has no phone number or exact address to hide similar posts by.")
+		return
+	}
+	respond(c, "🙈 Posts with the same phone number or exact address will be hidden. Use /unhide to undo.")
+}
+
+func handlePhoneButton(c *telebot.Callback) {
+	post := callbackPost(c)
+	if post == nil {
+		respond(c, postNotFoundText)
+		return
+	}
+	if post.Phone == "" {
+		respond(c, "Phone number of this post is unknown.")
+		return
+	}
+	// Sent as plain text, so Telegram makes it callable
+	respond(c, "")
+	sendTelegram(c.Message.Chat.ID, "📞 "+post.Phone)
+}
+
+func handleMuteButton(c *telebot.Callback) {
+	post := callbackPost(c)
+	if post == nil {
+		respond(c, postNotFoundText)
+		return
+	}
+	if post.Source == "" {
+		respond(c, "Portal of this post is unknown.")
+		return
+	}
+	db.MuteSource(c.Message.Chat.ID, post.Source)
+	respond(c, "🚫 Posts from "+post.Source+" are muted. Use /unmute "+post.Source+" to undo.")
+}
+
+const unmuteText = "Use this format:\n\n```\n/unmute <portal>\n```"
+
+func handleCommandUnmute(m *telebot.Message) {
+	fields := strings.Fields(strings.ToLower(m.Text))
+	if len(fields) != 2 {
+		muted := "none"
+		if sources := db.MutedSources(m.Chat.ID); len(sources) > 0 {
+			muted = strings.Join(sources, ", ")
+		}
+		sendTelegram(m.Chat.ID, unmuteText+"\n\nMuted portals: "+muted)
+		return
+	}
+	if !db.UnmuteSource(m.Chat.ID, fields[1]) {
+		sendTelegram(m.Chat.ID, "Portal '"+fields[1]+"' is not muted!")
+		return
+	}
+	sendTelegram(m.Chat.ID, "Portal '"+fields[1]+"' unmuted!")
+}
+
+func handleCommandUnhide(m *telebot.Message) {
+	if db.Unhide(m.Chat.ID) == 0 {
+		sendTelegram(m.Chat.ID, "There are no hidden posts!")
+		return
+	}
+	sendTelegram(m.Chat.ID, "Similar posts are not hidden anymore!")
+}
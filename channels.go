@@ -0,0 +1,121 @@
+package bbtmvbot
+
+import (
+	"bbtmvbot/database"
+	"bbtmvbot/notifier"
+	"crypto/rand"
+	"fmt"
+	"log"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	telebot "gopkg.in/tucnak/telebot.v2"
+)
+
+const linkText = "Use this format to receive notifications via another channel too:\n\n```\n/link <channel> <target>\n/confirm <code>\n/unlink <channel>\n```\n\nConfirmation code is sent to the target, so only its owner can link it.\n\nExamples:\n```\n/link email name@example.com\n/link webhook https://example.com/hook\n/link discord https://discord.com/api/webhooks/...\n/link matrix !room:matrix.org\n```"
+
+// linkableChannels returns names of configured channels, which can be linked
+// to a chat.
+func linkableChannels() []string {
+	names := make([]string, 0, len(notifiers))
+	for name := range notifiers {
+		if name != database.ChannelTelegram {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// formatChannels formats list of channels linked to the chat.
+func formatChannels(telegramID int64) string {
+	channels := db.GetChannels(telegramID)
+	if len(channels) == 0 {
+		return "none"
+	}
+	lines := make([]string, 0, len(channels))
+	for _, c := range channels {
+		lines = append(lines, c.Name+": `"+c.Target+"`")
+	}
+	return "\n" + strings.Join(lines, "\n")
+}
+
+func handleCommandLink(m *telebot.Message) {
+	available := linkableChannels()
+	if len(available) == 0 {
+		sendTelegram(m.Chat.ID, "There are no other channels available!")
+		return
+	}
+
+	fields := strings.Fields(m.Text)
+	if len(fields) != 3 {
+		sendTelegram(m.Chat.ID, linkText+"\n\nAvailable channels: "+strings.Join(available, ", ")+"\nLinked channels: "+formatChannels(m.Chat.ID))
+		return
+	}
+	channel, target := strings.ToLower(fields[1]), fields[2]
+	n, found := notifiers[channel]
+	if !found || channel == database.ChannelTelegram {
+		sendTelegram(m.Chat.ID, "Wrong input! Available channels: "+strings.Join(available, ", "))
+		return
+	}
+	if err := n.ValidateTarget(target); err != nil {
+		sendTelegram(m.Chat.ID, "Wrong input! Target of "+channel+" is not valid: "+err.Error()+"\n\n"+linkText)
+		return
+	}
+	if wait := db.LastPendingChannel(m.Chat.ID).Add(linkCodeInterval).Sub(time.Now()); wait > 0 {
+		sendTelegram(m.Chat.ID, fmt.Sprintf("Confirmation code was sent recently, try again in %d seconds!", int(wait.Seconds())+1))
+		return
+	}
+
+	code := confirmationCode()
+	db.AddPendingChannel(m.Chat.ID, channel, target, code)
+	msg := &notifier.Message{Text: "Confirmation code of BBTMV bot: *" + code + "*\n\nSend `/confirm " + code + "` to the bot to receive notifications here. Ignore this message if you did not ask for it."}
+	if _, err := n.Send(target, msg); err != nil {
+		log.Printf("failed to send confirmation code to %s %s: %s", channel, target, err)
+		sendTelegram(m.Chat.ID, "Failed to send confirmation code to "+channel+" `"+target+"`!")
+		return
+	}
+	sendTelegram(m.Chat.ID, "Confirmation code was sent to "+channel+" `"+target+"`. Use /confirm <code> to link it.")
+}
+
+// Confirmation codes are sent to targets not more often than this per chat,
+// so bot cannot be used to spam them
+const linkCodeInterval = time.Minute
+
+// confirmationCode returns random 6 digit code.
+func confirmationCode() string {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		panic(err)
+	}
+	return fmt.Sprintf("%06d", n.Int64())
+}
+
+func handleCommandConfirm(m *telebot.Message) {
+	fields := strings.Fields(m.Text)
+	if len(fields) != 2 {
+		sendTelegram(m.Chat.ID, "Wrong input! "+linkText)
+		return
+	}
+	c := db.ConfirmChannel(m.Chat.ID, fields[1])
+	if c == nil {
+		sendTelegram(m.Chat.ID, "Confirmation code is wrong or expired! Use /link to get a new one.")
+		return
+	}
+	sendTelegram(m.Chat.ID, "Notifications will be sent to "+c.Name+" `"+c.Target+"` too! Use /unlink "+c.Name+" to stop.")
+}
+
+func handleCommandUnlink(m *telebot.Message) {
+	fields := strings.Fields(strings.ToLower(m.Text))
+	if len(fields) != 2 {
+		sendTelegram(m.Chat.ID, linkText+"\n\nLinked channels: "+formatChannels(m.Chat.ID))
+		return
+	}
+	if !db.UnlinkChannel(m.Chat.ID, fields[1]) {
+		sendTelegram(m.Chat.ID, "Channel '"+fields[1]+"' is not linked!")
+		return
+	}
+	sendTelegram(m.Chat.ID, "Channel '"+fields[1]+"' unlinked!")
+}
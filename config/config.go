@@ -2,6 +2,7 @@ package config
 
 import (
 	"io/ioutil"
+	"time"
 
 	"gopkg.in/yaml.v2"
 )
@@ -10,6 +11,72 @@ type Config struct {
 	Telegram struct {
 		ApiKey string `yaml:"api_key"`
 	} `yaml:"telegram"`
+
+	// Cities are IDs of the cities to search posts in, e.g. "kaunas"
+	Cities []string `yaml:"cities"`
+	// City is ID of a single city to search posts in. Deprecated, use Cities
+	City string `yaml:"city"`
+
+	// HTTP contains defaults for all websites
+	HTTP HTTP `yaml:"http"`
+
+	// Websites contains per-website settings, keyed by website name
+	Websites map[string]Website `yaml:"websites"`
+
+	// Notifiers configures channels, which users can link to their chats in
+	// addition to Telegram
+	Notifiers Notifiers `yaml:"notifiers"`
+}
+
+// Notifiers contains settings of notification channels. Channels that are
+// not configured are disabled.
+type Notifiers struct {
+	Email   *Email   `yaml:"email"`
+	Webhook *Webhook `yaml:"webhook"`
+	Discord *Webhook `yaml:"discord"`
+	Matrix  *Matrix  `yaml:"matrix"`
+}
+
+// Email configures SMTP server used to send emails.
+type Email struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	// From is sender address
+	From string `yaml:"from"`
+}
+
+// Webhook configures requests to webhooks provided by users.
+type Webhook struct {
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// Matrix configures bot account used to send messages to Matrix rooms.
+type Matrix struct {
+	// Homeserver is base URL of the homeserver, e.g. "https://matrix.org"
+	Homeserver  string        `yaml:"homeserver"`
+	AccessToken string        `yaml:"access_token"`
+	Timeout     time.Duration `yaml:"timeout"`
+}
+
+// HTTP configures how websites are accessed. Zero values mean defaults.
+type HTTP struct {
+	Timeout   time.Duration `yaml:"timeout"`
+	UserAgent string        `yaml:"user_agent"`
+	Proxy     string        `yaml:"proxy"`
+}
+
+type Website struct {
+	// BaseURL is used for links to posts, e.g. "https://www.alio.lt"
+	BaseURL string `yaml:"base_url"`
+	// ListingURL is used for search page, defaults to BaseURL
+	ListingURL string `yaml:"listing_url"`
+	// SearchPaths overrides search page path (relative to ListingURL) per city
+	SearchPaths map[string]string `yaml:"search_paths"`
+
+	// HTTP overrides global HTTP settings for this website
+	HTTP HTTP `yaml:",inline"`
 }
 
 func New(path string) (*Config, error) {
@@ -22,3 +89,29 @@ func New(path string) (*Config, error) {
 	err = yaml.Unmarshal(contents, &c)
 	return &c, err
 }
+
+// CityIDs returns IDs of the configured cities, including the deprecated
+// single City setting.
+func (c *Config) CityIDs() []string {
+	if len(c.Cities) == 0 && c.City != "" {
+		return []string{c.City}
+	}
+	return c.Cities
+}
+
+// WebsiteHTTP returns HTTP settings of the given website, where missing
+// values are taken from global HTTP settings.
+func (c *Config) WebsiteHTTP(name string) HTTP {
+	h := c.HTTP
+	w := c.Websites[name].HTTP
+	if w.Timeout != 0 {
+		h.Timeout = w.Timeout
+	}
+	if w.UserAgent != "" {
+		h.UserAgent = w.UserAgent
+	}
+	if w.Proxy != "" {
+		h.Proxy = w.Proxy
+	}
+	return h
+}
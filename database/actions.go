@@ -0,0 +1,126 @@
+package database
+
+import (
+	"bbtmvbot/website"
+	"strings"
+	"time"
+)
+
+// SavePost adds post to user's favourites and reports whether it was not
+// saved before.
+func (d *Database) SavePost(telegramID, postID int64) bool {
+	query := "INSERT OR IGNORE INTO favourites(telegram_id, post_id, saved_at) VALUES(?, ?, ?)"
+	res, err := d.db.Exec(query, telegramID, postID, time.Now().Unix())
+	if err != nil {
+		panic(err)
+	}
+	return affected(res) > 0
+}
+
+// UnsavePost removes post from user's favourites and reports whether it was
+// saved.
+func (d *Database) UnsavePost(telegramID, postID int64) bool {
+	res, err := d.db.Exec("DELETE FROM favourites WHERE telegram_id=? AND post_id=?", telegramID, postID)
+	if err != nil {
+		panic(err)
+	}
+	return affected(res) > 0
+}
+
+// GetSaved returns user's saved posts starting from offset, most recently
+// saved first, and total count of saved posts.
+func (d *Database) GetSaved(telegramID int64, offset, limit int) ([]*Post, int) {
+	var total int
+	err := d.db.QueryRow("SELECT COUNT(*) FROM favourites f JOIN posts p ON p.id=f.post_id WHERE f.telegram_id=?", telegramID).Scan(&total)
+	if err != nil {
+		panic(err)
+	}
+	query := "SELECT p." + strings.ReplaceAll(postColumns, ", ", ", p.") + " FROM favourites f JOIN posts p ON p.id=f.post_id WHERE f.telegram_id=? ORDER BY f.saved_at DESC, f.post_id DESC LIMIT ? OFFSET ?"
+	return d.queryPosts(query, telegramID, limit, offset), total
+}
+
+// HideSimilar hides future posts with the same phone number or address as
+// the given post. Address is used only if it includes house number, as
+// street alone matches too many flats. It reports whether there was
+// anything to hide by.
+func (d *Database) HideSimilar(telegramID int64, p *website.Post) bool {
+	if p.Phone == "" && !p.HasHouseNumber() {
+		return false
+	}
+	query := "INSERT OR IGNORE INTO hidden(telegram_id, phone, address) VALUES(?, ?, ?)"
+	_, err := d.db.Exec(query, telegramID, p.Phone, p.Address)
+	if err != nil {
+		panic(err)
+	}
+	return true
+}
+
+// Unhide shows again all posts hidden by the user and returns how many hide
+// rules were removed.
+func (d *Database) Unhide(telegramID int64) int64 {
+	res, err := d.db.Exec("DELETE FROM hidden WHERE telegram_id=?", telegramID)
+	if err != nil {
+		panic(err)
+	}
+	return affected(res)
+}
+
+// hiddenBy returns hide rules of all users.
+func (d *Database) hiddenBy() map[int64][]*website.Post {
+	hidden := map[int64][]*website.Post{}
+	rows, err := d.db.Query("SELECT telegram_id, phone, address FROM hidden")
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		p := &website.Post{}
+		if err = rows.Scan(&id, &p.Phone, &p.Address); err != nil {
+			panic(err)
+		}
+		hidden[id] = append(hidden[id], p)
+	}
+	if err = rows.Err(); err != nil {
+		panic(err)
+	}
+	return hidden
+}
+
+// isSimilar reports whether post has the same phone number or address with
+// house number as one of the hidden ones.
+func isSimilar(p *website.Post, hidden []*website.Post) bool {
+	phone := p.NormalizedPhone()
+	for _, h := range hidden {
+		if h.Phone != "" && h.NormalizedPhone() == phone {
+			return true
+		}
+		if h.HasHouseNumber() && website.Normalize(h.Address) == website.Normalize(p.Address) {
+			return true
+		}
+	}
+	return false
+}
+
+// MuteSource stops notifications about posts from the given website.
+func (d *Database) MuteSource(telegramID int64, source string) {
+	_, err := d.db.Exec("INSERT OR IGNORE INTO muted_sources(telegram_id, source) VALUES(?, ?)", telegramID, source)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// UnmuteSource resumes notifications about posts from the given website and
+// reports whether it was muted.
+func (d *Database) UnmuteSource(telegramID int64, source string) bool {
+	res, err := d.db.Exec("DELETE FROM muted_sources WHERE telegram_id=? AND source=?", telegramID, source)
+	if err != nil {
+		panic(err)
+	}
+	return affected(res) > 0
+}
+
+// MutedSources returns websites muted by the user.
+func (d *Database) MutedSources(telegramID int64) []string {
+	return d.queryStrings("SELECT source FROM muted_sources WHERE telegram_id=? ORDER BY source", telegramID)
+}
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"bbtmvbot/website"
+	"reflect"
+	"testing"
+)
+
+func TestActions(t *testing.T) {
+	d := openTest(t)
+	for id := int64(1); id <= 2; id++ {
+		d.EnsureUserInDB(id)
+		d.UpdateUser(&User{TelegramID: id, Filter: Filter{PriceTo: 1000, RoomsTo: 5}})
+	}
+
+	if !d.SavePost(1, 10) || d.SavePost(1, 10) {
+		t.Errorf("Expected post to be saved only once.")
+	}
+	if !d.UnsavePost(1, 10) || d.UnsavePost(1, 10) {
+		t.Errorf("Expected post to be unsaved only once.")
+	}
+
+	d.MuteSource(1, "skelbiu")
+	d.MuteSource(1, "skelbiu")
+	if got := d.MutedSources(1); !reflect.DeepEqual(got, []string{"skelbiu"}) {
+		t.Errorf("Result is incorrect, got: '%v', want: '[skelbiu]'.", got)
+	}
+	if d.HideSimilar(2, &website.Post{}) || d.HideSimilar(2, &website.Post{Address: "Vilnius, Žirmūnai, Kalvarijų g."}) {
+		t.Errorf("Expected post without phone and house number not to be hidden.")
+	}
+	d.HideSimilar(2, &website.Post{Phone: "+37060000000", Address: "Vilnius, Žirmūnai, Kalvarijų g."})
+	d.HideSimilar(2, &website.Post{Address: "Vilnius, Antakalnis, Saulėtekio al. 15"})
+
+	var data = []struct {
+		Post     website.Post
+		Expected []int64
+	}{
+		{website.Post{Source: "aruodas", Price: 300, Rooms: 1}, []int64{1, 2}},
+		{website.Post{Source: "skelbiu", Price: 300, Rooms: 1}, []int64{2}},
+		{website.Post{Source: "aruodas", Price: 300, Rooms: 1, Phone: "+37060000000"}, []int64{1}},
+		{website.Post{Source: "aruodas", Price: 300, Rooms: 1, Phone: "860000000"}, []int64{1}},
+		// Street alone does not hide other flats on it
+		{website.Post{Source: "aruodas", Price: 300, Rooms: 1, Address: "Vilnius, Žirmūnai, Kalvarijų g."}, []int64{1, 2}},
+		{website.Post{Source: "aruodas", Price: 300, Rooms: 1, Address: "Vilnius, Antakalnis, Saulėtekio al. 15"}, []int64{1}},
+		{website.Post{Source: "aruodas", Price: 300, Rooms: 1, Address: "Vilnius, Antakalnis, Saulėtekio al. 17"}, []int64{1, 2}},
+		{website.Post{Source: "aruodas", Price: 300, Rooms: 1, Phone: "+37061111111", Address: "Vilnius, Antakalnis"}, []int64{1, 2}},
+	}
+	for _, v := range data {
+		if got := interestedIDs(d, &v.Post); !reflect.DeepEqual(got, v.Expected) {
+			t.Errorf("Result is incorrect for '%+v', got: '%v', want: '%v'.", v.Post, got, v.Expected)
+		}
+	}
+
+	if !d.UnmuteSource(1, "skelbiu") || d.UnmuteSource(1, "skelbiu") {
+		t.Errorf("Expected source to be unmuted only once.")
+	}
+	if got := d.Unhide(2); got != 2 {
+		t.Errorf("Result is incorrect, got: '%d', want: '2'.", got)
+	}
+	if got := interestedIDs(d, &website.Post{Source: "skelbiu", Price: 300, Rooms: 1, Phone: "+37060000000"}); !reflect.DeepEqual(got, []int64{1, 2}) {
+		t.Errorf("Result is incorrect, got: '%v', want: '[1 2]'.", got)
+	}
+}
+
+func TestGetSaved(t *testing.T) {
+	d := openTest(t)
+	ids := make([]int64, 0)
+	for _, link := range []string{"https://a.lt/1", "https://a.lt/2", "https://a.lt/3"} {
+		id := d.AddPost(&website.Post{Link: link})
+		d.SavePost(1, id)
+		ids = append(ids, id)
+	}
+	d.SavePost(2, ids[0])
+	// Favourites of removed posts are not listed
+	d.SavePost(1, 100)
+
+	var data = []struct {
+		Offset   int
+		Limit    int
+		Expected []int64
+	}{
+		{0, 2, []int64{ids[2], ids[1]}},
+		{2, 2, []int64{ids[0]}},
+		{4, 2, []int64{}},
+	}
+	for _, v := range data {
+		posts, total := d.GetSaved(1, v.Offset, v.Limit)
+		got := make([]int64, 0, len(posts))
+		for _, p := range posts {
+			got = append(got, p.ID)
+		}
+		if !reflect.DeepEqual(got, v.Expected) || total != 3 {
+			t.Errorf("Result is incorrect for offset %d, got: '%v' of %d, want: '%v' of 3.", v.Offset, got, total, v.Expected)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// ChannelTelegram is the channel of the chat itself. Other channels are
+// linked to the chat by user.
+const ChannelTelegram = "telegram"
+
+// Channel is a notification channel linked to the chat, e.g. email address.
+type Channel struct {
+	Name   string
+	Target string
+}
+
+// LinkChannel links channel to the chat, replacing previous target of the
+// same channel. Links made by users must be confirmed, see AddPendingChannel.
+func (d *Database) LinkChannel(telegramID int64, channel, target string) {
+	query := "INSERT OR REPLACE INTO user_channels(telegram_id, channel, target) VALUES(?, ?, ?)"
+	if _, err := d.db.Exec(query, telegramID, channel, target); err != nil {
+		panic(err)
+	}
+}
+
+// UnlinkChannel removes channel from the chat and reports whether it was
+// linked.
+func (d *Database) UnlinkChannel(telegramID int64, channel string) bool {
+	res, err := d.db.Exec("DELETE FROM user_channels WHERE telegram_id=? AND channel=?", telegramID, channel)
+	if err != nil {
+		panic(err)
+	}
+	return affected(res) > 0
+}
+
+// GetChannels returns channels linked to the chat, ordered by name.
+func (d *Database) GetChannels(telegramID int64) []*Channel {
+	rows, err := d.db.Query("SELECT channel, target FROM user_channels WHERE telegram_id=? ORDER BY channel", telegramID)
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+
+	channels := make([]*Channel, 0)
+	for rows.Next() {
+		var c Channel
+		if err = rows.Scan(&c.Name, &c.Target); err != nil {
+			panic(err)
+		}
+		channels = append(channels, &c)
+	}
+	if err = rows.Err(); err != nil {
+		panic(err)
+	}
+	return channels
+}
+
+// DeactivateChannel unlinks channel, which target cannot receive messages
+// anymore, and marks its pending messages as inactive.
+func (d *Database) DeactivateChannel(telegramID int64, channel, target, reason string) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		panic(err)
+	}
+	defer tx.Rollback()
+
+	query := "DELETE FROM user_channels WHERE telegram_id=? AND channel=? AND target=?"
+	if _, err = tx.Exec(query, telegramID, channel, target); err != nil {
+		panic(err)
+	}
+	query = "UPDATE outbox SET status=?, error=? WHERE telegram_id=? AND channel=? AND target=? AND status=?"
+	if _, err = tx.Exec(query, OutboxInactive, reason, telegramID, channel, target, OutboxPending); err != nil {
+		panic(err)
+	}
+	if err = tx.Commit(); err != nil {
+		panic(err)
+	}
+}
+
+// Limits of link confirmation
+const (
+	// Confirmation code expires after this period
+	ConfirmationTTL = time.Hour
+	// Pending link is removed after this many wrong codes
+	MaxConfirmationAttempts = 5
+)
+
+// AddPendingChannel stores link, which becomes active once confirmed with the
+// code sent to the target. It replaces pending link of the same channel.
+func (d *Database) AddPendingChannel(telegramID int64, channel, target, code string) {
+	query := "INSERT OR REPLACE INTO pending_channels(telegram_id, channel, target, code, created_at) VALUES(?, ?, ?, ?, ?)"
+	if _, err := d.db.Exec(query, telegramID, channel, target, code, time.Now().Unix()); err != nil {
+		panic(err)
+	}
+}
+
+// LastPendingChannel returns time of the latest link waiting for
+// confirmation, or zero time if there is none.
+func (d *Database) LastPendingChannel(telegramID int64) time.Time {
+	var createdAt int64
+	query := "SELECT COALESCE(MAX(created_at), 0) FROM pending_channels WHERE telegram_id=?"
+	if err := d.db.QueryRow(query, telegramID).Scan(&createdAt); err != nil {
+		panic(err)
+	}
+	if createdAt == 0 {
+		return time.Time{}
+	}
+	return time.Unix(createdAt, 0)
+}
+
+// ConfirmChannel activates pending link with the given code and returns it.
+// Returns nil if code is wrong or expired. Pending links are removed after
+// too many wrong codes, so codes cannot be guessed.
+func (d *Database) ConfirmChannel(telegramID int64, code string) *Channel {
+	tx, err := d.db.Begin()
+	if err != nil {
+		panic(err)
+	}
+	defer tx.Rollback()
+
+	expired := time.Now().Add(-ConfirmationTTL).Unix()
+	if _, err = tx.Exec("DELETE FROM pending_channels WHERE created_at<? OR attempts>=?", expired, MaxConfirmationAttempts); err != nil {
+		panic(err)
+	}
+
+	var c Channel
+	query := "SELECT channel, target FROM pending_channels WHERE telegram_id=? AND code=?"
+	err = tx.QueryRow(query, telegramID, code).Scan(&c.Name, &c.Target)
+	if err == sql.ErrNoRows {
+		if _, err = tx.Exec("UPDATE pending_channels SET attempts=attempts+1 WHERE telegram_id=?", telegramID); err != nil {
+			panic(err)
+		}
+		if err = tx.Commit(); err != nil {
+			panic(err)
+		}
+		return nil
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	query = "INSERT OR REPLACE INTO user_channels(telegram_id, channel, target) VALUES(?, ?, ?)"
+	if _, err = tx.Exec(query, telegramID, c.Name, c.Target); err != nil {
+		panic(err)
+	}
+	if _, err = tx.Exec("DELETE FROM pending_channels WHERE telegram_id=? AND channel=?", telegramID, c.Name); err != nil {
+		panic(err)
+	}
+	if err = tx.Commit(); err != nil {
+		panic(err)
+	}
+	return &c
+}
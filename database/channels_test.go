@@ -0,0 +1,108 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChannels(t *testing.T) {
+	d := openTest(t)
+	d.EnsureUserInDB(1)
+
+	d.LinkChannel(1, "webhook", "https://example.com/old")
+	d.LinkChannel(1, "webhook", "https://example.com/hook")
+	d.LinkChannel(1, "email", "user@example.com")
+	channels := d.GetChannels(1)
+	if len(channels) != 2 || *channels[0] != (Channel{"email", "user@example.com"}) || *channels[1] != (Channel{"webhook", "https://example.com/hook"}) {
+		t.Errorf("Result is incorrect, got: '%+v', want: email and webhook.", channels)
+	}
+
+	// Message is queued to the chat and all linked channels
+	id := d.Enqueue(1, "text", true, 5)
+	var got []*OutboxMessage
+	for m := d.NextOutbox(time.Now()); m != nil; m = d.NextOutbox(time.Now()) {
+		got = append(got, m)
+		d.MarkSent(m.ID, 0)
+	}
+	var data = []struct {
+		Channel string
+		Target  string
+	}{
+		{ChannelTelegram, "1"},
+		{"email", "user@example.com"},
+		{"webhook", "https://example.com/hook"},
+	}
+	if len(got) != len(data) || got[0].ID != id {
+		t.Fatalf("Result is incorrect, got: '%d' messages, want: '%d'.", len(got), len(data))
+	}
+	for i, v := range data {
+		if got[i].Channel != v.Channel || got[i].Target != v.Target || got[i].Text != "text" || !got[i].Silent || got[i].PostID != 5 {
+			t.Errorf("Result is incorrect, got: '%+v', want: '%s %s'.", got[i], v.Channel, v.Target)
+		}
+	}
+
+	// Inactive channel is unlinked, while chat stays enabled
+	d.SetEnabled(1, true)
+	d.Enqueue(1, "second", false, 0)
+	d.DeactivateChannel(1, "webhook", "https://example.com/hook", "gone")
+	if !d.Enabled(1) || len(d.GetChannels(1)) != 1 {
+		t.Errorf("Expected only webhook to be unlinked.")
+	}
+	for m := d.NextOutbox(time.Now()); m != nil; m = d.NextOutbox(time.Now()) {
+		if m.Channel == "webhook" {
+			t.Errorf("Result is incorrect, got: '%+v', want: no pending webhook messages.", m)
+		}
+		d.MarkSent(m.ID, 0)
+	}
+
+	if !d.UnlinkChannel(1, "email") || d.UnlinkChannel(1, "email") || len(d.GetChannels(1)) != 0 {
+		t.Errorf("Expected email to be unlinked once.")
+	}
+}
+
+func TestConfirmChannel(t *testing.T) {
+	d := openTest(t)
+	d.EnsureUserInDB(1)
+
+	if !d.LastPendingChannel(1).IsZero() {
+		t.Errorf("Expected no pending links.")
+	}
+	d.AddPendingChannel(1, "email", "user@example.com", "123456")
+	if d.LastPendingChannel(1).IsZero() || len(d.GetChannels(1)) != 0 {
+		t.Errorf("Expected link to be pending.")
+	}
+	if c := d.ConfirmChannel(1, "654321"); c != nil {
+		t.Errorf("Result is incorrect, got: '%+v', want: 'nil' for wrong code.", c)
+	}
+	if c := d.ConfirmChannel(2, "123456"); c != nil {
+		t.Errorf("Result is incorrect, got: '%+v', want: 'nil' for other chat.", c)
+	}
+	c := d.ConfirmChannel(1, "123456")
+	if c == nil || *c != (Channel{"email", "user@example.com"}) {
+		t.Errorf("Result is incorrect, got: '%+v', want: email.", c)
+	}
+	if channels := d.GetChannels(1); len(channels) != 1 || !d.LastPendingChannel(1).IsZero() {
+		t.Errorf("Result is incorrect, got: '%+v', want: confirmed email.", channels)
+	}
+	if c = d.ConfirmChannel(1, "123456"); c != nil {
+		t.Errorf("Result is incorrect, got: '%+v', want: 'nil' for used code.", c)
+	}
+
+	// Code cannot be guessed
+	d.AddPendingChannel(1, "webhook", "https://example.com/hook", "111111")
+	for i := 0; i < MaxConfirmationAttempts; i++ {
+		d.ConfirmChannel(1, "000000")
+	}
+	if c = d.ConfirmChannel(1, "111111"); c != nil {
+		t.Errorf("Result is incorrect, got: '%+v', want: 'nil' after too many attempts.", c)
+	}
+
+	// Code expires
+	d.AddPendingChannel(1, "webhook", "https://example.com/hook", "222222")
+	if _, err := d.db.Exec("UPDATE pending_channels SET created_at=?", time.Now().Add(-2*ConfirmationTTL).Unix()); err != nil {
+		t.Fatal(err)
+	}
+	if c = d.ConfirmChannel(1, "222222"); c != nil {
+		t.Errorf("Result is incorrect, got: '%+v', want: 'nil' for expired code.", c)
+	}
+}
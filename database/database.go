@@ -1,145 +1,332 @@
 package database
 
 import (
+	"bbtmvbot/website"
 	"database/sql"
 	"log"
-	"os"
+	"sync"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
-const CREATE_DB = `
-BEGIN TRANSACTION;
-CREATE TABLE IF NOT EXISTS "users" (
-	"telegram_id"	INTEGER NOT NULL UNIQUE,
-	"enabled"	INTEGER NOT NULL DEFAULT 0,
-	"price_from"	INTEGER NOT NULL DEFAULT 0,
-	"price_to"	INTEGER NOT NULL DEFAULT 0,
-	"rooms_from"	INTEGER NOT NULL DEFAULT 0,
-	"rooms_to"	INTEGER NOT NULL DEFAULT 0,
-	"year_from"	INTEGER NOT NULL DEFAULT 0,
-	"min_floor" INTEGER NOT NULL DEFAULT 0,
-	"show_with_fee" INTEGER NOT NULL DEFAULT 0,
-	PRIMARY KEY("telegram_id")
-);
-CREATE TABLE IF NOT EXISTS "posts" (
-	"id"	INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT UNIQUE,
-	"link"	TEXT NOT NULL UNIQUE,
-	"last_seen"	INTEGER NOT NULL
-);
-CREATE UNIQUE INDEX IF NOT EXISTS "index_posts_link" ON "posts" (
-	"link"
-);
-COMMIT;
-`
-
 type Database struct {
 	db *sql.DB
+
+	// Serializes finding duplicates and inserting posts
+	insertMu sync.Mutex
 }
 
 func Open(path string) (*Database, error) {
-	_, fileErr := os.Stat(path)
 	d, err := sql.Open("sqlite3", "file:"+path+"?_mutex=full")
-	if os.IsNotExist(fileErr) {
-		_, err := d.Exec(CREATE_DB)
-		if err != nil {
-			panic(err)
-		}
+	if err != nil {
+		return nil, err
 	}
-	return &Database{d}, err
+	if err = migrate(d); err != nil {
+		d.Close()
+		return nil, err
+	}
+	return &Database{db: d}, nil
 }
 
 type User struct {
-	TelegramID   int64
-	Enabled      bool
-	PriceFrom    int
-	PriceTo      int
-	RoomsFrom    int
-	RoomsTo      int
-	YearFrom     int
-	MinFloor     int
-	ShowWithFees bool
+	TelegramID int64
+	Enabled    bool
+	Filter
 }
 
-func (d *Database) GetInterestedTelegramIDs(price, rooms, year int, floor int, isWithFee bool) []int64 {
-	telegram_IDs := make([]int64, 0)
-	query := "SELECT telegram_id FROM users WHERE enabled=1 AND ? >= price_from AND ? <= price_to AND ? >= rooms_from AND ? <= rooms_to AND ? >= year_from AND min_floor <= ? "
-	if isWithFee {
-		query += "AND show_with_fee = 1"
+func (d *Database) EnsureUserInDB(telegramID int64) {
+	query := "INSERT OR IGNORE INTO users(telegram_id) VALUES(?)"
+	_, err := d.db.Exec(query, telegramID)
+	if err != nil {
+		log.Fatalln(err)
 	}
-	rows, err := d.db.Query(query, price, price, rooms, rooms, year, floor)
+}
+
+// Details of posts, whose listing price is not known, are retrieved again
+// after RefetchInterval, so their price changes are noticed too. Only posts
+// first seen within RefetchMaxAge are retrieved, as each retrieval opens the
+// post in headless browser.
+const (
+	RefetchInterval = 12 * time.Hour
+	RefetchMaxAge   = 3 * 24 * time.Hour
+)
+
+// Seen reports whether post with given link is already in database and
+// refreshes its last_seen if so. Post is not considered seen if its known
+// price differs from the given one, so its details are retrieved again.
+// Websites not showing price in listing (aruodas and nuomininkai) pass 0, so
+// their recent posts are not considered seen once details are older than
+// RefetchInterval.
+func (d *Database) Seen(link string, price int) bool {
+	var storedPrice int
+	var firstSeen, fetchedAt int64
+	err := d.db.QueryRow("SELECT price, first_seen, fetched_at FROM posts WHERE link=? LIMIT 1", link).Scan(&storedPrice, &firstSeen, &fetchedAt)
+	if err == sql.ErrNoRows {
+		return false
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+	query := "UPDATE posts SET last_seen=? WHERE link=?"
+	_, err = d.db.Exec(query, time.Now().Unix(), link)
 	if err != nil {
 		panic(err)
 	}
-	defer rows.Close()
-	for rows.Next() {
-		var telegramID int64
-		if err = rows.Scan(&telegramID); err != nil {
-			panic(err)
-		}
-		telegram_IDs = append(telegram_IDs, telegramID)
+	if price == 0 {
+		return time.Since(time.Unix(firstSeen, 0)) > RefetchMaxAge || time.Since(time.Unix(fetchedAt, 0)) < RefetchInterval
 	}
-	if err = rows.Err(); err != nil {
+	return storedPrice == 0 || price == storedPrice
+}
+
+// Post is a post stored in database.
+type Post struct {
+	website.Post
+	ID        int64
+	WithFee   bool
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+func (d *Database) AddPost(p *website.Post) int64 {
+	now := time.Now().Unix()
+	query := "INSERT INTO posts(link, source, city, phone, description, address, heating, floor, floor_total, area, price, rooms, year, with_fee, fingerprint, first_seen, last_seen, fetched_at) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	res, err := d.db.Exec(query, p.Link, p.Source, p.City, p.Phone, p.Description, p.Address, p.Heating, p.Floor, p.FloorTotal, p.Area, p.Price, p.Rooms, p.Year, p.IsWithFee(), p.Fingerprint(), now, now, now)
+	if err != nil {
+		panic(err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
 		panic(err)
 	}
-	return telegram_IDs
+	return id
 }
 
-func (d *Database) EnsureUserInDB(telegramID int64) {
-	query := "INSERT OR IGNORE INTO users(telegram_id) VALUES(?)"
-	_, err := d.db.Exec(query, telegramID)
+const postColumns = "id, link, source, city, phone, description, address, heating, floor, floor_total, area, price, rooms, year, with_fee, first_seen, last_seen"
+
+// scanner is implemented by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPost(s scanner) (*Post, error) {
+	var p Post
+	var firstSeen, lastSeen int64
+	err := s.Scan(&p.ID, &p.Link, &p.Source, &p.City, &p.Phone, &p.Description, &p.Address, &p.Heating, &p.Floor, &p.FloorTotal, &p.Area, &p.Price, &p.Rooms, &p.Year, &p.WithFee, &firstSeen, &lastSeen)
 	if err != nil {
-		log.Fatalln(err)
+		return nil, err
 	}
+	p.FirstSeen = time.Unix(firstSeen, 0)
+	p.LastSeen = time.Unix(lastSeen, 0)
+	return &p, nil
 }
 
-func (d *Database) InDatabase(link string) bool {
-	var count int
-	err := d.db.QueryRow("SELECT COUNT(*) AS count FROM posts WHERE link=? LIMIT 1", link).Scan(&count)
+// GetPost returns stored post with given ID, or nil if there is no such post.
+func (d *Database) GetPost(id int64) *Post {
+	p, err := scanPost(d.db.QueryRow("SELECT "+postColumns+" FROM posts WHERE id=?", id))
+	if err == sql.ErrNoRows {
+		return nil
+	}
 	if err != nil {
-		log.Fatalln(err)
+		panic(err)
 	}
-	if count <= 0 {
-		return false
+	return p
+}
+
+// GetPostByLink returns stored post with given link, or nil if there is no
+// such post.
+func (d *Database) GetPostByLink(link string) *Post {
+	p, err := scanPost(d.db.QueryRow("SELECT "+postColumns+" FROM posts WHERE link=?", link))
+	if err == sql.ErrNoRows {
+		return nil
 	}
-	query := "UPDATE posts SET last_seen=? WHERE link=?"
-	_, err = d.db.Exec(query, time.Now().Unix(), link)
 	if err != nil {
 		panic(err)
 	}
-	return true
+	return p
 }
 
-func (d *Database) AddPost(link string) int64 {
-	query := "INSERT INTO posts(link, last_seen) VALUES(?, ?)"
-	res, err := d.db.Exec(query, link, time.Now().Unix())
+// FindRelisted returns the latest stored post of the same website with the
+// same phone, area and address, but a different link, or nil if there is
+// no such post.
+func (d *Database) FindRelisted(p *website.Post) *Post {
+	if p.Phone == "" || p.Area == 0 || p.Address == "" {
+		return nil
+	}
+	query := "SELECT " + postColumns + " FROM posts WHERE phone=? AND area=? AND address=? AND source=? AND link<>? ORDER BY id DESC LIMIT 1"
+	original, err := scanPost(d.db.QueryRow(query, p.Phone, p.Area, p.Address, p.Source, p.Link))
+	if err == sql.ErrNoRows {
+		return nil
+	}
 	if err != nil {
 		panic(err)
 	}
-	id, err := res.LastInsertId()
+	return original
+}
+
+// UpdatePost replaces stored details of the post with given ID.
+func (d *Database) UpdatePost(id int64, p *website.Post) {
+	query := "UPDATE posts SET phone=?, description=?, address=?, heating=?, floor=?, floor_total=?, area=?, price=?, rooms=?, year=?, with_fee=?, fingerprint=?, last_seen=?, fetched_at=? WHERE id=?"
+	now := time.Now().Unix()
+	_, err := d.db.Exec(query, p.Phone, p.Description, p.Address, p.Heating, p.Floor, p.FloorTotal, p.Area, p.Price, p.Rooms, p.Year, p.IsWithFee(), p.Fingerprint(), now, now, id)
 	if err != nil {
 		panic(err)
 	}
-	return id
 }
 
-// Delete posts older than 30 days
+// FindDuplicate returns post from a different website with the same
+// fingerprint, first seen after since, or nil if there is no such post.
+// Returned post is never a duplicate itself.
+func (d *Database) FindDuplicate(p *website.Post, since time.Time) *Post {
+	fingerprint := p.Fingerprint()
+	if fingerprint == "" {
+		return nil
+	}
+	query := "SELECT " + postColumns + " FROM posts WHERE fingerprint=? AND source<>? AND duplicate_of=0 AND first_seen>=? ORDER BY id DESC LIMIT 1"
+	original, err := scanPost(d.db.QueryRow(query, fingerprint, p.Source, since.Unix()))
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		panic(err)
+	}
+	return original
+}
+
+// InsertPost stores new post and returns its ID. If post is a duplicate of a
+// post from another website, first seen after since, it is marked so and the
+// original post is returned too. Finding duplicate and inserting is done at
+// once, so the same flat retrieved from several websites at the same time is
+// not stored as a new post more than once.
+func (d *Database) InsertPost(p *website.Post, since time.Time) (int64, *Post) {
+	d.insertMu.Lock()
+	defer d.insertMu.Unlock()
+
+	original := d.FindDuplicate(p, since)
+	id := d.AddPost(p)
+	if original != nil {
+		d.MarkDuplicate(id, original.ID)
+	}
+	return id, original
+}
+
+// MarkDuplicate marks post with given ID as a duplicate of another post.
+func (d *Database) MarkDuplicate(id, originalID int64) {
+	_, err := d.db.Exec("UPDATE posts SET duplicate_of=? WHERE id=?", originalID, id)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// GetDuplicates returns posts marked as duplicates of the given post.
+func (d *Database) GetDuplicates(originalID int64) []*Post {
+	return d.queryPosts("SELECT "+postColumns+" FROM posts WHERE duplicate_of=? ORDER BY id", originalID)
+}
+
+// GetOriginal returns post, which the given post is a duplicate of, or the
+// post itself if it is not a duplicate.
+func (d *Database) GetOriginal(p *Post) *Post {
+	var originalID int64
+	if err := d.db.QueryRow("SELECT duplicate_of FROM posts WHERE id=?", p.ID).Scan(&originalID); err != nil {
+		panic(err)
+	}
+	if originalID == 0 {
+		return p
+	}
+	if original := d.GetPost(originalID); original != nil {
+		return original
+	}
+	return p
+}
+
+// queryPosts returns posts selected by query, which must select postColumns.
+func (d *Database) queryPosts(query string, args ...interface{}) []*Post {
+	posts := make([]*Post, 0)
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		p, err := scanPost(rows)
+		if err != nil {
+			panic(err)
+		}
+		posts = append(posts, p)
+	}
+	if err = rows.Err(); err != nil {
+		panic(err)
+	}
+	return posts
+}
+
+// Notification is a Telegram message sent about a post.
+type Notification struct {
+	TelegramID int64
+	MessageID  int
+	// Text is the text message was sent with, or empty if it is not known
+	Text string
+}
+
+func (d *Database) AddNotification(postID, telegramID int64, messageID int, text string) {
+	query := "INSERT OR REPLACE INTO notifications(post_id, telegram_id, message_id, text) VALUES(?, ?, ?, ?)"
+	_, err := d.db.Exec(query, postID, telegramID, messageID, text)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// GetNotifications returns messages sent about the given post.
+func (d *Database) GetNotifications(postID int64) []Notification {
+	notifications := make([]Notification, 0)
+	rows, err := d.db.Query("SELECT telegram_id, message_id, text FROM notifications WHERE post_id=? ORDER BY telegram_id", postID)
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var n Notification
+		if err = rows.Scan(&n.TelegramID, &n.MessageID, &n.Text); err != nil {
+			panic(err)
+		}
+		notifications = append(notifications, n)
+	}
+	if err = rows.Err(); err != nil {
+		panic(err)
+	}
+	return notifications
+}
+
+// Delete posts older than 30 days, except saved ones
 func (d *Database) DeleteOldPosts() {
-	query := "DELETE FROM posts WHERE last_seen < ?"
+	query := "DELETE FROM posts WHERE last_seen < ? AND id NOT IN (SELECT post_id FROM favourites)"
 	_, err := d.db.Exec(query, time.Now().AddDate(0, 0, -30).Unix())
 	if err != nil {
 		panic(err)
 	}
+	_, err = d.db.Exec("DELETE FROM notifications WHERE post_id NOT IN (SELECT id FROM posts)")
+	if err != nil {
+		panic(err)
+	}
+	_, err = d.db.Exec("DELETE FROM digest_queue WHERE post_id NOT IN (SELECT id FROM posts)")
+	if err != nil {
+		panic(err)
+	}
+	_, err = d.db.Exec("DELETE FROM outbox WHERE status!=? AND created_at < ?", OutboxPending, time.Now().AddDate(0, 0, -30).Unix())
+	if err != nil {
+		panic(err)
+	}
 }
 
 func (d *Database) GetUser(telegramID int64) *User {
 	var u User
-	query := "SELECT * FROM users WHERE telegram_id=?"
-	err := d.db.QueryRow(query, telegramID).Scan(&u.TelegramID, &u.Enabled, &u.PriceFrom, &u.PriceTo, &u.RoomsFrom, &u.RoomsTo, &u.YearFrom, &u.MinFloor, &u.ShowWithFees)
+	query := "SELECT telegram_id, enabled, " + filterColumns + " FROM users WHERE telegram_id=?"
+	err := d.db.QueryRow(query, telegramID).Scan(append([]interface{}{&u.TelegramID, &u.Enabled}, u.scanDest()...)...)
 	if err != nil {
 		panic(err)
 	}
+	u.Heating = d.queryStrings("SELECT heating FROM user_heating WHERE telegram_id=? ORDER BY heating", telegramID)
+	u.Districts = d.queryStrings("SELECT district FROM user_districts WHERE telegram_id=? ORDER BY district", telegramID)
 	return &u
 }
 
@@ -151,6 +338,79 @@ func (d *Database) UpdateUser(user *User) {
 	}
 }
 
+// UpdateFilters stores additional filters of the user, leaving the ones set
+// by UpdateUser intact.
+func (d *Database) UpdateFilters(user *User) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		panic(err)
+	}
+	defer tx.Rollback()
+
+	query := "UPDATE users SET min_area=?, max_area=?, max_floor=?, not_last_floor=?, max_price_per_m2=? WHERE telegram_id=?"
+	_, err = tx.Exec(query, user.MinArea, user.MaxArea, user.MaxFloor, user.NotLastFloor, user.MaxPricePerM2, user.TelegramID)
+	if err != nil {
+		panic(err)
+	}
+	replaceStrings(tx, "user_heating", "telegram_id", "heating", user.TelegramID, user.Heating)
+	replaceStrings(tx, "user_districts", "telegram_id", "district", user.TelegramID, user.Districts)
+	if err = tx.Commit(); err != nil {
+		panic(err)
+	}
+}
+
+// AddKeyword adds keyword to user's included or excluded keywords. Keyword
+// must be normalized using website.Normalize.
+func (d *Database) AddKeyword(telegramID int64, keyword string, exclude bool) {
+	query := "INSERT OR IGNORE INTO user_keywords(telegram_id, keyword, exclude) VALUES(?, ?, ?)"
+	_, err := d.db.Exec(query, telegramID, keyword, exclude)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// RemoveKeyword removes keyword from both included and excluded keywords and
+// reports whether it was there.
+func (d *Database) RemoveKeyword(telegramID int64, keyword string) bool {
+	res, err := d.db.Exec("DELETE FROM user_keywords WHERE telegram_id=? AND keyword=?", telegramID, keyword)
+	if err != nil {
+		panic(err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		panic(err)
+	}
+	return count > 0
+}
+
+// GetKeywords returns user's included and excluded keywords.
+func (d *Database) GetKeywords(telegramID int64) (include, exclude []string) {
+	include = d.queryStrings("SELECT keyword FROM user_keywords WHERE telegram_id=? AND exclude=0 ORDER BY keyword", telegramID)
+	exclude = d.queryStrings("SELECT keyword FROM user_keywords WHERE telegram_id=? AND exclude=1 ORDER BY keyword", telegramID)
+	return
+}
+
+// queryStrings returns single string column of all rows returned by query.
+func (d *Database) queryStrings(query string, args ...interface{}) []string {
+	values := make([]string, 0)
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var value string
+		if err = rows.Scan(&value); err != nil {
+			panic(err)
+		}
+		values = append(values, value)
+	}
+	if err = rows.Err(); err != nil {
+		panic(err)
+	}
+	return values
+}
+
 func (d *Database) Enabled(telegramID int64) bool {
 	var enabled int
 	query := "SELECT enabled FROM users WHERE telegram_id=? LIMIT 1"
@@ -172,3 +432,32 @@ func (d *Database) SetEnabled(telegramID int64, enabled bool) {
 		panic(err)
 	}
 }
+
+// UserCities returns IDs of the cities user is subscribed to. Empty list means
+// that user is interested in all cities.
+func (d *Database) UserCities(telegramID int64) []string {
+	return d.queryStrings("SELECT city FROM user_cities WHERE telegram_id=? ORDER BY city", telegramID)
+}
+
+// SetUserCities replaces cities user is subscribed to.
+func (d *Database) SetUserCities(telegramID int64, cities []string) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		panic(err)
+	}
+	defer tx.Rollback()
+
+	replaceStrings(tx, "user_cities", "telegram_id", "city", telegramID, cities)
+	if err = tx.Commit(); err != nil {
+		panic(err)
+	}
+}
+
+// affected returns number of rows affected by the statement.
+func affected(res sql.Result) int64 {
+	count, err := res.RowsAffected()
+	if err != nil {
+		panic(err)
+	}
+	return count
+}
@@ -0,0 +1,351 @@
+package database
+
+import (
+	"bbtmvbot/website"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func openTest(t *testing.T) *Database {
+	d, err := Open(filepath.Join(t.TempDir(), "database.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.db.Close() })
+	return d
+}
+
+func interestedIDs(d *Database, p *website.Post) []int64 {
+	ids := make([]int64, 0)
+	for _, m := range d.GetInterested(p) {
+		ids = append(ids, m.TelegramID)
+	}
+	return ids
+}
+
+func TestAddPost(t *testing.T) {
+	d := openTest(t)
+
+	p := &website.Post{
+		Link:        "https://www.alio.lt/skelbimai/ID60331923.html",
+		Phone:       "+37061234567",
+		Description: "Vienkartinis agentūros mokestis 200 eurų.",
+		Address:     "Vilnius, Žirmūnai, Kalvarijų g.",
+		Heating:     "Centrinis kolektorinis",
+		Floor:       3,
+		FloorTotal:  5,
+		Area:        45,
+		Price:       450,
+		Rooms:       2,
+		Year:        1975,
+		Source:      "alio",
+		City:        "vilnius",
+	}
+	id := d.AddPost(p)
+
+	got := d.GetPost(id)
+	if got == nil {
+		t.Fatalf("Post %d was not found.", id)
+	}
+	if got.Post != *p || got.ID != id || !got.WithFee {
+		t.Errorf("Result is incorrect, got: '%+v', want: '%+v'.", got.Post, *p)
+	}
+	if got.FirstSeen.IsZero() || !got.FirstSeen.Equal(got.LastSeen) {
+		t.Errorf("Result is incorrect, got: '%s' and '%s', want: equal non-zero times.", got.FirstSeen, got.LastSeen)
+	}
+
+	if d.GetPost(id+1) != nil {
+		t.Errorf("Expected nil for missing post.")
+	}
+}
+
+func TestSeen(t *testing.T) {
+	d := openTest(t)
+	d.AddPost(&website.Post{Link: "https://example.com/1", Price: 300})
+
+	var data = []struct {
+		Link     string
+		Price    int
+		Expected bool
+	}{
+		{"https://example.com/1", 300, true},
+		{"https://example.com/1", 0, true},
+		{"https://example.com/1", 280, false},
+		{"https://example.com/2", 300, false},
+	}
+	for _, v := range data {
+		if got := d.Seen(v.Link, v.Price); got != v.Expected {
+			t.Errorf("Result is incorrect for '%s' %d, got: '%t', want: '%t'.", v.Link, v.Price, got, v.Expected)
+		}
+	}
+
+	// Post without listing price is retrieved again once details are old
+	old := time.Now().Add(-RefetchInterval - time.Minute).Unix()
+	if _, err := d.db.Exec("UPDATE posts SET fetched_at=?", old); err != nil {
+		t.Fatal(err)
+	}
+	if d.Seen("https://example.com/1", 0) || !d.Seen("https://example.com/1", 300) {
+		t.Errorf("Expected post with old details to be seen only if listing price is known.")
+	}
+	id := d.GetPostByLink("https://example.com/1").ID
+	d.UpdatePost(id, &website.Post{Link: "https://example.com/1", Price: 300})
+	if !d.Seen("https://example.com/1", 0) {
+		t.Errorf("Expected post to be seen after its details were retrieved again.")
+	}
+
+	// Details of old posts are not retrieved again
+	firstSeen := time.Now().Add(-RefetchMaxAge - time.Minute).Unix()
+	if _, err := d.db.Exec("UPDATE posts SET first_seen=?, fetched_at=?", firstSeen, old); err != nil {
+		t.Fatal(err)
+	}
+	if !d.Seen("https://example.com/1", 0) {
+		t.Errorf("Expected old post to be seen without listing price.")
+	}
+}
+
+func TestFindRelisted(t *testing.T) {
+	d := openTest(t)
+	original := &website.Post{Link: "https://example.com/1", Phone: "+37061234567", Area: 45, Address: "Vilnius, Žirmūnai, Kalvarijų g.", Price: 450, Source: "alio"}
+	id := d.AddPost(original)
+
+	var data = []struct {
+		Post     website.Post
+		Expected int64
+	}{
+		{website.Post{Link: "https://example.com/2", Phone: "+37061234567", Area: 45, Address: "Vilnius, Žirmūnai, Kalvarijų g.", Price: 420, Source: "alio"}, id},
+		{website.Post{Link: "https://example.com/1", Phone: "+37061234567", Area: 45, Address: "Vilnius, Žirmūnai, Kalvarijų g.", Price: 420, Source: "alio"}, 0},
+		{website.Post{Link: "https://example.com/2", Phone: "+37061234567", Area: 46, Address: "Vilnius, Žirmūnai, Kalvarijų g.", Price: 420, Source: "alio"}, 0},
+		{website.Post{Link: "https://example.com/2", Phone: "+37061234567", Area: 45, Address: "Vilnius, Žirmūnai, Kalvarijų g.", Price: 420, Source: "skelbiu"}, 0},
+		{website.Post{Link: "https://example.com/2", Area: 45, Address: "Vilnius, Žirmūnai, Kalvarijų g.", Price: 420, Source: "alio"}, 0},
+	}
+	for _, v := range data {
+		var got int64
+		if p := d.FindRelisted(&v.Post); p != nil {
+			got = p.ID
+		}
+		if got != v.Expected {
+			t.Errorf("Result is incorrect for '%+v', got: '%d', want: '%d'.", v.Post, got, v.Expected)
+		}
+	}
+}
+
+func TestUpdatePost(t *testing.T) {
+	d := openTest(t)
+	id := d.AddPost(&website.Post{Link: "https://example.com/1", Price: 450, Source: "alio", City: "vilnius"})
+	d.UpdatePost(id, &website.Post{Link: "https://example.com/1", Price: 420, Source: "alio", City: "vilnius"})
+
+	if p := d.GetPostByLink("https://example.com/1"); p == nil || p.Price != 420 || p.ID != id {
+		t.Errorf("Result is incorrect, got: '%+v', want: post %d with price 420.", p, id)
+	}
+}
+
+func TestFindDuplicate(t *testing.T) {
+	d := openTest(t)
+	aruodas := &website.Post{Link: "https://aruodas.lt/1", Phone: "+37061234567", Area: 45, Rooms: 2, Floor: 3, Address: "Vilnius, Žirmūnai, Kalvarijų g.", Source: "aruodas"}
+	id := d.AddPost(aruodas)
+
+	skelbiu := &website.Post{Link: "https://skelbiu.lt/1", Phone: "861234567", Area: 45, Rooms: 2, Floor: 3, Address: "Vilnius, Žirmūnai, Kalvarijų g. 12", Source: "skelbiu"}
+	original := d.FindDuplicate(skelbiu, time.Now().Add(-time.Hour))
+	if original == nil || original.ID != id {
+		t.Fatalf("Result is incorrect, got: '%+v', want: post %d.", original, id)
+	}
+	duplicateID := d.AddPost(skelbiu)
+	d.MarkDuplicate(duplicateID, id)
+
+	// Duplicates are never returned, only the original post
+	alio := &website.Post{Link: "https://alio.lt/1", Phone: "+37061234567", Area: 45, Rooms: 2, Floor: 3, Address: "Vilnius, Kalvarijų g.", Source: "alio"}
+	if original := d.FindDuplicate(alio, time.Now().Add(-time.Hour)); original == nil || original.ID != id {
+		t.Errorf("Result is incorrect, got: '%+v', want: post %d.", original, id)
+	}
+	if original := d.FindDuplicate(alio, time.Now().Add(time.Hour)); original != nil {
+		t.Errorf("Result is incorrect, got: '%+v', want: nil for post outside of time window.", original)
+	}
+	if original := d.FindDuplicate(&website.Post{Link: "https://aruodas.lt/2", Phone: "+37061234567", Area: 45, Rooms: 2, Floor: 3, Address: "Vilnius, Kalvarijų g.", Source: "aruodas"}, time.Now().Add(-time.Hour)); original != nil {
+		t.Errorf("Result is incorrect, got: '%+v', want: nil for post of the same website.", original)
+	}
+
+	duplicates := d.GetDuplicates(id)
+	if len(duplicates) != 1 || duplicates[0].ID != duplicateID {
+		t.Errorf("Result is incorrect, got: '%+v', want: post %d.", duplicates, duplicateID)
+	}
+
+	for _, postID := range []int64{id, duplicateID} {
+		if got := d.GetOriginal(d.GetPost(postID)).ID; got != id {
+			t.Errorf("Result of post %d is incorrect, got: '%d', want: '%d'.", postID, got, id)
+		}
+	}
+}
+
+func TestNotifications(t *testing.T) {
+	d := openTest(t)
+	id := d.AddPost(&website.Post{Link: "https://example.com/1"})
+	d.AddNotification(id, 100, 5, "first")
+	d.AddNotification(id, 200, 7, "second")
+
+	got := d.GetNotifications(id)
+	if len(got) != 2 || got[0] != (Notification{100, 5, "first"}) || got[1] != (Notification{200, 7, "second"}) {
+		t.Errorf("Result is incorrect, got: '%+v', want: '[{100 5 first} {200 7 second}]'.", got)
+	}
+}
+
+func TestGetInterested(t *testing.T) {
+	d := openTest(t)
+	users := []*User{
+		{TelegramID: 1},
+		{TelegramID: 2, Filter: Filter{MinArea: 50}},
+		{TelegramID: 3, Filter: Filter{MaxArea: 40}},
+		{TelegramID: 4, Filter: Filter{MaxFloor: 2}},
+		{TelegramID: 5, Filter: Filter{NotLastFloor: true}},
+		{TelegramID: 6, Filter: Filter{MaxPricePerM2: 9.5}},
+		{TelegramID: 7, Filter: Filter{Heating: []string{"dujinis", "centrinis"}}},
+		{TelegramID: 8, Filter: Filter{Heating: []string{"elektra"}}},
+		{TelegramID: 9, Filter: Filter{Districts: []string{"zirmunai"}}},
+		{TelegramID: 10, Filter: Filter{Districts: []string{"antakalnis"}}},
+	}
+	for _, u := range users {
+		d.EnsureUserInDB(u.TelegramID)
+		u.PriceFrom, u.PriceTo, u.RoomsFrom, u.RoomsTo, u.ShowWithFees = 0, 1000, 1, 5, true
+		d.UpdateUser(u)
+		d.UpdateFilters(u)
+	}
+	d.SetUserCities(10, []string{"kaunas"})
+
+	var data = []struct {
+		Post     website.Post
+		Expected []int64
+	}{
+		{
+			Post:     website.Post{Price: 450, Rooms: 2, Area: 38, Floor: 3, FloorTotal: 3, Heating: "Centrinis kolektorinis", Address: "Vilnius, Žirmūnai, Kalvarijų g.", City: "vilnius"},
+			Expected: []int64{1, 3, 7, 9},
+		},
+		{
+			Post:     website.Post{Price: 450, Rooms: 2, Area: 60, Floor: 1, FloorTotal: 5, Heating: "Elektra", Address: "Kaunas, Antakalnis, Kovo 11-osios g.", City: "kaunas"},
+			Expected: []int64{1, 2, 4, 5, 6, 8, 10},
+		},
+		{
+			// Unknown values pass new filters
+			Post:     website.Post{Price: 450, Rooms: 2, City: "vilnius"},
+			Expected: []int64{1, 2, 3, 4, 5, 6, 7, 8, 9},
+		},
+	}
+	for _, v := range data {
+		got := interestedIDs(d, &v.Post)
+		if !reflect.DeepEqual(got, v.Expected) {
+			t.Errorf("Result is incorrect for '%+v', got: '%v', want: '%v'.", v.Post, got, v.Expected)
+		}
+	}
+
+	u := d.GetUser(7)
+	if !reflect.DeepEqual(u.Heating, []string{"centrinis", "dujinis"}) {
+		t.Errorf("Result is incorrect, got: '%v', want: '[centrinis dujinis]'.", u.Heating)
+	}
+}
+
+func TestKeywords(t *testing.T) {
+	d := openTest(t)
+	for id := int64(1); id <= 4; id++ {
+		d.EnsureUserInDB(id)
+		d.UpdateUser(&User{TelegramID: id, Filter: Filter{PriceTo: 1000, RoomsTo: 5, ShowWithFees: true}})
+	}
+	d.AddKeyword(2, "balkon", false)
+	d.AddKeyword(2, "terasa", false)
+	d.AddKeyword(3, "tik studentams", true)
+	d.AddKeyword(4, "zirmunai", false)
+	d.AddKeyword(4, website.Normalize("Gyvūnai neleidžiami"), true)
+
+	var data = []struct {
+		Post     website.Post
+		Expected []int64
+	}{
+		{website.Post{Price: 300, Rooms: 1, Description: "Butas su BALKONU. Tik studentams!"}, []int64{1, 2}},
+		{website.Post{Price: 300, Rooms: 1, Description: "Yra terasa.", Address: "Vilnius, Žirmūnai, Kalvarijų g."}, []int64{1, 2, 3, 4}},
+		{website.Post{Price: 300, Rooms: 1, Description: "Gyvūnai neleidžiami.", Address: "Vilnius, Žirmūnai, Kalvarijų g."}, []int64{1, 3}},
+	}
+	for _, v := range data {
+		got := interestedIDs(d, &v.Post)
+		if !reflect.DeepEqual(got, v.Expected) {
+			t.Errorf("Result is incorrect for '%s', got: '%v', want: '%v'.", v.Post.Description, got, v.Expected)
+		}
+	}
+
+	if !d.RemoveKeyword(2, "terasa") || d.RemoveKeyword(2, "terasa") {
+		t.Errorf("Expected keyword to be removed only once.")
+	}
+	include, exclude := d.GetKeywords(4)
+	if !reflect.DeepEqual(include, []string{"zirmunai"}) || !reflect.DeepEqual(exclude, []string{"givunai neleidziami"}) {
+		t.Errorf("Result is incorrect, got: '%v' '%v', want: '[zirmunai]' '[givunai neleidziami]'.", include, exclude)
+	}
+}
+
+func TestInsertPostConcurrent(t *testing.T) {
+	d := openTest(t)
+
+	// The same flat retrieved from all websites at the same time is stored as
+	// a new post only once
+	sources := []string{"aruodas", "alio", "domoplius", "kampas", "nuomininkai", "rinka", "skelbiu"}
+	originals := make([]*Post, len(sources))
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i, source := range sources {
+		wg.Add(1)
+		go func(i int, source string) {
+			defer wg.Done()
+			<-start
+			p := &website.Post{Link: "https://" + source + ".lt/1", Phone: "+37061234567", Area: 45, Rooms: 2, Floor: 3, Address: "Vilnius, Kalvarijų g.", Source: source}
+			_, originals[i] = d.InsertPost(p, time.Now().Add(-time.Hour))
+		}(i, source)
+	}
+	close(start)
+	wg.Wait()
+
+	var first *Post
+	count := 0
+	for _, original := range originals {
+		if original == nil {
+			count++
+		} else if first == nil {
+			first = original
+		}
+	}
+	if count != 1 {
+		t.Fatalf("Result is incorrect, got: '%d', want: '1' post stored as new.", count)
+	}
+	if duplicates := d.GetDuplicates(first.ID); len(duplicates) != len(sources)-1 {
+		t.Errorf("Result is incorrect, got: '%d', want: '%d' duplicates.", len(duplicates), len(sources)-1)
+	}
+}
+
+func TestLoadSubscriptions(t *testing.T) {
+	d := openTest(t)
+	for _, id := range []int64{1, 2} {
+		d.EnsureUserInDB(id)
+		d.UpdateUser(&User{TelegramID: id, Enabled: true, Filter: Filter{PriceTo: 1000, RoomsFrom: 1, RoomsTo: 5}})
+	}
+	post := &website.Post{Price: 450, Rooms: 2, Source: "skelbiu", City: "vilnius"}
+
+	// Changes are not visible in already loaded subscriptions
+	subs := d.LoadSubscriptions()
+	d.MuteSource(2, "skelbiu")
+	d.SetUserCities(1, []string{"kaunas"})
+
+	var data = []struct {
+		Subs     *Subscriptions
+		Expected []Match
+		Any      bool
+	}{
+		{subs, []Match{{1, []string{}}, {2, []string{}}}, true},
+		// Portal of a listed post is not checked
+		{d.LoadSubscriptions(), []Match{}, true},
+	}
+	for i, v := range data {
+		if got := v.Subs.Interested(post); !reflect.DeepEqual(got, v.Expected) {
+			t.Errorf("Result of case %d is incorrect, got: '%v', want: '%v'.", i, got, v.Expected)
+		}
+		if got := v.Subs.AnyInterested(post.Price, post.Rooms, post.City); got != v.Any {
+			t.Errorf("Result of case %d is incorrect, got: '%t', want: '%t'.", i, got, v.Any)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package database
+
+import (
+	"strings"
+	"time"
+)
+
+// Delivery modes
+const (
+	DeliveryInstant = "instant"
+	DeliveryHourly  = "hourly"
+	DeliveryDaily   = "daily"
+)
+
+// Quiet hours modes
+const (
+	QuietOff    = ""
+	QuietHold   = "hold"   // Posts are queued until quiet hours end
+	QuietSilent = "silent" // Posts are sent without sound
+)
+
+// Delivery describes when user receives notifications. Posts matched in
+// hourly and daily modes, or during quiet hours in hold mode, are queued and
+// sent as a single digest.
+type Delivery struct {
+	Mode string
+	// At is time of the daily digest in minutes after midnight
+	At         int
+	LastDigest time.Time
+
+	QuietMode string
+	// QuietFrom and QuietTo are bounds of quiet hours in minutes after
+	// midnight. Quiet hours may span midnight.
+	QuietFrom int
+	QuietTo   int
+}
+
+const deliveryColumns = "delivery, digest_at, last_digest, quiet_mode, quiet_from, quiet_to"
+
+func scanDelivery(s scanner) (*Delivery, error) {
+	var dl Delivery
+	var lastDigest int64
+	err := s.Scan(&dl.Mode, &dl.At, &lastDigest, &dl.QuietMode, &dl.QuietFrom, &dl.QuietTo)
+	if err != nil {
+		return nil, err
+	}
+	dl.LastDigest = time.Unix(lastDigest, 0)
+	return &dl, nil
+}
+
+// Quiet reports whether quiet hours are on at the given time, which must be
+// in Vilnius time.
+func (dl *Delivery) Quiet(now time.Time) bool {
+	if dl.QuietMode == QuietOff || dl.QuietFrom == dl.QuietTo {
+		return false
+	}
+	minute := now.Hour()*60 + now.Minute()
+	if dl.QuietFrom < dl.QuietTo {
+		return minute >= dl.QuietFrom && minute < dl.QuietTo
+	}
+	return minute >= dl.QuietFrom || minute < dl.QuietTo
+}
+
+// Holds reports whether posts matched at the given time must be queued.
+func (dl *Delivery) Holds(now time.Time) bool {
+	return dl.Mode != DeliveryInstant || dl.QuietMode == QuietHold && dl.Quiet(now)
+}
+
+// Silent reports whether posts must be sent without sound at the given time.
+func (dl *Delivery) Silent(now time.Time) bool {
+	return dl.QuietMode == QuietSilent && dl.Quiet(now)
+}
+
+// Due reports whether digest should be sent at the given time. Daily digest
+// time is in the location of now. Digests are held during quiet hours in
+// hold mode.
+func (dl *Delivery) Due(now time.Time) bool {
+	if dl.QuietMode == QuietHold && dl.Quiet(now) {
+		return false
+	}
+	var scheduled time.Time
+	switch dl.Mode {
+	case DeliveryHourly:
+		scheduled = time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), 0, 0, 0, now.Location())
+	case DeliveryDaily:
+		scheduled = time.Date(now.Year(), now.Month(), now.Day(), 0, dl.At, 0, 0, now.Location())
+		if now.Before(scheduled) {
+			scheduled = scheduled.AddDate(0, 0, -1)
+		}
+	default:
+		return true
+	}
+	return dl.LastDigest.Before(scheduled)
+}
+
+// GetDelivery returns delivery mode of the user.
+func (d *Database) GetDelivery(telegramID int64) *Delivery {
+	dl, err := scanDelivery(d.db.QueryRow("SELECT "+deliveryColumns+" FROM users WHERE telegram_id=?", telegramID))
+	if err != nil {
+		panic(err)
+	}
+	return dl
+}
+
+// SetDelivery changes delivery mode of the user. Posts matched before the
+// change are sent with the next digest.
+func (d *Database) SetDelivery(telegramID int64, mode string, at int) {
+	query := "UPDATE users SET delivery=?, digest_at=?, last_digest=? WHERE telegram_id=?"
+	_, err := d.db.Exec(query, mode, at, time.Now().Unix(), telegramID)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// SetQuiet changes quiet hours of the user.
+func (d *Database) SetQuiet(telegramID int64, mode string, from, to int) {
+	query := "UPDATE users SET quiet_mode=?, quiet_from=?, quiet_to=? WHERE telegram_id=?"
+	_, err := d.db.Exec(query, mode, from, to, telegramID)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// QueuePost adds post to the next digest of the user. Searches are names of
+// user's searches the post matched.
+func (d *Database) QueuePost(telegramID, postID int64, searches []string) {
+	query := "INSERT OR REPLACE INTO digest_queue(telegram_id, post_id, searches, queued_at) VALUES(?, ?, ?, ?)"
+	_, err := d.db.Exec(query, telegramID, postID, strings.Join(searches, ", "), time.Now().Unix())
+	if err != nil {
+		panic(err)
+	}
+}
+
+// PendingDigests returns delivery modes of users that have queued posts.
+func (d *Database) PendingDigests() map[int64]*Delivery {
+	pending := map[int64]*Delivery{}
+	rows, err := d.db.Query("SELECT telegram_id, " + deliveryColumns + " FROM users WHERE telegram_id IN (SELECT telegram_id FROM digest_queue)")
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		dl, err := scanDelivery(prefixScanner{rows, &id})
+		if err != nil {
+			panic(err)
+		}
+		pending[id] = dl
+	}
+	if err = rows.Err(); err != nil {
+		panic(err)
+	}
+	return pending
+}
+
+// QueuedPost is a post waiting in a digest queue.
+type QueuedPost struct {
+	*Post
+	// Searches are names of matched searches, joined by comma
+	Searches string
+	// Duplicates are the same flat posted on other websites
+	Duplicates []*Post
+}
+
+// TakeDigest removes queued posts of the user from the queue and returns
+// them in order they were queued. Duplicates are replaced by their original
+// posts, so every flat is returned once.
+func (d *Database) TakeDigest(telegramID int64) []*QueuedPost {
+	tx, err := d.db.Begin()
+	if err != nil {
+		panic(err)
+	}
+	defer tx.Rollback()
+
+	query := "SELECT q.searches, p." + strings.ReplaceAll(postColumns, ", ", ", p.") + " FROM digest_queue q JOIN posts p ON p.id=q.post_id WHERE q.telegram_id=? ORDER BY q.queued_at, q.post_id"
+	rows, err := tx.Query(query, telegramID)
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	posts := make([]*QueuedPost, 0)
+	for rows.Next() {
+		var searches string
+		p, err := scanPost(prefixScanner{rows, &searches})
+		if err != nil {
+			panic(err)
+		}
+		posts = append(posts, &QueuedPost{Post: p, Searches: searches})
+	}
+	if err = rows.Err(); err != nil {
+		panic(err)
+	}
+
+	if _, err = tx.Exec("DELETE FROM digest_queue WHERE telegram_id=?", telegramID); err != nil {
+		panic(err)
+	}
+	if _, err = tx.Exec("UPDATE users SET last_digest=? WHERE telegram_id=?", time.Now().Unix(), telegramID); err != nil {
+		panic(err)
+	}
+	if err = tx.Commit(); err != nil {
+		panic(err)
+	}
+	return d.mergeDuplicates(posts)
+}
+
+// mergeDuplicates replaces queued duplicates by their original posts and
+// adds duplicates to every post.
+func (d *Database) mergeDuplicates(posts []*QueuedPost) []*QueuedPost {
+	merged := make([]*QueuedPost, 0, len(posts))
+	index := map[int64]*QueuedPost{}
+	for _, q := range posts {
+		original := d.GetOriginal(q.Post)
+		if m, found := index[original.ID]; found {
+			m.Searches = joinSearches(m.Searches, q.Searches)
+			continue
+		}
+		m := &QueuedPost{Post: original, Searches: q.Searches}
+		index[original.ID] = m
+		merged = append(merged, m)
+	}
+	for _, m := range merged {
+		m.Duplicates = d.GetDuplicates(m.ID)
+	}
+	return merged
+}
+
+// joinSearches joins comma separated names of searches without repeating
+// them.
+func joinSearches(a, b string) string {
+	names := make([]string, 0)
+	for _, name := range strings.Split(a+", "+b, ", ") {
+		if name != "" && !contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// prefixScanner scans the first column into dest and the rest using
+// the wrapped scanner.
+type prefixScanner struct {
+	scanner
+	dest interface{}
+}
+
+func (s prefixScanner) Scan(dest ...interface{}) error {
+	return s.scanner.Scan(append([]interface{}{s.dest}, dest...)...)
+}
@@ -0,0 +1,128 @@
+package database
+
+import (
+	"bbtmvbot/website"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDeliveryDue(t *testing.T) {
+	location, err := time.LoadLocation("Europe/Vilnius")
+	if err != nil {
+		t.Skip(err)
+	}
+	at := func(day, hour, min int) time.Time {
+		return time.Date(2021, time.March, day, hour, min, 0, 0, location)
+	}
+
+	var data = []struct {
+		Delivery Delivery
+		Now      time.Time
+		Expected bool
+	}{
+		{Delivery{Mode: DeliveryInstant}, at(10, 12, 0), true},
+		{Delivery{Mode: DeliveryHourly, LastDigest: at(10, 11, 0)}, at(10, 11, 59), false},
+		{Delivery{Mode: DeliveryHourly, LastDigest: at(10, 11, 0)}, at(10, 12, 0), true},
+		{Delivery{Mode: DeliveryHourly, LastDigest: at(10, 9, 30)}, at(10, 11, 15), true},
+		{Delivery{Mode: DeliveryDaily, At: 8 * 60, LastDigest: at(10, 8, 0)}, at(10, 23, 0), false},
+		{Delivery{Mode: DeliveryDaily, At: 8 * 60, LastDigest: at(10, 8, 0)}, at(11, 7, 59), false},
+		{Delivery{Mode: DeliveryDaily, At: 8 * 60, LastDigest: at(10, 8, 0)}, at(11, 8, 0), true},
+		{Delivery{Mode: DeliveryDaily, At: 20*60 + 30, LastDigest: at(10, 12, 0)}, at(10, 20, 30), true},
+		{Delivery{Mode: DeliveryDaily, At: 20*60 + 30, LastDigest: at(9, 21, 0)}, at(10, 20, 29), false},
+		// Digest missed while bot was not running is sent late
+		{Delivery{Mode: DeliveryDaily, At: 8 * 60, LastDigest: at(8, 8, 0)}, at(10, 7, 0), true},
+	}
+	for i, v := range data {
+		if got := v.Delivery.Due(v.Now); got != v.Expected {
+			t.Errorf("Result of case %d is incorrect, got: '%t', want: '%t'.", i, got, v.Expected)
+		}
+	}
+}
+
+func TestDigestQueue(t *testing.T) {
+	d := openTest(t)
+	d.EnsureUserInDB(1)
+	d.EnsureUserInDB(2)
+	if got := d.GetDelivery(1); got.Mode != DeliveryInstant {
+		t.Errorf("Result is incorrect, got: '%s', want: '%s'.", got.Mode, DeliveryInstant)
+	}
+	d.SetDelivery(1, DeliveryDaily, 8*60)
+	if got := d.GetDelivery(1); got.Mode != DeliveryDaily || got.At != 8*60 || time.Since(got.LastDigest) > time.Minute {
+		t.Errorf("Result is incorrect, got: '%+v', want: daily at 480 with recent last digest.", got)
+	}
+
+	first := d.AddPost(&website.Post{Link: "https://a.lt/1"})
+	second := d.AddPost(&website.Post{Link: "https://a.lt/2"})
+	d.QueuePost(1, first, nil)
+	d.QueuePost(1, second, []string{"cheap", "big"})
+
+	pending := d.PendingDigests()
+	if len(pending) != 1 || pending[1] == nil || pending[1].Mode != DeliveryDaily {
+		t.Errorf("Result is incorrect, got: '%+v', want: only user 1.", pending)
+	}
+
+	got := make([]string, 0)
+	for _, q := range d.TakeDigest(1) {
+		got = append(got, q.Link+" "+q.Searches)
+	}
+	if expected := []string{"https://a.lt/1 ", "https://a.lt/2 cheap, big"}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("Result is incorrect, got: '%v', want: '%v'.", got, expected)
+	}
+	if len(d.TakeDigest(1)) != 0 || len(d.PendingDigests()) != 0 {
+		t.Errorf("Expected digest queue to be empty.")
+	}
+
+	// Duplicates are merged into the original post
+	duplicate := d.AddPost(&website.Post{Link: "https://b.lt/1", Source: "b"})
+	d.MarkDuplicate(duplicate, first)
+	d.QueuePost(1, first, []string{"cheap"})
+	d.QueuePost(1, second, nil)
+	d.QueuePost(1, duplicate, []string{"big", "cheap"})
+	got = make([]string, 0)
+	for _, q := range d.TakeDigest(1) {
+		line := q.Link + " " + q.Searches
+		for _, dup := range q.Duplicates {
+			line += " " + dup.Link
+		}
+		got = append(got, line)
+	}
+	if expected := []string{"https://a.lt/1 cheap, big https://b.lt/1", "https://a.lt/2 "}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("Result is incorrect, got: '%v', want: '%v'.", got, expected)
+	}
+}
+
+func TestDeliveryQuiet(t *testing.T) {
+	at := func(hour, min int) time.Time {
+		return time.Date(2021, time.March, 10, hour, min, 0, 0, time.UTC)
+	}
+	night := Delivery{Mode: DeliveryInstant, QuietMode: QuietHold, QuietFrom: 23 * 60, QuietTo: 7*60 + 30}
+	lunch := Delivery{Mode: DeliveryInstant, QuietMode: QuietSilent, QuietFrom: 12 * 60, QuietTo: 13 * 60}
+	off := Delivery{Mode: DeliveryInstant, QuietFrom: 23 * 60, QuietTo: 7*60 + 30}
+	hourly := Delivery{Mode: DeliveryHourly, LastDigest: at(1, 0), QuietMode: QuietHold, QuietFrom: 23 * 60, QuietTo: 7*60 + 30}
+
+	var data = []struct {
+		Delivery Delivery
+		Now      time.Time
+		Holds    bool
+		Silent   bool
+		Due      bool
+	}{
+		{night, at(22, 59), false, false, true},
+		{night, at(23, 0), true, false, false},
+		{night, at(3, 0), true, false, false},
+		{night, at(7, 29), true, false, false},
+		{night, at(7, 30), false, false, true},
+		{lunch, at(11, 59), false, false, true},
+		{lunch, at(12, 30), false, true, true},
+		{lunch, at(13, 0), false, false, true},
+		{off, at(3, 0), false, false, true},
+		{hourly, at(3, 0), true, false, false},
+		{hourly, at(8, 0), true, false, true},
+	}
+	for i, v := range data {
+		if holds, silent, due := v.Delivery.Holds(v.Now), v.Delivery.Silent(v.Now), v.Delivery.Due(v.Now); holds != v.Holds || silent != v.Silent || due != v.Due {
+			t.Errorf("Result of case %d is incorrect, got: '%t %t %t', want: '%t %t %t'.", i, holds, silent, due, v.Holds, v.Silent, v.Due)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package database
+
+import (
+	"bbtmvbot/website"
+	"database/sql"
+	"sort"
+	"strings"
+)
+
+// Filter describes posts a chat is interested in. It is stored both in users
+// table (chat's own config) and in searches table (named searches).
+type Filter struct {
+	PriceFrom    int
+	PriceTo      int
+	RoomsFrom    int
+	RoomsTo      int
+	YearFrom     int
+	MinFloor     int
+	ShowWithFees bool
+
+	// Filters below are not applied when zero or empty
+	MinArea       int
+	MaxArea       int
+	MaxFloor      int
+	NotLastFloor  bool
+	MaxPricePerM2 float64
+	// Heating and Districts are normalized using website.Normalize
+	Heating   []string
+	Districts []string
+}
+
+const filterColumns = "price_from, price_to, rooms_from, rooms_to, year_from, min_floor, show_with_fee, min_area, max_area, max_floor, not_last_floor, max_price_per_m2"
+
+// scanDest returns scan destinations of filterColumns.
+func (f *Filter) scanDest() []interface{} {
+	return []interface{}{&f.PriceFrom, &f.PriceTo, &f.RoomsFrom, &f.RoomsTo, &f.YearFrom, &f.MinFloor, &f.ShowWithFees, &f.MinArea, &f.MaxArea, &f.MaxFloor, &f.NotLastFloor, &f.MaxPricePerM2}
+}
+
+// values returns values of filterColumns.
+func (f *Filter) values() []interface{} {
+	return []interface{}{f.PriceFrom, f.PriceTo, f.RoomsFrom, f.RoomsTo, f.YearFrom, f.MinFloor, f.ShowWithFees, f.MinArea, f.MaxArea, f.MaxFloor, f.NotLastFloor, f.MaxPricePerM2}
+}
+
+// Matches reports whether post passes the filter. Unknown (zero or empty)
+// post values pass the filters that appeared after the original ones.
+func (f *Filter) Matches(p *website.Post) bool {
+	if p.Price < f.PriceFrom || p.Price > f.PriceTo || p.Rooms < f.RoomsFrom || p.Rooms > f.RoomsTo || p.Year < f.YearFrom || p.Floor < f.MinFloor {
+		return false
+	}
+	if p.IsWithFee() && !f.ShowWithFees {
+		return false
+	}
+	if p.Area != 0 && (f.MinArea != 0 && p.Area < f.MinArea || f.MaxArea != 0 && p.Area > f.MaxArea) {
+		return false
+	}
+	if f.MaxFloor != 0 && p.Floor > f.MaxFloor {
+		return false
+	}
+	if f.NotLastFloor && p.Floor != 0 && p.Floor == p.FloorTotal {
+		return false
+	}
+	if f.MaxPricePerM2 != 0 && p.Area != 0 && float64(p.Price) > f.MaxPricePerM2*float64(p.Area) {
+		return false
+	}
+	return containsAny(website.Normalize(p.Heating), f.Heating) && containsAny(website.Normalize(p.District()), f.Districts)
+}
+
+// MayMatch reports whether post with given price and rooms count could pass
+// the filter. Zero means that value is unknown.
+func (f *Filter) MayMatch(price, rooms int) bool {
+	if price != 0 && (price < f.PriceFrom || price > f.PriceTo) {
+		return false
+	}
+	return rooms == 0 || rooms >= f.RoomsFrom && rooms <= f.RoomsTo
+}
+
+// containsAny reports whether text contains at least one of the values. Empty
+// text or values always pass.
+func containsAny(text string, values []string) bool {
+	if text == "" || len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if strings.Contains(text, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Keywords are chat's included and excluded keywords, normalized using
+// website.Normalize.
+type Keywords struct {
+	Include []string
+	Exclude []string
+}
+
+// Matches reports whether text contains at least one of included keywords
+// (if there are any) and none of excluded ones.
+func (k *Keywords) Matches(text string) bool {
+	text = website.Normalize(text)
+	for _, v := range k.Exclude {
+		if strings.Contains(text, v) {
+			return false
+		}
+	}
+	if len(k.Include) == 0 {
+		return true
+	}
+	for _, v := range k.Include {
+		if strings.Contains(text, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match is a chat interested in a post.
+type Match struct {
+	TelegramID int64
+	// Searches are names of the matched searches, empty if post matched only
+	// chat's own config
+	Searches []string
+}
+
+// subscription is a filter of enabled chat.
+type subscription struct {
+	telegramID int64
+	search     string // Empty for chat's own config
+	filter     *Filter
+}
+
+// subscriptions returns filters of all enabled chats, including their active
+// named searches.
+func (d *Database) subscriptions() []subscription {
+	subs := make([]subscription, 0)
+
+	heating := d.stringsByID("SELECT telegram_id, heating FROM user_heating")
+	districts := d.stringsByID("SELECT telegram_id, district FROM user_districts")
+	rows, err := d.db.Query("SELECT telegram_id, " + filterColumns + " FROM users WHERE enabled=1")
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		s := subscription{filter: &Filter{}}
+		if err = rows.Scan(append([]interface{}{&s.telegramID}, s.filter.scanDest()...)...); err != nil {
+			panic(err)
+		}
+		s.filter.Heating = heating[s.telegramID]
+		s.filter.Districts = districts[s.telegramID]
+		subs = append(subs, s)
+	}
+	if err = rows.Err(); err != nil {
+		panic(err)
+	}
+
+	for _, search := range d.querySearches("SELECT s.id, s.telegram_id, s.name, s.paused, s." + strings.ReplaceAll(filterColumns, ", ", ", s.") + " FROM searches s JOIN users u ON u.telegram_id=s.telegram_id WHERE u.enabled=1 AND s.paused=0 ORDER BY s.id") {
+		subs = append(subs, subscription{telegramID: search.TelegramID, search: search.Name, filter: &search.Filter})
+	}
+	return subs
+}
+
+// Subscriptions are filters of all enabled chats together with their cities,
+// keywords, muted portals and hidden posts. They are loaded once per refresh
+// of websites, so checking every listed or retrieved post does not query all
+// the tables again.
+type Subscriptions struct {
+	subs    []subscription
+	cities  map[int64][]string
+	include map[int64][]string
+	exclude map[int64][]string
+	muted   map[int64][]string
+	hidden  map[int64][]*website.Post
+}
+
+// LoadSubscriptions returns current subscriptions of all enabled chats.
+func (d *Database) LoadSubscriptions() *Subscriptions {
+	return &Subscriptions{
+		subs:    d.subscriptions(),
+		cities:  d.stringsByID("SELECT telegram_id, city FROM user_cities"),
+		include: d.stringsByID("SELECT telegram_id, keyword FROM user_keywords WHERE exclude=0"),
+		exclude: d.stringsByID("SELECT telegram_id, keyword FROM user_keywords WHERE exclude=1"),
+		muted:   d.stringsByID("SELECT telegram_id, source FROM muted_sources"),
+		hidden:  d.hiddenBy(),
+	}
+}
+
+// Interested returns chats interested in the post, in order of their
+// Telegram IDs.
+func (s *Subscriptions) Interested(p *website.Post) []Match {
+	text := p.Description + "\n" + p.Address
+
+	matches := make([]Match, 0)
+	index := map[int64]int{}
+	for _, sub := range s.subs {
+		if !inCities(p.City, s.cities[sub.telegramID]) || !sub.filter.Matches(p) {
+			continue
+		}
+		if contains(s.muted[sub.telegramID], p.Source) || isSimilar(p, s.hidden[sub.telegramID]) {
+			continue
+		}
+		keywords := &Keywords{Include: s.include[sub.telegramID], Exclude: s.exclude[sub.telegramID]}
+		if !keywords.Matches(text) {
+			continue
+		}
+
+		i, found := index[sub.telegramID]
+		if !found {
+			i = len(matches)
+			index[sub.telegramID] = i
+			matches = append(matches, Match{TelegramID: sub.telegramID, Searches: []string{}})
+		}
+		if sub.search != "" {
+			matches[i].Searches = append(matches[i].Searches, sub.search)
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool { return matches[i].TelegramID < matches[j].TelegramID })
+	return matches
+}
+
+// GetInterested is like Subscriptions.Interested with subscriptions loaded
+// just for this post.
+func (d *Database) GetInterested(p *website.Post) []Match {
+	return d.LoadSubscriptions().Interested(p)
+}
+
+// AnyInterested reports whether at least one enabled chat could be interested
+// in post with given price and rooms count in given city. Zero means that
+// value is unknown and is not used for filtering.
+func (s *Subscriptions) AnyInterested(price, rooms int, city string) bool {
+	for _, sub := range s.subs {
+		if inCities(city, s.cities[sub.telegramID]) && sub.filter.MayMatch(price, rooms) {
+			return true
+		}
+	}
+	return false
+}
+
+// AnyInterested is like Subscriptions.AnyInterested with subscriptions loaded
+// just for this check.
+func (d *Database) AnyInterested(price, rooms int, city string) bool {
+	return d.LoadSubscriptions().AnyInterested(price, rooms, city)
+}
+
+// Chats without any subscribed city are interested in all cities
+func inCities(city string, cities []string) bool {
+	return len(cities) == 0 || contains(cities, city)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// stringsByID groups string values by ID, where query returns ID and value.
+func (d *Database) stringsByID(query string, args ...interface{}) map[int64][]string {
+	values := map[int64][]string{}
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var value string
+		if err = rows.Scan(&id, &value); err != nil {
+			panic(err)
+		}
+		values[id] = append(values[id], value)
+	}
+	if err = rows.Err(); err != nil {
+		panic(err)
+	}
+	return values
+}
+
+// replaceStrings replaces values stored in a table, that has ID and a single
+// value column.
+func replaceStrings(tx *sql.Tx, table, idColumn, column string, id int64, values []string) {
+	_, err := tx.Exec("DELETE FROM "+table+" WHERE "+idColumn+"=?", id)
+	if err != nil {
+		panic(err)
+	}
+	for _, value := range values {
+		_, err = tx.Exec("INSERT OR IGNORE INTO "+table+"("+idColumn+", "+column+") VALUES(?, ?)", id, value)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
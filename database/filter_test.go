@@ -0,0 +1,30 @@
+package database
+
+import (
+	"bbtmvbot/website"
+	"testing"
+)
+
+func TestFilterMatches(t *testing.T) {
+	filter := Filter{PriceTo: 1000, RoomsTo: 5, Districts: []string{"zirmunai"}}
+	var data = []struct {
+		Address  string
+		Expected bool
+	}{
+		{"Vilnius, Žirmūnai, Kalvarijų g.", true},
+		{"Vilnius, Žirmūnai", true},
+		{"Vilnius, Antakalnis, Antakalnio g.", false},
+		{"Vilnius, Antakalnis", false},
+		// District is unknown
+		{"Vilnius, Kalvarijų g.", true},
+		{"Vilnius, Kalvarijų g. 12", true},
+		{"", true},
+	}
+
+	for _, v := range data {
+		p := &website.Post{Address: v.Address, Price: 300, Rooms: 2}
+		if got := filter.Matches(p); got != v.Expected {
+			t.Errorf("Result of '%s' is incorrect, got: '%t', want: '%t'.", v.Address, got, v.Expected)
+		}
+	}
+}
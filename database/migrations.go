@@ -0,0 +1,383 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// migration upgrades database schema by a single version. Migrations must
+// also work on databases created before schema_version table existed, so
+// they create tables only if missing and add columns using addColumn.
+type migration struct {
+	version     int
+	description string
+	up          func(tx *sql.Tx) error
+}
+
+// Append new migrations to the end, never change already released ones
+var migrations = []migration{
+	{1, "create users and posts tables", execSQL(`
+CREATE TABLE IF NOT EXISTS "users" (
+	"telegram_id"	INTEGER NOT NULL UNIQUE,
+	"enabled"	INTEGER NOT NULL DEFAULT 0,
+	"price_from"	INTEGER NOT NULL DEFAULT 0,
+	"price_to"	INTEGER NOT NULL DEFAULT 0,
+	"rooms_from"	INTEGER NOT NULL DEFAULT 0,
+	"rooms_to"	INTEGER NOT NULL DEFAULT 0,
+	"year_from"	INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY("telegram_id")
+);
+CREATE TABLE IF NOT EXISTS "posts" (
+	"id"	INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT UNIQUE,
+	"link"	TEXT NOT NULL UNIQUE,
+	"last_seen"	INTEGER NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "index_posts_link" ON "posts" (
+	"link"
+);
+`)},
+	{2, "add min_floor and show_with_fee to users", func(tx *sql.Tx) error {
+		if err := addColumn(tx, "users", "min_floor", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+			return err
+		}
+		return addColumn(tx, "users", "show_with_fee", "INTEGER NOT NULL DEFAULT 0")
+	}},
+	{3, "create user_cities table", execSQL(`
+CREATE TABLE IF NOT EXISTS "user_cities" (
+	"telegram_id"	INTEGER NOT NULL,
+	"city"	TEXT NOT NULL,
+	PRIMARY KEY("telegram_id","city")
+);
+`)},
+	{4, "store post details", func(tx *sql.Tx) error {
+		columns := []struct{ name, definition string }{
+			{"source", "TEXT NOT NULL DEFAULT ''"},
+			{"city", "TEXT NOT NULL DEFAULT ''"},
+			{"phone", "TEXT NOT NULL DEFAULT ''"},
+			{"description", "TEXT NOT NULL DEFAULT ''"},
+			{"address", "TEXT NOT NULL DEFAULT ''"},
+			{"heating", "TEXT NOT NULL DEFAULT ''"},
+			{"floor", "INTEGER NOT NULL DEFAULT 0"},
+			{"floor_total", "INTEGER NOT NULL DEFAULT 0"},
+			{"area", "INTEGER NOT NULL DEFAULT 0"},
+			{"price", "INTEGER NOT NULL DEFAULT 0"},
+			{"rooms", "INTEGER NOT NULL DEFAULT 0"},
+			{"year", "INTEGER NOT NULL DEFAULT 0"},
+			{"with_fee", "INTEGER NOT NULL DEFAULT 0"},
+			{"first_seen", "INTEGER NOT NULL DEFAULT 0"},
+		}
+		for _, c := range columns {
+			if err := addColumn(tx, "posts", c.name, c.definition); err != nil {
+				return err
+			}
+		}
+		// Best guess for posts stored before first_seen existed
+		_, err := tx.Exec(`UPDATE posts SET first_seen=last_seen WHERE first_seen=0`)
+		return err
+	}},
+	{5, "index posts for relisting detection", execSQL(`
+CREATE INDEX IF NOT EXISTS "index_posts_relisting" ON "posts" (
+	"phone", "area", "address"
+);
+`)},
+	{6, "track duplicate posts and sent notifications", func(tx *sql.Tx) error {
+		if err := addColumn(tx, "posts", "fingerprint", "TEXT NOT NULL DEFAULT ''"); err != nil {
+			return err
+		}
+		if err := addColumn(tx, "posts", "duplicate_of", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`
+CREATE INDEX IF NOT EXISTS "index_posts_fingerprint" ON "posts" (
+	"fingerprint"
+);
+CREATE TABLE IF NOT EXISTS "notifications" (
+	"post_id"	INTEGER NOT NULL,
+	"telegram_id"	INTEGER NOT NULL,
+	"message_id"	INTEGER NOT NULL,
+	PRIMARY KEY("post_id","telegram_id")
+);
+`)
+		return err
+	}},
+	{7, "add area, floor, price per m², heating and district filters", func(tx *sql.Tx) error {
+		columns := []struct{ name, definition string }{
+			{"min_area", "INTEGER NOT NULL DEFAULT 0"},
+			{"max_area", "INTEGER NOT NULL DEFAULT 0"},
+			{"max_floor", "INTEGER NOT NULL DEFAULT 0"},
+			{"not_last_floor", "INTEGER NOT NULL DEFAULT 0"},
+			{"max_price_per_m2", "REAL NOT NULL DEFAULT 0"},
+		}
+		for _, c := range columns {
+			if err := addColumn(tx, "users", c.name, c.definition); err != nil {
+				return err
+			}
+		}
+		_, err := tx.Exec(`
+CREATE TABLE IF NOT EXISTS "user_heating" (
+	"telegram_id"	INTEGER NOT NULL,
+	"heating"	TEXT NOT NULL,
+	PRIMARY KEY("telegram_id","heating")
+);
+CREATE TABLE IF NOT EXISTS "user_districts" (
+	"telegram_id"	INTEGER NOT NULL,
+	"district"	TEXT NOT NULL,
+	PRIMARY KEY("telegram_id","district")
+);
+`)
+		return err
+	}},
+	{8, "create user_keywords table", execSQL(`
+CREATE TABLE IF NOT EXISTS "user_keywords" (
+	"telegram_id"	INTEGER NOT NULL,
+	"keyword"	TEXT NOT NULL,
+	"exclude"	INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY("telegram_id","keyword","exclude")
+);
+`)},
+	{9, "create searches tables", execSQL(`
+CREATE TABLE IF NOT EXISTS "searches" (
+	"id"	INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT UNIQUE,
+	"telegram_id"	INTEGER NOT NULL,
+	"name"	TEXT NOT NULL,
+	"paused"	INTEGER NOT NULL DEFAULT 0,
+	"price_from"	INTEGER NOT NULL DEFAULT 0,
+	"price_to"	INTEGER NOT NULL DEFAULT 0,
+	"rooms_from"	INTEGER NOT NULL DEFAULT 0,
+	"rooms_to"	INTEGER NOT NULL DEFAULT 0,
+	"year_from"	INTEGER NOT NULL DEFAULT 0,
+	"min_floor"	INTEGER NOT NULL DEFAULT 0,
+	"show_with_fee"	INTEGER NOT NULL DEFAULT 0,
+	"min_area"	INTEGER NOT NULL DEFAULT 0,
+	"max_area"	INTEGER NOT NULL DEFAULT 0,
+	"max_floor"	INTEGER NOT NULL DEFAULT 0,
+	"not_last_floor"	INTEGER NOT NULL DEFAULT 0,
+	"max_price_per_m2"	REAL NOT NULL DEFAULT 0,
+	UNIQUE("telegram_id","name")
+);
+CREATE TABLE IF NOT EXISTS "search_heating" (
+	"search_id"	INTEGER NOT NULL,
+	"heating"	TEXT NOT NULL,
+	PRIMARY KEY("search_id","heating")
+);
+CREATE TABLE IF NOT EXISTS "search_districts" (
+	"search_id"	INTEGER NOT NULL,
+	"district"	TEXT NOT NULL,
+	PRIMARY KEY("search_id","district")
+);
+`)},
+	{10, "create favourites, hidden and muted_sources tables", execSQL(`
+CREATE TABLE IF NOT EXISTS "favourites" (
+	"telegram_id"	INTEGER NOT NULL,
+	"post_id"	INTEGER NOT NULL,
+	"saved_at"	INTEGER NOT NULL,
+	PRIMARY KEY("telegram_id","post_id")
+);
+CREATE TABLE IF NOT EXISTS "hidden" (
+	"telegram_id"	INTEGER NOT NULL,
+	"phone"	TEXT NOT NULL,
+	"address"	TEXT NOT NULL,
+	PRIMARY KEY("telegram_id","phone","address")
+);
+CREATE TABLE IF NOT EXISTS "muted_sources" (
+	"telegram_id"	INTEGER NOT NULL,
+	"source"	TEXT NOT NULL,
+	PRIMARY KEY("telegram_id","source")
+);
+`)},
+	{11, "add delivery modes and digest queue", func(tx *sql.Tx) error {
+		columns := []struct{ name, definition string }{
+			{"delivery", "TEXT NOT NULL DEFAULT 'instant'"},
+			{"digest_at", "INTEGER NOT NULL DEFAULT 0"},
+			{"last_digest", "INTEGER NOT NULL DEFAULT 0"},
+		}
+		for _, c := range columns {
+			if err := addColumn(tx, "users", c.name, c.definition); err != nil {
+				return err
+			}
+		}
+		_, err := tx.Exec(`
+CREATE TABLE IF NOT EXISTS "digest_queue" (
+	"telegram_id"	INTEGER NOT NULL,
+	"post_id"	INTEGER NOT NULL,
+	"searches"	TEXT NOT NULL DEFAULT '',
+	"queued_at"	INTEGER NOT NULL,
+	PRIMARY KEY("telegram_id","post_id")
+);
+`)
+		return err
+	}},
+	{12, "add quiet hours", func(tx *sql.Tx) error {
+		columns := []struct{ name, definition string }{
+			{"quiet_mode", "TEXT NOT NULL DEFAULT ''"},
+			{"quiet_from", "INTEGER NOT NULL DEFAULT 0"},
+			{"quiet_to", "INTEGER NOT NULL DEFAULT 0"},
+		}
+		for _, c := range columns {
+			if err := addColumn(tx, "users", c.name, c.definition); err != nil {
+				return err
+			}
+		}
+		return nil
+	}},
+	{13, "create outbox table", execSQL(`
+CREATE TABLE IF NOT EXISTS "outbox" (
+	"id"	INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT UNIQUE,
+	"telegram_id"	INTEGER NOT NULL,
+	"text"	TEXT NOT NULL,
+	"silent"	INTEGER NOT NULL DEFAULT 0,
+	"post_id"	INTEGER NOT NULL DEFAULT 0,
+	"status"	TEXT NOT NULL DEFAULT 'pending',
+	"attempts"	INTEGER NOT NULL DEFAULT 0,
+	"next_attempt"	INTEGER NOT NULL DEFAULT 0,
+	"message_id"	INTEGER NOT NULL DEFAULT 0,
+	"error"	TEXT NOT NULL DEFAULT '',
+	"created_at"	INTEGER NOT NULL,
+	"sent_at"	INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS "index_outbox_pending" ON "outbox" (
+	"status", "next_attempt"
+);
+`)},
+	{14, "add notification channels", func(tx *sql.Tx) error {
+		columns := []struct{ name, definition string }{
+			{"channel", "TEXT NOT NULL DEFAULT 'telegram'"},
+			{"target", "TEXT NOT NULL DEFAULT ''"},
+		}
+		for _, c := range columns {
+			if err := addColumn(tx, "outbox", c.name, c.definition); err != nil {
+				return err
+			}
+		}
+		_, err := tx.Exec(`
+UPDATE "outbox" SET "target"=CAST("telegram_id" AS TEXT) WHERE "target"='';
+CREATE TABLE IF NOT EXISTS "user_channels" (
+	"telegram_id"	INTEGER NOT NULL,
+	"channel"	TEXT NOT NULL,
+	"target"	TEXT NOT NULL,
+	PRIMARY KEY("telegram_id","channel")
+);
+`)
+		return err
+	}},
+	{15, "count rate limited outbox attempts", func(tx *sql.Tx) error {
+		return addColumn(tx, "outbox", "rate_limits", "INTEGER NOT NULL DEFAULT 0")
+	}},
+	{16, "create pending channels table", execSQL(`
+CREATE TABLE IF NOT EXISTS "pending_channels" (
+	"telegram_id"	INTEGER NOT NULL,
+	"channel"	TEXT NOT NULL,
+	"target"	TEXT NOT NULL,
+	"code"	TEXT NOT NULL,
+	"attempts"	INTEGER NOT NULL DEFAULT 0,
+	"created_at"	INTEGER NOT NULL,
+	PRIMARY KEY("telegram_id","channel")
+);
+`)},
+	{17, "store notification texts and outbox edits", func(tx *sql.Tx) error {
+		if err := addColumn(tx, "notifications", "text", "TEXT NOT NULL DEFAULT ''"); err != nil {
+			return err
+		}
+		return addColumn(tx, "outbox", "edit_message_id", "INTEGER NOT NULL DEFAULT 0")
+	}},
+	{18, "track when post details were retrieved", func(tx *sql.Tx) error {
+		if err := addColumn(tx, "posts", "fetched_at", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`UPDATE "posts" SET "fetched_at"="last_seen"`)
+		return err
+	}},
+}
+
+func execSQL(query string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(query)
+		return err
+	}
+}
+
+// addColumn adds column to the table, unless table already has it.
+func addColumn(tx *sql.Tx, table, column, definition string) error {
+	exists, err := hasColumn(tx, table, column)
+	if err != nil || exists {
+		return err
+	}
+	_, err = tx.Exec(fmt.Sprintf(`ALTER TABLE "%s" ADD COLUMN "%s" %s`, table, column, definition))
+	return err
+}
+
+func hasColumn(tx *sql.Tx, table, column string) (bool, error) {
+	rows, err := tx.Query(fmt.Sprintf(`PRAGMA table_info("%s")`, table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	found := false
+	for rows.Next() {
+		var (
+			cid          int
+			name, ctype  string
+			notNull, pk  int
+			defaultValue sql.NullString
+		)
+		if err = rows.Scan(&cid, &name, &ctype, &notNull, &defaultValue, &pk); err != nil {
+			return false, err
+		}
+		if name == column {
+			found = true
+		}
+	}
+	return found, rows.Err()
+}
+
+// migrate applies all migrations newer than the current schema version, each
+// of them in its own transaction.
+func migrate(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS "schema_version" (
+	"version"	INTEGER NOT NULL PRIMARY KEY,
+	"applied_at"	INTEGER NOT NULL
+)`)
+	if err != nil {
+		return err
+	}
+
+	current, err := schemaVersion(db)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := applyMigration(db, m); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", m.version, m.description, err)
+		}
+	}
+	return nil
+}
+
+func schemaVersion(db *sql.DB) (int, error) {
+	var version int
+	err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&version)
+	return version, err
+}
+
+func applyMigration(db *sql.DB, m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = m.up(tx); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO schema_version(version, applied_at) VALUES(?, ?)`, m.version, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,181 @@
+package database
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Schema created by the very first releases, before any migrations existed
+const originalSchema = `
+BEGIN TRANSACTION;
+CREATE TABLE IF NOT EXISTS "users" (
+	"telegram_id"	INTEGER NOT NULL UNIQUE,
+	"enabled"	INTEGER NOT NULL DEFAULT 0,
+	"price_from"	INTEGER NOT NULL DEFAULT 0,
+	"price_to"	INTEGER NOT NULL DEFAULT 0,
+	"rooms_from"	INTEGER NOT NULL DEFAULT 0,
+	"rooms_to"	INTEGER NOT NULL DEFAULT 0,
+	"year_from"	INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY("telegram_id")
+);
+CREATE TABLE IF NOT EXISTS "posts" (
+	"id"	INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT UNIQUE,
+	"link"	TEXT NOT NULL UNIQUE,
+	"last_seen"	INTEGER NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "index_posts_link" ON "posts" (
+	"link"
+);
+INSERT INTO users VALUES(1, 1, 200, 330, 1, 2, 2000);
+INSERT INTO posts(link, last_seen) VALUES('https://example.com/1', 1600000000);
+COMMIT;
+`
+
+// Schema with min_floor and show_with_fee, created before schema_version
+// table existed
+const feeSchema = `
+BEGIN TRANSACTION;
+CREATE TABLE IF NOT EXISTS "users" (
+	"telegram_id"	INTEGER NOT NULL UNIQUE,
+	"enabled"	INTEGER NOT NULL DEFAULT 0,
+	"price_from"	INTEGER NOT NULL DEFAULT 0,
+	"price_to"	INTEGER NOT NULL DEFAULT 0,
+	"rooms_from"	INTEGER NOT NULL DEFAULT 0,
+	"rooms_to"	INTEGER NOT NULL DEFAULT 0,
+	"year_from"	INTEGER NOT NULL DEFAULT 0,
+	"min_floor" INTEGER NOT NULL DEFAULT 0,
+	"show_with_fee" INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY("telegram_id")
+);
+CREATE TABLE IF NOT EXISTS "posts" (
+	"id"	INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT UNIQUE,
+	"link"	TEXT NOT NULL UNIQUE,
+	"last_seen"	INTEGER NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "index_posts_link" ON "posts" (
+	"link"
+);
+INSERT INTO users VALUES(1, 1, 200, 330, 1, 2, 2000, 3, 1);
+INSERT INTO posts(link, last_seen) VALUES('https://example.com/1', 1600000000);
+COMMIT;
+`
+
+// createLegacy creates database file with the given schema, as it was done
+// before migrations existed.
+func createLegacy(t *testing.T, schema string) string {
+	path := filepath.Join(t.TempDir(), "database.db")
+	d, err := sql.Open("sqlite3", "file:"+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if _, err = d.Exec(schema); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func latestVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+func TestMigrationsVersions(t *testing.T) {
+	for i, m := range migrations {
+		if m.version != i+1 {
+			t.Errorf("Result is incorrect, got: '%d', want: '%d'.", m.version, i+1)
+		}
+	}
+}
+
+func TestOpenUpgradesLegacySchema(t *testing.T) {
+	var data = []struct {
+		Schema string
+		Want   User
+	}{
+		{originalSchema, User{TelegramID: 1, Enabled: true, Filter: Filter{PriceFrom: 200, PriceTo: 330, RoomsFrom: 1, RoomsTo: 2, YearFrom: 2000, Heating: []string{}, Districts: []string{}}}},
+		{feeSchema, User{TelegramID: 1, Enabled: true, Filter: Filter{PriceFrom: 200, PriceTo: 330, RoomsFrom: 1, RoomsTo: 2, YearFrom: 2000, MinFloor: 3, ShowWithFees: true, Heating: []string{}, Districts: []string{}}}},
+	}
+	for _, v := range data {
+		path := createLegacy(t, v.Schema)
+
+		d, err := Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		version, err := schemaVersion(d.db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version != latestVersion() {
+			t.Errorf("Result is incorrect, got: '%d', want: '%d'.", version, latestVersion())
+		}
+
+		got := d.GetUser(1)
+		if !reflect.DeepEqual(*got, v.Want) {
+			t.Errorf("Result is incorrect, got: '%+v', want: '%+v'.", *got, v.Want)
+		}
+
+		if !d.Seen("https://example.com/1", 300) {
+			t.Errorf("Post was lost during migration.")
+		}
+		if p := d.GetPost(1); p == nil || p.FirstSeen.Unix() != 1600000000 {
+			t.Errorf("Result is incorrect, got: '%+v', want: post first seen at 1600000000.", p)
+		}
+
+		d.SetUserCities(1, []string{"kaunas"})
+		if cities := d.UserCities(1); len(cities) != 1 || cities[0] != "kaunas" {
+			t.Errorf("Result is incorrect, got: '%v', want: '[kaunas]'.", cities)
+		}
+		d.db.Close()
+	}
+}
+
+func TestOpenIsIdempotent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "database.db")
+	for i := 0; i < 2; i++ {
+		d, err := Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var count int
+		if err = d.db.QueryRow("SELECT COUNT(*) FROM schema_version").Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		if count != len(migrations) {
+			t.Errorf("Result is incorrect, got: '%d', want: '%d'.", count, len(migrations))
+		}
+		d.db.Close()
+	}
+}
+
+func TestFailedMigrationIsRolledBack(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "database.db")
+	d, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.db.Close()
+
+	broken := migration{latestVersion() + 1, "broken", execSQL(`CREATE TABLE "broken" ("id" INTEGER); INSERT INTO missing VALUES(1);`)}
+	if err = applyMigration(d.db, broken); err == nil {
+		t.Fatal("Expected error, got nil.")
+	}
+
+	version, err := schemaVersion(d.db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != latestVersion() {
+		t.Errorf("Result is incorrect, got: '%d', want: '%d'.", version, latestVersion())
+	}
+	var count int
+	if err = d.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name='broken'`).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("Table of failed migration was not rolled back.")
+	}
+}
@@ -0,0 +1,206 @@
+package database
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Statuses of outbox messages
+const (
+	OutboxPending  = "pending"
+	OutboxSent     = "sent"
+	OutboxFailed   = "failed"   // Failed permanently or too many times
+	OutboxInactive = "inactive" // Chat blocked the bot or does not exist
+)
+
+// OutboxMessage is a message waiting to be sent, or already handled.
+type OutboxMessage struct {
+	ID         int64
+	TelegramID int64
+	// Channel and Target tell where message is sent, e.g. "email" and email
+	// address. Target of Telegram messages is chat ID.
+	Channel string
+	Target  string
+	Text    string
+	Silent  bool
+	// PostID is ID of the post message notifies about, or 0
+	PostID int64
+	// EditMessageID is ID of the Telegram message this message replaces, or 0
+	EditMessageID int
+	Status        string
+	Attempts      int
+	// RateLimits is count of attempts rejected because of rate limits
+	RateLimits  int
+	NextAttempt time.Time
+	MessageID   int
+	Error       string
+}
+
+const outboxColumns = "id, telegram_id, channel, target, text, silent, post_id, edit_message_id, status, attempts, rate_limits, next_attempt, message_id, error"
+
+func scanOutbox(s scanner) (*OutboxMessage, error) {
+	var m OutboxMessage
+	var nextAttempt int64
+	err := s.Scan(&m.ID, &m.TelegramID, &m.Channel, &m.Target, &m.Text, &m.Silent, &m.PostID, &m.EditMessageID, &m.Status, &m.Attempts, &m.RateLimits, &nextAttempt, &m.MessageID, &m.Error)
+	if err != nil {
+		return nil, err
+	}
+	m.NextAttempt = time.Unix(nextAttempt, 0)
+	return &m, nil
+}
+
+// Enqueue adds message to the outbox of the chat and of all channels linked
+// to it. Returns outbox ID of the message to the chat itself.
+func (d *Database) Enqueue(telegramID int64, text string, silent bool, postID int64) int64 {
+	tx, err := d.db.Begin()
+	if err != nil {
+		panic(err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+	query := "INSERT INTO outbox(telegram_id, channel, target, text, silent, post_id, created_at) VALUES(?, ?, ?, ?, ?, ?, ?)"
+	res, err := tx.Exec(query, telegramID, ChannelTelegram, strconv.FormatInt(telegramID, 10), text, silent, postID, now)
+	if err != nil {
+		panic(err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		panic(err)
+	}
+
+	query = "INSERT INTO outbox(telegram_id, channel, target, text, silent, post_id, created_at) " +
+		"SELECT telegram_id, channel, target, ?, ?, ?, ? FROM user_channels WHERE telegram_id=? ORDER BY channel"
+	if _, err = tx.Exec(query, text, silent, postID, now, telegramID); err != nil {
+		panic(err)
+	}
+	if err = tx.Commit(); err != nil {
+		panic(err)
+	}
+	return id
+}
+
+// EnqueueEdit adds replacement of already sent Telegram message to the
+// outbox. Linked channels, which cannot edit messages, get the follow-up
+// message instead.
+func (d *Database) EnqueueEdit(telegramID int64, messageID int, text, followUp string, postID int64) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		panic(err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+	query := "INSERT INTO outbox(telegram_id, channel, target, text, silent, post_id, edit_message_id, created_at) VALUES(?, ?, ?, ?, 1, ?, ?, ?)"
+	if _, err = tx.Exec(query, telegramID, ChannelTelegram, strconv.FormatInt(telegramID, 10), text, postID, messageID, now); err != nil {
+		panic(err)
+	}
+	query = "INSERT INTO outbox(telegram_id, channel, target, text, silent, post_id, created_at) " +
+		"SELECT telegram_id, channel, target, ?, 1, ?, ? FROM user_channels WHERE telegram_id=? ORDER BY channel"
+	if _, err = tx.Exec(query, followUp, postID, now, telegramID); err != nil {
+		panic(err)
+	}
+	if err = tx.Commit(); err != nil {
+		panic(err)
+	}
+}
+
+// NextOutbox returns the oldest pending message, which is due at the given
+// time and is not addressed to one of the skipped chats, or nil if there is
+// no such message. Messages are sent to each target in order, so message
+// waiting for retry holds back later messages to the same target.
+func (d *Database) NextOutbox(now time.Time, skip ...int64) *OutboxMessage {
+	query := "SELECT " + outboxColumns + " FROM outbox o WHERE status=? AND next_attempt<=? AND NOT EXISTS (" +
+		"SELECT 1 FROM outbox e WHERE e.status=? AND e.telegram_id=o.telegram_id AND e.channel=o.channel AND e.target=o.target AND e.id<o.id)"
+	args := []interface{}{OutboxPending, now.Unix(), OutboxPending}
+	if len(skip) > 0 {
+		query += " AND telegram_id NOT IN (?" + strings.Repeat(", ?", len(skip)-1) + ")"
+		for _, id := range skip {
+			args = append(args, id)
+		}
+	}
+	m, err := scanOutbox(d.db.QueryRow(query+" ORDER BY id LIMIT 1", args...))
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// GetOutbox returns outbox message with given ID, or nil if there is no such
+// message.
+func (d *Database) GetOutbox(id int64) *OutboxMessage {
+	m, err := scanOutbox(d.db.QueryRow("SELECT "+outboxColumns+" FROM outbox WHERE id=?", id))
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// MarkSent records that message was sent. Message ID is ID of the Telegram
+// message, or 0 for other channels.
+func (d *Database) MarkSent(id int64, messageID int) {
+	query := "UPDATE outbox SET status=?, attempts=attempts+1, message_id=?, error='', sent_at=? WHERE id=?"
+	_, err := d.db.Exec(query, OutboxSent, messageID, time.Now().Unix(), id)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// RetryLater records failed attempt and postpones message until the given
+// time.
+func (d *Database) RetryLater(id int64, next time.Time, reason string) {
+	query := "UPDATE outbox SET attempts=attempts+1, next_attempt=?, error=? WHERE id=?"
+	_, err := d.db.Exec(query, next.Unix(), reason, id)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// RateLimited records attempt rejected because of rate limits and postpones
+// message until the given time. These are counted separately from failed
+// attempts, as they are expected during bursts.
+func (d *Database) RateLimited(id int64, next time.Time, reason string) {
+	query := "UPDATE outbox SET rate_limits=rate_limits+1, next_attempt=?, error=? WHERE id=?"
+	_, err := d.db.Exec(query, next.Unix(), reason, id)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// MarkFailed gives up sending the message.
+func (d *Database) MarkFailed(id int64, reason string) {
+	query := "UPDATE outbox SET status=?, attempts=attempts+1, error=? WHERE id=?"
+	_, err := d.db.Exec(query, OutboxFailed, reason, id)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// DeactivateChat disables notifications of a chat that cannot receive
+// messages anymore and marks its pending Telegram messages as inactive.
+func (d *Database) DeactivateChat(telegramID int64, reason string) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		panic(err)
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec("UPDATE users SET enabled=0 WHERE telegram_id=?", telegramID); err != nil {
+		panic(err)
+	}
+	query := "UPDATE outbox SET status=?, error=? WHERE telegram_id=? AND channel=? AND status=?"
+	if _, err = tx.Exec(query, OutboxInactive, reason, telegramID, ChannelTelegram, OutboxPending); err != nil {
+		panic(err)
+	}
+	if err = tx.Commit(); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,90 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOutbox(t *testing.T) {
+	d := openTest(t)
+	d.EnsureUserInDB(1)
+	d.EnsureUserInDB(2)
+	d.SetEnabled(1, true)
+	d.SetEnabled(2, true)
+
+	now := time.Now()
+	first := d.Enqueue(1, "first", false, 5)
+	second := d.Enqueue(1, "second", true, 0)
+	third := d.Enqueue(2, "third", false, 0)
+
+	m := d.NextOutbox(now)
+	if m == nil || m.ID != first || m.Text != "first" || m.PostID != 5 || m.Silent || m.Status != OutboxPending {
+		t.Fatalf("Result is incorrect, got: '%+v', want: pending message 'first'.", m)
+	}
+	if m = d.NextOutbox(now, 1); m == nil || m.ID != third {
+		t.Errorf("Result is incorrect, got: '%+v', want: message 'third' when chat 1 is skipped.", m)
+	}
+	if m = d.NextOutbox(now, 1, 2); m != nil {
+		t.Errorf("Result is incorrect, got: '%+v', want: no message when all chats are skipped.", m)
+	}
+	d.MarkSent(first, 42)
+	if m = d.GetOutbox(first); m.Status != OutboxSent || m.MessageID != 42 || m.Attempts != 1 {
+		t.Errorf("Result is incorrect, got: '%+v', want: sent message 42.", m)
+	}
+
+	// Postponed message is skipped until it is due
+	d.RetryLater(second, now.Add(time.Minute), "timeout")
+	if m = d.NextOutbox(now); m == nil || m.ID != third {
+		t.Errorf("Result is incorrect, got: '%+v', want: message 'third'.", m)
+	}
+	// Later message of the same chat waits for the postponed one
+	later := d.Enqueue(1, "later", false, 0)
+	if m = d.NextOutbox(now, 2); m != nil {
+		t.Errorf("Result is incorrect, got: '%+v', want: no message before postponed one.", m)
+	}
+	if m = d.NextOutbox(now.Add(2 * time.Minute)); m == nil || m.ID != second || m.Attempts != 1 || m.Error != "timeout" || !m.Silent {
+		t.Errorf("Result is incorrect, got: '%+v', want: message 'second' after 1 attempt.", m)
+	}
+	d.RateLimited(second, now, "flood")
+	if m = d.GetOutbox(second); m.Attempts != 1 || m.RateLimits != 1 {
+		t.Errorf("Result is incorrect, got: '%d %d', want: '1 1'.", m.Attempts, m.RateLimits)
+	}
+	d.MarkFailed(second, "bad request")
+	if m = d.GetOutbox(second); m.Status != OutboxFailed || m.Attempts != 2 {
+		t.Errorf("Result is incorrect, got: '%+v', want: failed message after 2 attempts.", m)
+	}
+	if m = d.NextOutbox(now, 2); m == nil || m.ID != later {
+		t.Errorf("Result is incorrect, got: '%+v', want: message 'later' once postponed one failed.", m)
+	}
+	d.MarkSent(later, 43)
+
+	d.Enqueue(2, "fourth", false, 0)
+	d.DeactivateChat(2, "blocked")
+	if d.Enabled(2) || !d.Enabled(1) {
+		t.Errorf("Expected only chat 2 to be disabled.")
+	}
+	if m = d.NextOutbox(now); m != nil {
+		t.Errorf("Result is incorrect, got: '%+v', want: no pending messages.", m)
+	}
+	if m = d.GetOutbox(third); m.Status != OutboxInactive || m.Error != "blocked" {
+		t.Errorf("Result is incorrect, got: '%+v', want: inactive message.", m)
+	}
+}
+
+func TestEnqueueEdit(t *testing.T) {
+	d := openTest(t)
+	d.EnsureUserInDB(1)
+	d.AddPendingChannel(1, "email", "user@example.com", "123456")
+	d.ConfirmChannel(1, "123456")
+
+	d.EnqueueEdit(1, 42, "edited", "follow-up", 5)
+	telegram := d.NextOutbox(time.Now())
+	if telegram == nil || telegram.Channel != ChannelTelegram || telegram.Text != "edited" || telegram.EditMessageID != 42 || telegram.PostID != 5 {
+		t.Fatalf("Result is incorrect, got: '%+v', want: edit of message 42.", telegram)
+	}
+	d.MarkSent(telegram.ID, 42)
+	email := d.NextOutbox(time.Now())
+	if email == nil || email.Channel != "email" || email.Text != "follow-up" || email.EditMessageID != 0 || email.PostID != 5 {
+		t.Errorf("Result is incorrect, got: '%+v', want: follow-up email.", email)
+	}
+}
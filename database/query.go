@@ -0,0 +1,109 @@
+package database
+
+import (
+	"bbtmvbot/website"
+	"strings"
+	"time"
+)
+
+// PostQuery selects stored posts for browsing.
+type PostQuery struct {
+	Filter Filter
+	// Cities of posts, empty means all cities
+	Cities []string
+	// Chat whose keywords, muted portals and hidden posts are applied, like
+	// to its notifications. Zero means none.
+	TelegramID int64
+	// Only posts first seen since this time are selected
+	Since time.Time
+	// Offset and Limit select page of the matching posts
+	Offset int
+	Limit  int
+}
+
+// where returns SQL condition and its arguments selecting posts matching the
+// query. Heating, district and keyword filters and hidden posts are not
+// included, as they match normalized text, which SQLite cannot do.
+func (q *PostQuery) where() (string, []interface{}) {
+	f := &q.Filter
+	conditions := []string{"duplicate_of=0", "price>0", "first_seen>=?", "price>=?", "price<=?", "rooms>=?", "rooms<=?", "year>=?", "floor>=?"}
+	args := []interface{}{q.Since.Unix(), f.PriceFrom, f.PriceTo, f.RoomsFrom, f.RoomsTo, f.YearFrom, f.MinFloor}
+
+	// Unknown (zero) values pass the same filters as in Filter.Matches
+	if !f.ShowWithFees {
+		conditions = append(conditions, "with_fee=0")
+	}
+	if f.MinArea != 0 {
+		conditions = append(conditions, "(area=0 OR area>=?)")
+		args = append(args, f.MinArea)
+	}
+	if f.MaxArea != 0 {
+		conditions = append(conditions, "(area=0 OR area<=?)")
+		args = append(args, f.MaxArea)
+	}
+	if f.MaxFloor != 0 {
+		conditions = append(conditions, "floor<=?")
+		args = append(args, f.MaxFloor)
+	}
+	if f.NotLastFloor {
+		conditions = append(conditions, "(floor=0 OR floor<>floor_total)")
+	}
+	if f.MaxPricePerM2 != 0 {
+		conditions = append(conditions, "(area=0 OR price<=?*area)")
+		args = append(args, f.MaxPricePerM2)
+	}
+	if len(q.Cities) > 0 {
+		conditions = append(conditions, "city IN (?"+strings.Repeat(", ?", len(q.Cities)-1)+")")
+		for _, city := range q.Cities {
+			args = append(args, city)
+		}
+	}
+	if q.TelegramID != 0 {
+		conditions = append(conditions, "source NOT IN (SELECT source FROM muted_sources WHERE telegram_id=?)")
+		args = append(args, q.TelegramID)
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// FindPosts returns page of posts matching the query, newest first, and
+// total count of matching posts. Duplicates and posts without price are
+// skipped.
+//
+// Page is selected in SQL, unless filter has heating or districts, or chat
+// has keywords or hidden posts, which are matched in Go. Then all posts
+// matching other filters since q.Since are loaded, so Since should be limited
+// (/find allows 30 days at most).
+func (d *Database) FindPosts(q *PostQuery) ([]*Post, int) {
+	where, args := q.where()
+	order := " ORDER BY first_seen DESC, id DESC"
+
+	var keywords Keywords
+	var hidden []*website.Post
+	if q.TelegramID != 0 {
+		keywords.Include, keywords.Exclude = d.GetKeywords(q.TelegramID)
+		hidden = d.hiddenBy()[q.TelegramID]
+	}
+
+	if len(q.Filter.Heating) == 0 && len(q.Filter.Districts) == 0 && len(keywords.Include) == 0 && len(keywords.Exclude) == 0 && len(hidden) == 0 {
+		var total int
+		if err := d.db.QueryRow("SELECT COUNT(*) FROM posts WHERE "+where, args...).Scan(&total); err != nil {
+			panic(err)
+		}
+		query := "SELECT " + postColumns + " FROM posts WHERE " + where + order + " LIMIT ? OFFSET ?"
+		return d.queryPosts(query, append(args, q.Limit, q.Offset)...), total
+	}
+
+	posts := d.queryPosts("SELECT "+postColumns+" FROM posts WHERE "+where+order, args...)
+	page := make([]*Post, 0, q.Limit)
+	total := 0
+	for _, p := range posts {
+		if !q.Filter.Matches(&p.Post) || !keywords.Matches(p.Description+"\n"+p.Address) || isSimilar(&p.Post, hidden) {
+			continue
+		}
+		if total >= q.Offset && len(page) < q.Limit {
+			page = append(page, p)
+		}
+		total++
+	}
+	return page, total
+}
@@ -0,0 +1,143 @@
+package database
+
+import (
+	"bbtmvbot/website"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFindPosts(t *testing.T) {
+	d := openTest(t)
+	posts := []*website.Post{
+		{Link: "https://a.lt/1", City: "vilnius", Price: 300, Rooms: 1, Area: 30},
+		{Link: "https://a.lt/2", City: "vilnius", Price: 450, Rooms: 2, Area: 50},
+		{Link: "https://a.lt/3", City: "kaunas", Price: 350, Rooms: 2, Area: 45},
+		{Link: "https://a.lt/4", City: "vilnius", Price: 0, Rooms: 2},
+		{Link: "https://a.lt/5", City: "vilnius", Price: 400, Rooms: 2, Area: 20},
+		{Link: "https://b.lt/5", City: "vilnius", Price: 400, Rooms: 2, Area: 20},
+	}
+	ids := make([]int64, 0, len(posts))
+	for _, p := range posts {
+		ids = append(ids, d.AddPost(p))
+	}
+	d.MarkDuplicate(ids[5], ids[4])
+
+	since := time.Now().Add(-time.Hour)
+	var data = []struct {
+		Query    PostQuery
+		Expected []int64
+		Total    int
+	}{
+		{PostQuery{Filter: Filter{PriceTo: 1000, RoomsTo: 5}, Since: since, Limit: 10}, []int64{ids[4], ids[2], ids[1], ids[0]}, 4},
+		{PostQuery{Filter: Filter{PriceTo: 1000, RoomsTo: 5}, Since: since, Offset: 1, Limit: 2}, []int64{ids[2], ids[1]}, 4},
+		{PostQuery{Filter: Filter{PriceTo: 1000, RoomsTo: 5}, Since: since, Offset: 4, Limit: 2}, []int64{}, 4},
+		{PostQuery{Filter: Filter{PriceTo: 1000, RoomsTo: 5}, Cities: []string{"kaunas"}, Since: since, Limit: 10}, []int64{ids[2]}, 1},
+		{PostQuery{Filter: Filter{PriceFrom: 320, PriceTo: 1000, RoomsFrom: 2, RoomsTo: 5, MinArea: 40}, Since: since, Limit: 10}, []int64{ids[2], ids[1]}, 2},
+		{PostQuery{Filter: Filter{PriceTo: 1000, RoomsTo: 5}, Since: time.Now().Add(time.Hour), Limit: 10}, []int64{}, 0},
+	}
+	for i, v := range data {
+		page, total := d.FindPosts(&v.Query)
+		got := make([]int64, 0, len(page))
+		for _, p := range page {
+			got = append(got, p.ID)
+		}
+		if !reflect.DeepEqual(got, v.Expected) || total != v.Total {
+			t.Errorf("Result of case %d is incorrect, got: '%v' of %d, want: '%v' of %d.", i, got, total, v.Expected, v.Total)
+		}
+	}
+}
+
+func TestFindPostsFilters(t *testing.T) {
+	d := openTest(t)
+	posts := []*website.Post{
+		{Link: "https://a.lt/1", City: "vilnius", Price: 300, Rooms: 1, Area: 30, Floor: 2, FloorTotal: 5, Heating: "Centrinis", Address: "Vilnius, Antakalnis, Saulėtekio al."},
+		{Link: "https://a.lt/2", City: "vilnius", Price: 450, Rooms: 2, Area: 50, Floor: 5, FloorTotal: 5, Heating: "Dujinis", Address: "Vilnius, Žirmūnai, Kalvarijų g."},
+		{Link: "https://a.lt/3", City: "vilnius", Price: 350, Rooms: 2, Floor: 3, FloorTotal: 3, Description: "Agentūros mokestis 100 eur"},
+		{Link: "https://a.lt/4", City: "vilnius", Price: 400, Rooms: 2, Area: 20},
+	}
+	ids := make([]int64, 0, len(posts))
+	for _, p := range posts {
+		ids = append(ids, d.AddPost(p))
+	}
+
+	since := time.Now().Add(-time.Hour)
+	var data = []struct {
+		Filter   Filter
+		Offset   int
+		Expected []int64
+		Total    int
+	}{
+		{Filter{PriceTo: 1000, RoomsTo: 5}, 0, []int64{ids[3], ids[1], ids[0]}, 3},
+		{Filter{PriceTo: 1000, RoomsTo: 5, ShowWithFees: true}, 0, []int64{ids[3], ids[2], ids[1], ids[0]}, 4},
+		{Filter{PriceTo: 1000, RoomsTo: 5, ShowWithFees: true, MinFloor: 3}, 0, []int64{ids[2], ids[1]}, 2},
+		{Filter{PriceTo: 1000, RoomsTo: 5, ShowWithFees: true, MaxFloor: 3}, 0, []int64{ids[3], ids[2], ids[0]}, 3},
+		{Filter{PriceTo: 1000, RoomsTo: 5, ShowWithFees: true, NotLastFloor: true}, 0, []int64{ids[3], ids[0]}, 2},
+		{Filter{PriceTo: 1000, RoomsTo: 5, MaxArea: 40}, 0, []int64{ids[3], ids[0]}, 2},
+		{Filter{PriceTo: 1000, RoomsTo: 5, MaxPricePerM2: 10}, 0, []int64{ids[1], ids[0]}, 2},
+		{Filter{PriceTo: 1000, RoomsTo: 5, MaxPricePerM2: 10}, 1, []int64{ids[0]}, 2},
+		// Heating and districts are matched after SQL, posts without them pass
+		{Filter{PriceTo: 1000, RoomsTo: 5, Heating: []string{"centr"}}, 0, []int64{ids[3], ids[0]}, 2},
+		{Filter{PriceTo: 1000, RoomsTo: 5, Districts: []string{"zirmunai"}}, 0, []int64{ids[3], ids[1]}, 2},
+		{Filter{PriceTo: 1000, RoomsTo: 5, Districts: []string{"zirmunai"}}, 1, []int64{ids[1]}, 2},
+	}
+	for i, v := range data {
+		page, total := d.FindPosts(&PostQuery{Filter: v.Filter, Since: since, Offset: v.Offset, Limit: 10})
+		got := make([]int64, 0, len(page))
+		for _, p := range page {
+			got = append(got, p.ID)
+		}
+		if !reflect.DeepEqual(got, v.Expected) || total != v.Total {
+			t.Errorf("Result of case %d is incorrect, got: '%v' of %d, want: '%v' of %d.", i, got, total, v.Expected, v.Total)
+		}
+	}
+}
+
+func TestFindPostsChatRules(t *testing.T) {
+	d := openTest(t)
+	posts := []*website.Post{
+		{Link: "https://skelbiu.lt/1", Source: "skelbiu", City: "vilnius", Price: 300, Rooms: 1, Description: "Butas su balkonu"},
+		{Link: "https://aruodas.lt/2", Source: "aruodas", City: "vilnius", Price: 350, Rooms: 2, Description: "Butas su balkonu"},
+		{Link: "https://aruodas.lt/3", Source: "aruodas", City: "vilnius", Price: 400, Rooms: 2, Description: "Butas su terasa"},
+		{Link: "https://aruodas.lt/4", Source: "aruodas", City: "vilnius", Price: 450, Rooms: 2, Phone: "+37061234567", Description: "Butas su balkonu"},
+		{Link: "https://aruodas.lt/5", Source: "aruodas", City: "vilnius", Price: 500, Rooms: 2, Description: "Butas su balkonu, rūsys"},
+	}
+	ids := make([]int64, 0, len(posts))
+	for _, p := range posts {
+		ids = append(ids, d.AddPost(p))
+	}
+	d.EnsureUserInDB(1)
+	d.EnsureUserInDB(2)
+	d.EnsureUserInDB(3)
+	d.MuteSource(2, "skelbiu")
+	d.AddKeyword(3, "balkon", false)
+	d.AddKeyword(3, website.Normalize("rūsys"), true)
+	d.HideSimilar(3, posts[3])
+
+	filter := Filter{PriceTo: 1000, RoomsTo: 5}
+	since := time.Now().Add(-time.Hour)
+	var data = []struct {
+		Query    PostQuery
+		Expected []int64
+		Total    int
+	}{
+		{PostQuery{Filter: filter, Since: since, Limit: 10}, []int64{ids[4], ids[3], ids[2], ids[1], ids[0]}, 5},
+		{PostQuery{Filter: filter, TelegramID: 1, Since: since, Limit: 10}, []int64{ids[4], ids[3], ids[2], ids[1], ids[0]}, 5},
+		// Muted portal
+		{PostQuery{Filter: filter, TelegramID: 2, Since: since, Limit: 10}, []int64{ids[4], ids[3], ids[2], ids[1]}, 4},
+		{PostQuery{Filter: filter, TelegramID: 2, Since: since, Offset: 3, Limit: 10}, []int64{ids[1]}, 4},
+		// Included and excluded keywords and hidden phone
+		{PostQuery{Filter: filter, TelegramID: 3, Since: since, Limit: 10}, []int64{ids[1], ids[0]}, 2},
+		{PostQuery{Filter: filter, TelegramID: 3, Since: since, Offset: 1, Limit: 10}, []int64{ids[0]}, 2},
+	}
+	for i, v := range data {
+		page, total := d.FindPosts(&v.Query)
+		got := make([]int64, 0, len(page))
+		for _, p := range page {
+			got = append(got, p.ID)
+		}
+		if !reflect.DeepEqual(got, v.Expected) || total != v.Total {
+			t.Errorf("Result of case %d is incorrect, got: '%v' of %d, want: '%v' of %d.", i, got, total, v.Expected, v.Total)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package database
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// Search is a named filter of a chat. Chat can have many of them in addition
+// to its own config.
+type Search struct {
+	ID         int64
+	TelegramID int64
+	Name       string
+	Paused     bool
+	Filter
+}
+
+var searchColumns = "id, telegram_id, name, paused, " + filterColumns
+
+// querySearches returns searches selected by query, that returns
+// searchColumns. Heating and districts are loaded as well.
+func (d *Database) querySearches(query string, args ...interface{}) []*Search {
+	searches := make([]*Search, 0)
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var s Search
+		if err = rows.Scan(append([]interface{}{&s.ID, &s.TelegramID, &s.Name, &s.Paused}, s.scanDest()...)...); err != nil {
+			panic(err)
+		}
+		searches = append(searches, &s)
+	}
+	if err = rows.Err(); err != nil {
+		panic(err)
+	}
+
+	if len(searches) == 0 {
+		return searches
+	}
+	heating := d.stringsByID("SELECT search_id, heating FROM search_heating")
+	districts := d.stringsByID("SELECT search_id, district FROM search_districts")
+	for _, s := range searches {
+		s.Heating = heating[s.ID]
+		s.Districts = districts[s.ID]
+	}
+	return searches
+}
+
+// GetSearches returns all searches of the chat.
+func (d *Database) GetSearches(telegramID int64) []*Search {
+	return d.querySearches("SELECT "+searchColumns+" FROM searches WHERE telegram_id=? ORDER BY name", telegramID)
+}
+
+// GetSearch returns search of the chat with given name, or nil if there is no
+// such search.
+func (d *Database) GetSearch(telegramID int64, name string) *Search {
+	searches := d.querySearches("SELECT "+searchColumns+" FROM searches WHERE telegram_id=? AND name=?", telegramID, name)
+	if len(searches) == 0 {
+		return nil
+	}
+	return searches[0]
+}
+
+// AddSearch stores a new search and reports whether it was added. Search is
+// not added if chat already has a search with the same name.
+func (d *Database) AddSearch(s *Search) bool {
+	tx, err := d.db.Begin()
+	if err != nil {
+		panic(err)
+	}
+	defer tx.Rollback()
+
+	query := "INSERT OR IGNORE INTO searches(telegram_id, name, paused, " + filterColumns + ") VALUES(?, ?, ?" + strings.Repeat(", ?", len(s.values())) + ")"
+	res, err := tx.Exec(query, append([]interface{}{s.TelegramID, s.Name, s.Paused}, s.values()...)...)
+	if err != nil {
+		panic(err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		panic(err)
+	}
+	if count == 0 {
+		return false
+	}
+	if s.ID, err = res.LastInsertId(); err != nil {
+		panic(err)
+	}
+	replaceSearchLists(tx, s)
+	if err = tx.Commit(); err != nil {
+		panic(err)
+	}
+	return true
+}
+
+// UpdateSearch stores filter and paused state of the search.
+func (d *Database) UpdateSearch(s *Search) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		panic(err)
+	}
+	defer tx.Rollback()
+
+	query := "UPDATE searches SET paused=?, " + strings.ReplaceAll(filterColumns, ",", "=?,") + "=? WHERE id=?"
+	args := append([]interface{}{s.Paused}, s.values()...)
+	_, err = tx.Exec(query, append(args, s.ID)...)
+	if err != nil {
+		panic(err)
+	}
+	replaceSearchLists(tx, s)
+	if err = tx.Commit(); err != nil {
+		panic(err)
+	}
+}
+
+func replaceSearchLists(tx *sql.Tx, s *Search) {
+	replaceStrings(tx, "search_heating", "search_id", "heating", s.ID, s.Heating)
+	replaceStrings(tx, "search_districts", "search_id", "district", s.ID, s.Districts)
+}
+
+// DeleteSearch deletes search of the chat and reports whether it existed.
+func (d *Database) DeleteSearch(telegramID int64, name string) bool {
+	s := d.GetSearch(telegramID, name)
+	if s == nil {
+		return false
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		panic(err)
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"search_heating", "search_districts"} {
+		if _, err = tx.Exec("DELETE FROM "+table+" WHERE search_id=?", s.ID); err != nil {
+			panic(err)
+		}
+	}
+	if _, err = tx.Exec("DELETE FROM searches WHERE id=?", s.ID); err != nil {
+		panic(err)
+	}
+	if err = tx.Commit(); err != nil {
+		panic(err)
+	}
+	return true
+}
@@ -0,0 +1,64 @@
+package database
+
+import (
+	"bbtmvbot/website"
+	"reflect"
+	"testing"
+)
+
+func TestSearches(t *testing.T) {
+	d := openTest(t)
+	d.EnsureUserInDB(1)
+	d.UpdateUser(&User{TelegramID: 1, Filter: Filter{PriceFrom: 200, PriceTo: 300, RoomsFrom: 1, RoomsTo: 1}})
+
+	cheap := &Search{TelegramID: 1, Name: "cheap 1-room", Filter: Filter{PriceTo: 350, RoomsFrom: 1, RoomsTo: 1}}
+	parking := &Search{TelegramID: 1, Name: "2-room in Žirmūnai", Filter: Filter{PriceTo: 600, RoomsFrom: 2, RoomsTo: 2, Districts: []string{"zirmunai"}}}
+	if !d.AddSearch(cheap) || !d.AddSearch(parking) {
+		t.Fatal("Expected searches to be added.")
+	}
+	if d.AddSearch(&Search{TelegramID: 1, Name: "cheap 1-room"}) {
+		t.Errorf("Expected search with the same name not to be added.")
+	}
+
+	got := d.GetSearch(1, "2-room in Žirmūnai")
+	if got == nil || !reflect.DeepEqual(*got, *parking) {
+		t.Errorf("Result is incorrect, got: '%+v', want: '%+v'.", got, parking)
+	}
+
+	var data = []struct {
+		Post     website.Post
+		Expected []Match
+	}{
+		{website.Post{Price: 250, Rooms: 1}, []Match{{1, []string{"cheap 1-room"}}}},
+		{website.Post{Price: 340, Rooms: 1}, []Match{{1, []string{"cheap 1-room"}}}},
+		{website.Post{Price: 500, Rooms: 2, Address: "Vilnius, Žirmūnai, Kalvarijų g."}, []Match{{1, []string{"2-room in Žirmūnai"}}}},
+		{website.Post{Price: 500, Rooms: 2, Address: "Vilnius, Antakalnis, Antakalnio g."}, []Match{}},
+	}
+	for _, v := range data {
+		if got := d.GetInterested(&v.Post); !reflect.DeepEqual(got, v.Expected) {
+			t.Errorf("Result is incorrect for '%+v', got: '%+v', want: '%+v'.", v.Post, got, v.Expected)
+		}
+	}
+
+	// Post matching only chat's own config has no search names
+	cheap.Paused = true
+	d.UpdateSearch(cheap)
+	if got := d.GetInterested(&website.Post{Price: 250, Rooms: 1}); !reflect.DeepEqual(got, []Match{{1, []string{}}}) {
+		t.Errorf("Result is incorrect, got: '%+v', want: '[{1 []}]'.", got)
+	}
+	if d.AnyInterested(340, 1, "vilnius") {
+		t.Errorf("Expected paused search not to be interested.")
+	}
+
+	d.SetEnabled(1, false)
+	if got := d.GetInterested(&website.Post{Price: 500, Rooms: 2, Address: "Vilnius, Žirmūnai, Kalvarijų g."}); len(got) != 0 {
+		t.Errorf("Result is incorrect, got: '%+v', want: no matches for disabled chat.", got)
+	}
+
+	if !d.DeleteSearch(1, "cheap 1-room") || d.DeleteSearch(1, "cheap 1-room") {
+		t.Errorf("Expected search to be deleted only once.")
+	}
+	if searches := d.GetSearches(1); len(searches) != 1 || searches[0].Name != "2-room in Žirmūnai" {
+		t.Errorf("Result is incorrect, got: '%+v', want: only '2-room in Žirmūnai'.", searches)
+	}
+}
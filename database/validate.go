@@ -0,0 +1,129 @@
+package database
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limits of filter values
+const (
+	MaxPrice = 100000
+	MaxRooms = 100
+	MaxFloor = 100
+	MinYear  = 1900
+)
+
+// FieldError describes invalid value of a single filter field.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + " " + e.Message
+}
+
+// ValidationError contains errors of all invalid filter fields.
+type ValidationError []*FieldError
+
+func (v ValidationError) Error() string {
+	messages := make([]string, 0, len(v))
+	for _, e := range v {
+		messages = append(messages, e.Error())
+	}
+	return strings.Join(messages, "\n")
+}
+
+// Field returns error of the given field or nil if field is valid.
+func (v ValidationError) Field(name string) *FieldError {
+	for _, e := range v {
+		if e.Field == name {
+			return e
+		}
+	}
+	return nil
+}
+
+// Validate checks filter values and returns ValidationError with one error per
+// invalid field, or nil if filter is valid. Zero year, area, max floor and max
+// price per m² mean that filter is not applied.
+func (f *Filter) Validate() error {
+	var errs ValidationError
+	check := func(valid bool, field, message string) {
+		if !valid && errs.Field(field) == nil {
+			errs = append(errs, &FieldError{Field: field, Message: message})
+		}
+	}
+	between := func(min, max int) string {
+		return "must be between " + strconv.Itoa(min) + " and " + strconv.Itoa(max)
+	}
+
+	check(f.PriceFrom >= 0 && f.PriceFrom <= MaxPrice, "price_from", between(0, MaxPrice))
+	check(f.PriceTo >= 0 && f.PriceTo <= MaxPrice, "price_to", between(0, MaxPrice))
+	check(f.PriceTo >= f.PriceFrom, "price_to", "must be ≥ price_from")
+
+	check(f.RoomsFrom >= 0 && f.RoomsFrom <= MaxRooms, "rooms_from", between(0, MaxRooms))
+	check(f.RoomsTo >= 0 && f.RoomsTo <= MaxRooms, "rooms_to", between(0, MaxRooms))
+	check(f.RoomsTo >= f.RoomsFrom, "rooms_to", "must be ≥ rooms_from")
+
+	year := time.Now().Year()
+	check(f.YearFrom == 0 || f.YearFrom >= MinYear && f.YearFrom <= year, "year_from", between(MinYear, year))
+
+	check(f.MinFloor >= 0 && f.MinFloor <= MaxFloor, "min_floor", between(0, MaxFloor))
+	check(f.MaxFloor >= 0 && f.MaxFloor <= MaxFloor, "max_floor", between(0, MaxFloor))
+	check(f.MaxFloor == 0 || f.MaxFloor >= f.MinFloor, "max_floor", "must be ≥ min_floor")
+
+	check(f.MinArea >= 0, "min_area", "must not be negative")
+	check(f.MaxArea >= 0, "max_area", "must not be negative")
+	check(f.MaxArea == 0 || f.MaxArea >= f.MinArea, "max_area", "must be ≥ min_area")
+
+	check(f.MaxPricePerM2 >= 0, "max_price_per_m2", "must not be negative")
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// configFields are names of values in the order /config command expects them.
+var configFields = []string{"price_from", "price_to", "rooms_from", "rooms_to", "year_from", "min_floor", "show_with_fee"}
+
+// ParseConfig parses space separated config values in the same format as
+// /config command expects them and validates the result.
+func ParseConfig(values string) (*Filter, error) {
+	fields := strings.Fields(strings.ToLower(values))
+	if len(fields) != len(configFields) {
+		return nil, ValidationError{{Field: "config", Message: "must have " + strconv.Itoa(len(configFields)) + " values"}}
+	}
+
+	f := &Filter{}
+	var errs ValidationError
+	for i, dest := range []*int{&f.PriceFrom, &f.PriceTo, &f.RoomsFrom, &f.RoomsTo, &f.YearFrom, &f.MinFloor} {
+		n, err := strconv.Atoi(fields[i])
+		if err != nil {
+			errs = append(errs, &FieldError{Field: configFields[i], Message: "must be a number"})
+		}
+		*dest = n
+	}
+	switch fields[6] {
+	case "yes":
+		f.ShowWithFees = true
+	case "no":
+	default:
+		errs = append(errs, &FieldError{Field: configFields[6], Message: "must be yes or no"})
+	}
+
+	if err := f.Validate(); err != nil {
+		// Fields that are not numbers are already reported
+		for _, e := range err.(ValidationError) {
+			if errs.Field(e.Field) == nil {
+				errs = append(errs, e)
+			}
+		}
+	}
+	if len(errs) != 0 {
+		return nil, errs
+	}
+	return f, nil
+}
@@ -0,0 +1,107 @@
+package database
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	valid := Filter{PriceFrom: 200, PriceTo: 330, RoomsFrom: 1, RoomsTo: 2, YearFrom: 2000, MinFloor: 2}
+	year := time.Now().Year()
+
+	var data = []struct {
+		Modify   func(f *Filter)
+		Expected []string
+	}{
+		{func(f *Filter) {}, nil},
+		{func(f *Filter) { *f = Filter{} }, nil},
+		{func(f *Filter) { f.YearFrom = 0 }, nil},
+		{func(f *Filter) { f.YearFrom = year }, nil},
+		{func(f *Filter) { f.PriceFrom, f.PriceTo = 330, 330 }, nil},
+		{func(f *Filter) { f.MinArea, f.MaxArea, f.MaxFloor, f.MaxPricePerM2 = 30, 60, 5, 15.5 }, nil},
+		{func(f *Filter) { f.MinArea = 30 }, nil},
+		{func(f *Filter) { f.PriceFrom = -1 }, []string{"price_from must be between 0 and 100000"}},
+		{func(f *Filter) { f.PriceTo = 100001 }, []string{"price_to must be between 0 and 100000"}},
+		{func(f *Filter) { f.PriceTo = 100 }, []string{"price_to must be ≥ price_from"}},
+		{func(f *Filter) { f.RoomsFrom = -1 }, []string{"rooms_from must be between 0 and 100"}},
+		{func(f *Filter) { f.RoomsTo = 101 }, []string{"rooms_to must be between 0 and 100"}},
+		{func(f *Filter) { f.RoomsFrom = 3 }, []string{"rooms_to must be ≥ rooms_from"}},
+		{func(f *Filter) { f.YearFrom = 1899 }, []string{"year_from must be between 1900 and " + strconv.Itoa(year)}},
+		{func(f *Filter) { f.YearFrom = year + 1 }, []string{"year_from must be between 1900 and " + strconv.Itoa(year)}},
+		{func(f *Filter) { f.MinFloor = 101 }, []string{"min_floor must be between 0 and 100"}},
+		{func(f *Filter) { f.MaxFloor = 101 }, []string{"max_floor must be between 0 and 100"}},
+		{func(f *Filter) { f.MaxFloor = 1 }, []string{"max_floor must be ≥ min_floor"}},
+		{func(f *Filter) { f.MinArea = -1 }, []string{"min_area must not be negative"}},
+		{func(f *Filter) { f.MaxArea = -1 }, []string{"max_area must not be negative"}},
+		{func(f *Filter) { f.MinArea, f.MaxArea = 60, 30 }, []string{"max_area must be ≥ min_area"}},
+		{func(f *Filter) { f.MaxPricePerM2 = -0.5 }, []string{"max_price_per_m2 must not be negative"}},
+		{func(f *Filter) { f.PriceFrom, f.PriceTo, f.RoomsFrom, f.YearFrom = 500, 100, 5, 1800 }, []string{
+			"price_to must be ≥ price_from",
+			"rooms_to must be ≥ rooms_from",
+			"year_from must be between 1900 and " + strconv.Itoa(year),
+		}},
+		// Only first error of a field is reported
+		{func(f *Filter) { f.PriceTo = -1 }, []string{"price_to must be between 0 and 100000"}},
+	}
+
+	for i, v := range data {
+		f := valid
+		v.Modify(&f)
+		var got []string
+		if err := f.Validate(); err != nil {
+			for _, e := range err.(ValidationError) {
+				got = append(got, e.Error())
+			}
+		}
+		if !reflect.DeepEqual(got, v.Expected) {
+			t.Errorf("Result of case %d is incorrect, got: '%v', want: '%v'.", i, got, v.Expected)
+		}
+	}
+}
+
+func TestValidationErrorField(t *testing.T) {
+	f := Filter{PriceFrom: 500, PriceTo: 100}
+	err := f.Validate().(ValidationError)
+	if e := err.Field("price_to"); e == nil || e.Message != "must be ≥ price_from" {
+		t.Errorf("Result is incorrect, got: '%v', want: 'price_to must be ≥ price_from'.", e)
+	}
+	if e := err.Field("price_from"); e != nil {
+		t.Errorf("Result is incorrect, got: '%v', want: '<nil>'.", e)
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	var data = []struct {
+		Values   string
+		Filter   *Filter
+		Expected []string
+	}{
+		{"200 330 1 2 2000 2 yes", &Filter{PriceFrom: 200, PriceTo: 330, RoomsFrom: 1, RoomsTo: 2, YearFrom: 2000, MinFloor: 2, ShowWithFees: true}, nil},
+		{"0 500 1 3 0 0 NO", &Filter{PriceTo: 500, RoomsFrom: 1, RoomsTo: 3}, nil},
+		{"200 330 1 2 2000 2", nil, []string{"config must have 7 values"}},
+		{"200 330 1 2 2000 2 yes 1", nil, []string{"config must have 7 values"}},
+		{"abc 330 1 2 2000 2 maybe", nil, []string{"price_from must be a number", "show_with_fee must be yes or no"}},
+		{"500 330 3 2 1800 2 yes", nil, []string{
+			"price_to must be ≥ price_from",
+			"rooms_to must be ≥ rooms_from",
+			"year_from must be between 1900 and " + strconv.Itoa(time.Now().Year()),
+		}},
+		// Fields that are not numbers are not validated again
+		{"200 x 1 2 2000 2 yes", nil, []string{"price_to must be a number"}},
+	}
+
+	for _, v := range data {
+		f, err := ParseConfig(v.Values)
+		var got []string
+		if err != nil {
+			for _, e := range err.(ValidationError) {
+				got = append(got, e.Error())
+			}
+		}
+		if !reflect.DeepEqual(f, v.Filter) || !reflect.DeepEqual(got, v.Expected) {
+			t.Errorf("Result is incorrect for '%s', got: '%+v' '%v', want: '%+v' '%v'.", v.Values, f, got, v.Filter, v.Expected)
+		}
+	}
+}
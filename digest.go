@@ -0,0 +1,138 @@
+package bbtmvbot
+
+import (
+	"bbtmvbot/database"
+	"bbtmvbot/website"
+	"fmt"
+	"strings"
+	"time"
+
+	telebot "gopkg.in/tucnak/telebot.v2"
+)
+
+const deliveryText = "Use this format:\n\n```\n/delivery instant\n/delivery hourly\n/delivery daily <HH:MM>\n```\nIn hourly and daily modes posts are sent as a single digest message. Daily digest time is in Vilnius time.\n\nExample:\n```\n/delivery daily 08:30\n```"
+
+func handleCommandDelivery(m *telebot.Message) {
+	fields := strings.Fields(strings.ToLower(m.Text))
+	if len(fields) < 2 {
+		sendTelegram(m.Chat.ID, deliveryText+"\n\nCurrent delivery: "+formatDelivery(db.GetDelivery(m.Chat.ID)))
+		return
+	}
+
+	at := 0
+	switch {
+	case len(fields) == 2 && (fields[1] == database.DeliveryInstant || fields[1] == database.DeliveryHourly):
+	case len(fields) == 3 && fields[1] == database.DeliveryDaily:
+		t, err := time.Parse("15:04", fields[2])
+		if err != nil {
+			sendTelegram(m.Chat.ID, "Wrong input! Time must be in HH:MM format.\n\n"+deliveryText)
+			return
+		}
+		at = t.Hour()*60 + t.Minute()
+	default:
+		sendTelegram(m.Chat.ID, "Wrong input! "+deliveryText)
+		return
+	}
+
+	db.SetDelivery(m.Chat.ID, fields[1], at)
+	sendTelegram(m.Chat.ID, "Delivery updated to "+formatDelivery(db.GetDelivery(m.Chat.ID))+"!")
+}
+
+func formatDelivery(dl *database.Delivery) string {
+	if dl.Mode == database.DeliveryDaily {
+		return fmt.Sprintf("daily at %02d:%02d", dl.At/60, dl.At%60)
+	}
+	return dl.Mode
+}
+
+// sendDigests sends queued posts to users whose digest is due, including
+// posts held during quiet hours that just ended.
+func sendDigests() {
+	now := time.Now().In(location)
+	for telegramID, delivery := range db.PendingDigests() {
+		if !delivery.Due(now) {
+			continue
+		}
+		for _, msg := range formatDigest(db.TakeDigest(telegramID)) {
+			queueTelegram(telegramID, msg, delivery.Silent(now), 0)
+		}
+	}
+}
+
+const quietText = "Use this format:\n\n```\n/quiet <from HH:MM> <to HH:MM> [hold/silent]\n/quiet off\n```\nDuring quiet hours posts are held and sent as a single message when quiet hours end (`hold`, default), or sent without sound (`silent`). Time is in Vilnius time.\n\nExample:\n```\n/quiet 23:00 07:30\n```"
+
+func handleCommandQuiet(m *telebot.Message) {
+	fields := strings.Fields(strings.ToLower(m.Text))
+	if len(fields) < 2 {
+		sendTelegram(m.Chat.ID, quietText+"\n\nCurrent quiet hours: "+formatQuiet(db.GetDelivery(m.Chat.ID)))
+		return
+	}
+	if len(fields) == 2 && fields[1] == "off" {
+		db.SetQuiet(m.Chat.ID, database.QuietOff, 0, 0)
+		sendTelegram(m.Chat.ID, "Quiet hours turned off!")
+		return
+	}
+	if len(fields) != 3 && len(fields) != 4 {
+		sendTelegram(m.Chat.ID, "Wrong input! "+quietText)
+		return
+	}
+
+	from, errFrom := time.Parse("15:04", fields[1])
+	to, errTo := time.Parse("15:04", fields[2])
+	if errFrom != nil || errTo != nil || from.Equal(to) {
+		sendTelegram(m.Chat.ID, "Wrong input! Quiet hours must be two different times in HH:MM format.\n\n"+quietText)
+		return
+	}
+	mode := database.QuietHold
+	if len(fields) == 4 {
+		mode = fields[3]
+		if mode != database.QuietHold && mode != database.QuietSilent {
+			sendTelegram(m.Chat.ID, "Wrong input! Mode must be hold or silent.\n\n"+quietText)
+			return
+		}
+	}
+
+	db.SetQuiet(m.Chat.ID, mode, from.Hour()*60+from.Minute(), to.Hour()*60+to.Minute())
+	sendTelegram(m.Chat.ID, "Quiet hours set to "+formatQuiet(db.GetDelivery(m.Chat.ID))+"!")
+}
+
+func formatQuiet(dl *database.Delivery) string {
+	if dl.QuietMode == database.QuietOff {
+		return "off"
+	}
+	return fmt.Sprintf("%02d:%02d-%02d:%02d (%s)", dl.QuietFrom/60, dl.QuietFrom%60, dl.QuietTo/60, dl.QuietTo%60, dl.QuietMode)
+}
+
+// Telegram does not allow longer messages
+const maxMessageLength = 4096
+
+// formatDigest formats queued posts as one or more messages, each fitting
+// into a single Telegram message. Digest has no post buttons, so every post
+// links to /post command showing it with buttons.
+func formatDigest(posts []*database.QueuedPost) []string {
+	messages := make([]string, 0, 1)
+	if len(posts) == 0 {
+		return messages
+	}
+
+	header := fmt.Sprintf("📬 *Digest: %d new posts*\nTap /post link of a post to save, hide or mute it.\n\n", len(posts))
+	var sb strings.Builder
+	sb.WriteString(header)
+	for _, p := range posts {
+		line := strings.TrimSuffix(p.FormatDigestLine(p.ID), "\n") + " " + postLink(p.ID)
+		if p.Searches != "" {
+			line += " 🔎 _" + p.Searches + "_"
+		}
+		alsoOn := make([]*website.Post, 0, len(p.Duplicates))
+		for _, d := range p.Duplicates {
+			alsoOn = append(alsoOn, &d.Post)
+		}
+		line += "\n" + website.FormatAlsoOn(alsoOn)
+		if len(sb.String())+len(line) > maxMessageLength {
+			messages = append(messages, sb.String())
+			sb.Reset()
+		}
+		sb.WriteString(line)
+	}
+	return append(messages, sb.String())
+}
@@ -0,0 +1,62 @@
+package bbtmvbot
+
+import (
+	"bbtmvbot/database"
+	"bbtmvbot/website"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFormatDigest(t *testing.T) {
+	posts := []*database.QueuedPost{
+		{
+			Post:       &database.Post{ID: 7, Post: website.Post{Link: "https://a.lt/7", Address: "Vilnius, Žirmūnai", Price: 300, Rooms: 2}},
+			Searches:   "cheap",
+			Duplicates: []*database.Post{{ID: 9, Post: website.Post{Link: "https://b.lt/9", Source: "skelbiu"}}},
+		},
+		{Post: &database.Post{ID: 8, Post: website.Post{Link: "https://a.lt/8", Source: "alio", Price: 400}}},
+	}
+	expected := []string{"📬 *Digest: 2 new posts*\nTap /post link of a post to save, hide or mute it.\n\n" +
+		"7. [Vilnius, Žirmūnai](https://a.lt/7) `300€, 2 rooms` /post\\_7 🔎 _cheap_\n» *Also on:* [skelbiu](https://b.lt/9)\n" +
+		"8. [alio](https://a.lt/8) `400€` /post\\_8\n"}
+	if got := formatDigest(posts); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Result is incorrect, got: '%v', want: '%v'.", got, expected)
+	}
+
+	// Long digest is split into several messages
+	long := make([]*database.QueuedPost, 0, 100)
+	for i := 0; i < 100; i++ {
+		long = append(long, &database.QueuedPost{Post: &database.Post{ID: int64(i), Post: website.Post{Link: "https://a.lt/" + strings.Repeat("x", 50), Price: 300}}})
+	}
+	messages := formatDigest(long)
+	for _, m := range messages {
+		if len(m) > maxMessageLength {
+			t.Errorf("Result is incorrect, got message of %d bytes, want at most %d.", len(m), maxMessageLength)
+		}
+	}
+	if len(messages) < 2 {
+		t.Errorf("Result is incorrect, got: %d messages, want: at least 2.", len(messages))
+	}
+}
+
+func TestLinkedPostID(t *testing.T) {
+	var data = []struct {
+		Text     string
+		Expected int64
+		OK       bool
+	}{
+		{"/post_12", 12, true},
+		{"/post_12@bbtmvbot", 12, true},
+		{"/post_0", 0, false},
+		{"/post_abc", 0, false},
+		{"/post 12", 0, false},
+		{"post_12", 0, false},
+	}
+	for _, v := range data {
+		got, ok := linkedPostID(v.Text)
+		if got != v.Expected || ok != v.OK {
+			t.Errorf("Result of '%s' is incorrect, got: '%d %t', want: '%d %t'.", v.Text, got, ok, v.Expected, v.OK)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package notifier
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// stubLookup resolves host names without DNS until the end of the test.
+func stubLookup(t *testing.T) {
+	hosts := map[string]string{
+		"example.com":      "93.184.216.34",
+		"discord.com":      "162.159.135.232",
+		"localhost":        "127.0.0.1",
+		"metadata.local":   "169.254.169.254",
+		"intranet.local":   "10.1.2.3",
+		"ipv6.example.com": "fd00::1",
+	}
+	lookupIP = func(host string) ([]net.IP, error) {
+		if ip, found := hosts[host]; found {
+			return []net.IP{net.ParseIP(ip)}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+	t.Cleanup(func() { lookupIP = net.LookupIP })
+}
+
+func TestCheckHost(t *testing.T) {
+	stubLookup(t)
+
+	var data = []struct {
+		Host  string
+		Valid bool
+	}{
+		{"example.com", true},
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"localhost", false},
+		{"127.0.0.1", false},
+		{"0.0.0.0", false},
+		{"169.254.169.254", false},
+		{"metadata.local", false},
+		{"10.0.0.1", false},
+		{"172.20.0.1", false},
+		{"192.168.1.1", false},
+		{"intranet.local", false},
+		{"::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"fe80::1", false},
+		{"ipv6.example.com", false},
+		{"unknown.example.com", false},
+	}
+	for _, v := range data {
+		if got := checkHost(v.Host) == nil; got != v.Valid {
+			t.Errorf("Result of '%s' is incorrect, got: '%t', want: '%t'.", v.Host, got, v.Valid)
+		}
+	}
+}
+
+func TestPublicClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Request to internal address was not refused.")
+	}))
+	defer server.Close()
+
+	// Host of the test server is loopback, so request must not reach it
+	w := &Webhook{Client: NewPublicClient(time.Second)}
+	_, err := w.Send(server.URL, &Message{Text: "text"})
+	var permanent *PermanentError
+	if !errors.Is(err, ErrForbiddenAddress) || !errors.As(err, &permanent) {
+		t.Errorf("Result is incorrect, got: '%v', want: permanent forbidden address error.", err)
+	}
+}
@@ -0,0 +1,68 @@
+package notifier
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Discord sends messages to Discord channels using webhooks. Target is
+// webhook URL.
+type Discord struct {
+	Client *http.Client
+}
+
+// Discord limits length of embed description
+const discordMaxDescription = 4096
+
+// Discord message flag, which sends message without a notification
+const discordSuppressNotifications = 1 << 12
+
+type discordMessage struct {
+	Embeds []*discordEmbed `json:"embeds"`
+	Flags  int             `json:"flags,omitempty"`
+}
+
+type discordEmbed struct {
+	Title       string `json:"title,omitempty"`
+	URL         string `json:"url,omitempty"`
+	Description string `json:"description"`
+}
+
+func (d *Discord) ValidateTarget(target string) error {
+	if err := validateURL(target); err != nil {
+		return err
+	}
+	u, _ := url.Parse(target)
+	if u.Scheme != "https" || !strings.HasPrefix(u.Path, "/api/webhooks/") {
+		return errors.New("URL must be a Discord webhook URL, e.g. https://discord.com/api/webhooks/...")
+	}
+	return nil
+}
+
+func (d *Discord) Send(target string, m *Message) (int, error) {
+	embed := &discordEmbed{Description: truncate(DiscordMarkdown(m.Text), discordMaxDescription)}
+	if m.Post != nil {
+		embed.Title = fmt.Sprintf("Post #%d", m.PostID)
+		if m.Post.Address != "" {
+			embed.Title += ": " + m.Post.Address
+		}
+		embed.URL = m.Post.Link
+	}
+	msg := &discordMessage{Embeds: []*discordEmbed{embed}}
+	if m.Silent {
+		msg.Flags = discordSuppressNotifications
+	}
+	return 0, postJSON(d.Client, target, msg, nil)
+}
+
+// truncate shortens text to the given count of characters.
+func truncate(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	return string(runes[:max-1]) + "…"
+}
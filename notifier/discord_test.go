@@ -0,0 +1,106 @@
+package notifier
+
+import (
+	"bbtmvbot/website"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiscordValidateTarget(t *testing.T) {
+	var data = []struct {
+		Target string
+		Valid  bool
+	}{
+		{"https://discord.com/api/webhooks/123/token", true},
+		{"http://discord.com/api/webhooks/123/token", false},
+		{"https://example.com/hook", false},
+	}
+	stubLookup(t)
+	d := &Discord{}
+	for _, v := range data {
+		if got := d.ValidateTarget(v.Target) == nil; got != v.Valid {
+			t.Errorf("Result of '%s' is incorrect, got: '%t', want: '%t'.", v.Target, got, v.Valid)
+		}
+	}
+}
+
+func TestDiscordSend(t *testing.T) {
+	var got discordMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/webhooks/123/token" {
+			t.Errorf("Result is incorrect, got: '%s %s', want: 'POST /api/webhooks/123/token'.", r.Method, r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	post := &website.Post{
+		Link:       "https://example.com/1",
+		Phone:      "+37060000000",
+		Address:    "Vilnius, Antakalnis, Žirmūnų g.",
+		Price:      300,
+		Area:       50,
+		Rooms:      2,
+		Year:       2000,
+		Heating:    "centrinis",
+		Floor:      2,
+		FloorTotal: 5,
+	}
+	m := &Message{ID: 1, Text: post.FormatTelegramMessage(5), Silent: true, PostID: 5, Post: post}
+	if _, err := (&Discord{}).Send(server.URL+"/api/webhooks/123/token", m); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "5. https://example.com/1\n" +
+		"» **Phone number:** [+37060000000](tel:+37060000000)\n" +
+		"» **Address:** [Vilnius, Antakalnis, Žirmūnų g.](https://maps.google.com/?q=Vilnius%2C+Antakalnis%2C+%C5%BDirm%C5%ABn%C5%B3+g.)\n" +
+		"» **Price:** `300€ (6.00€/m²)`\n" +
+		"» **Rooms:** `2 (50m²)`\n" +
+		"» **Contruction year:** `2000`\n" +
+		"» **Heating type:** `centrinis`\n" +
+		"» **Floor:** `2/5`\n" +
+		"» **With fee:** no\n"
+	if len(got.Embeds) != 1 || got.Embeds[0].Description != expected {
+		t.Fatalf("Result is incorrect, got: '%+v', want description: '%s'.", got.Embeds, expected)
+	}
+	if e := got.Embeds[0]; e.Title != "Post #5: Vilnius, Antakalnis, Žirmūnų g." || e.URL != post.Link || got.Flags != discordSuppressNotifications {
+		t.Errorf("Result is incorrect, got: '%+v', want: silent post 5 embed.", got)
+	}
+}
+
+func TestDiscordSendLong(t *testing.T) {
+	var got discordMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer server.Close()
+
+	if _, err := (&Discord{}).Send(server.URL, &Message{Text: strings.Repeat("ą", 5000)}); err != nil {
+		t.Fatal(err)
+	}
+	if n := len([]rune(got.Embeds[0].Description)); n != discordMaxDescription || got.Flags != 0 {
+		t.Errorf("Result is incorrect, got: '%d' characters, want: '%d'.", n, discordMaxDescription)
+	}
+}
+
+func TestDiscordSendRateLimited(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "1.5")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	_, err := (&Discord{}).Send(server.URL, &Message{Text: "text"})
+	var retry *RetryAfterError
+	if !errors.As(err, &retry) || retry.After != 1500*time.Millisecond {
+		t.Errorf("Result is incorrect, got: '%v', want: retry after 1.5s.", err)
+	}
+}
@@ -0,0 +1,84 @@
+package notifier
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Email sends messages as plain text emails via SMTP. Target is email address.
+type Email struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+
+	// SendMail sends the email, defaults to smtp.SendMail
+	SendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+func (e *Email) ValidateTarget(target string) error {
+	addr, err := mail.ParseAddress(target)
+	if err != nil {
+		return err
+	}
+	if addr.Address != target {
+		return errors.New("only email address is allowed")
+	}
+	return nil
+}
+
+func (e *Email) Send(target string, m *Message) (int, error) {
+	if err := e.ValidateTarget(target); err != nil {
+		return 0, &PermanentError{err}
+	}
+
+	var auth smtp.Auth
+	if e.Username != "" {
+		auth = smtp.PlainAuth("", e.Username, e.Password, e.Host)
+	}
+	sendMail := e.SendMail
+	if sendMail == nil {
+		sendMail = smtp.SendMail
+	}
+	addr := net.JoinHostPort(e.Host, strconv.Itoa(e.Port))
+	err := sendMail(addr, auth, e.From, []string{target}, e.format(target, m))
+
+	// 4xx replies are temporary, 5xx are permanent
+	var smtpErr *textproto.Error
+	if errors.As(err, &smtpErr) && smtpErr.Code >= 500 {
+		return 0, &PermanentError{err}
+	}
+	return 0, err
+}
+
+// format returns email with headers.
+func (e *Email) format(to string, m *Message) []byte {
+	subject := "New notification"
+	if m.PostID != 0 {
+		subject = fmt.Sprintf("Post #%d", m.PostID)
+		if m.Post != nil && m.Post.Address != "" {
+			subject += ": " + m.Post.Address
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("From: " + e.From + "\r\n")
+	sb.WriteString("To: " + to + "\r\n")
+	sb.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n")
+	sb.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	sb.WriteString("MIME-Version: 1.0\r\n")
+	sb.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	sb.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	sb.WriteString("\r\n")
+	sb.WriteString(strings.ReplaceAll(PlainText(m.Text), "\n", "\r\n"))
+	return []byte(sb.String())
+}
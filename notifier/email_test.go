@@ -0,0 +1,82 @@
+package notifier
+
+import (
+	"bbtmvbot/website"
+	"errors"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+func TestEmailValidateTarget(t *testing.T) {
+	var data = []struct {
+		Target string
+		Valid  bool
+	}{
+		{"user@example.com", true},
+		{"User <user@example.com>", false},
+		{"user", false},
+		{"", false},
+	}
+	e := &Email{}
+	for _, v := range data {
+		if got := e.ValidateTarget(v.Target) == nil; got != v.Valid {
+			t.Errorf("Result of '%s' is incorrect, got: '%t', want: '%t'.", v.Target, got, v.Valid)
+		}
+	}
+}
+
+func TestEmailSend(t *testing.T) {
+	var gotAddr, gotFrom string
+	var gotTo []string
+	var gotMsg []byte
+	e := &Email{
+		Host: "smtp.example.com",
+		Port: 587,
+		From: "bot@example.com",
+		SendMail: func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+			gotAddr, gotFrom, gotTo, gotMsg = addr, from, to, msg
+			return nil
+		},
+	}
+
+	post := &website.Post{Link: "https://example.com/1", Address: "Vilnius, Antakalnis, Žirmūnų g.", Price: 300}
+	m := &Message{Text: post.FormatTelegramMessage(5), PostID: 5, Post: post}
+	if _, err := e.Send("user@example.com", m); err != nil {
+		t.Fatal(err)
+	}
+	if gotAddr != "smtp.example.com:587" || gotFrom != "bot@example.com" || len(gotTo) != 1 || gotTo[0] != "user@example.com" {
+		t.Errorf("Result is incorrect, got: '%s %s %v', want: 'smtp.example.com:587 bot@example.com [user@example.com]'.", gotAddr, gotFrom, gotTo)
+	}
+	msg := string(gotMsg)
+	for _, expected := range []string{
+		"To: user@example.com\r\n",
+		"Subject: =?utf-8?q?Post_#5:_Vilnius,_Antakalnis,_=C5=BDirm=C5=ABn=C5=B3_g.?=\r\n",
+		"\r\n\r\n5. https://example.com/1\r\n",
+		"» Price: 300€\r\n",
+	} {
+		if !strings.Contains(msg, expected) {
+			t.Errorf("Result is incorrect, got: '%s', want it to contain: '%s'.", msg, expected)
+		}
+	}
+}
+
+func TestEmailSendError(t *testing.T) {
+	var data = []struct {
+		Err       error
+		Permanent bool
+	}{
+		{&textproto.Error{Code: 550, Msg: "mailbox unavailable"}, true},
+		{&textproto.Error{Code: 451, Msg: "try again later"}, false},
+		{errors.New("connection refused"), false},
+	}
+	for _, v := range data {
+		e := &Email{SendMail: func(string, smtp.Auth, string, []string, []byte) error { return v.Err }}
+		_, err := e.Send("user@example.com", &Message{Text: "text"})
+		var permanent *PermanentError
+		if err == nil || errors.As(err, &permanent) != v.Permanent {
+			t.Errorf("Result of '%s' is incorrect, got: '%v', want permanent: '%t'.", v.Err, err, v.Permanent)
+		}
+	}
+}
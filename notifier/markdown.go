@@ -0,0 +1,115 @@
+package notifier
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// segment is a part of Telegram Markdown text with the same formatting.
+type segment struct {
+	Text string
+	Bold bool
+	Code bool
+	// URL is set if segment is a link
+	URL string
+}
+
+var reMarkdownLink = regexp.MustCompile(`^\[([^\]]*)\]\(([^)]*)\)`)
+
+// parseMarkdown splits Telegram Markdown into segments. Only bold, code and
+// links are supported, as other formatting is not used in messages.
+func parseMarkdown(markdown string) []segment {
+	var segments []segment
+	var text strings.Builder
+	bold := false
+	flush := func() {
+		if text.Len() > 0 {
+			segments = append(segments, segment{Text: text.String(), Bold: bold})
+			text.Reset()
+		}
+	}
+
+	for i := 0; i < len(markdown); i++ {
+		switch markdown[i] {
+		case '*':
+			flush()
+			bold = !bold
+			continue
+		case '`':
+			if end := strings.IndexByte(markdown[i+1:], '`'); end >= 0 {
+				flush()
+				segments = append(segments, segment{Text: markdown[i+1 : i+1+end], Bold: bold, Code: true})
+				i += end + 1
+				continue
+			}
+		case '[':
+			if m := reMarkdownLink.FindStringSubmatch(markdown[i:]); m != nil {
+				flush()
+				linkText := strings.NewReplacer("*", "", "`", "").Replace(m[1])
+				segments = append(segments, segment{Text: linkText, Bold: bold, URL: m[2]})
+				i += len(m[0]) - 1
+				continue
+			}
+		}
+		text.WriteByte(markdown[i])
+	}
+	flush()
+	return segments
+}
+
+// PlainText converts Telegram Markdown to plain text. Links are written as
+// "text (URL)", unless text is the URL itself.
+func PlainText(markdown string) string {
+	var sb strings.Builder
+	for _, s := range parseMarkdown(markdown) {
+		sb.WriteString(s.Text)
+		if s.URL != "" && s.URL != s.Text {
+			sb.WriteString(" (" + s.URL + ")")
+		}
+	}
+	return sb.String()
+}
+
+// DiscordMarkdown converts Telegram Markdown to Discord Markdown.
+func DiscordMarkdown(markdown string) string {
+	var sb strings.Builder
+	for _, s := range parseMarkdown(markdown) {
+		text := s.Text
+		switch {
+		case s.Code:
+			text = "`" + text + "`"
+		case s.URL != "":
+			text = "[" + text + "](" + s.URL + ")"
+		}
+		if s.Bold {
+			text = "**" + text + "**"
+		}
+		sb.WriteString(text)
+	}
+	return sb.String()
+}
+
+var reURL = regexp.MustCompile(`https?://[^\s<>"]+`)
+
+// HTML converts Telegram Markdown to HTML. Plain URLs are turned into links
+// too.
+func HTML(markdown string) string {
+	var sb strings.Builder
+	for _, s := range parseMarkdown(markdown) {
+		text := html.EscapeString(s.Text)
+		switch {
+		case s.Code:
+			text = "<code>" + text + "</code>"
+		case s.URL != "":
+			text = `<a href="` + html.EscapeString(s.URL) + `">` + text + "</a>"
+		default:
+			text = reURL.ReplaceAllString(text, `<a href="$0">$0</a>`)
+		}
+		if s.Bold {
+			text = "<b>" + text + "</b>"
+		}
+		sb.WriteString(strings.ReplaceAll(text, "\n", "<br>\n"))
+	}
+	return sb.String()
+}
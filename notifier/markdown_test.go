@@ -0,0 +1,54 @@
+package notifier
+
+import "testing"
+
+func TestPlainText(t *testing.T) {
+	var data = []struct {
+		Markdown string
+		Expected string
+	}{
+		{"*Bold* and `code`", "Bold and code"},
+		{"1. https://example.com/1\n", "1. https://example.com/1\n"},
+		{"» *Phone number:* [+37060000000](tel:+37060000000)", "» Phone number: +37060000000 (tel:+37060000000)"},
+		{"[https://example.com](https://example.com)", "https://example.com"},
+		{"[*a*](b) [c](d)", "a (b) c (d)"},
+	}
+	for _, v := range data {
+		if got := PlainText(v.Markdown); got != v.Expected {
+			t.Errorf("Result is incorrect, got: '%s', want: '%s'.", got, v.Expected)
+		}
+	}
+}
+
+func TestDiscordMarkdown(t *testing.T) {
+	var data = []struct {
+		Markdown string
+		Expected string
+	}{
+		{"*Bold* and `code`", "**Bold** and `code`"},
+		{"» *Phone number:* [+37060000000](tel:+37060000000)\n", "» **Phone number:** [+37060000000](tel:+37060000000)\n"},
+		{"📬 *Digest: 2 new posts*\n1. [a](b) `300€`", "📬 **Digest: 2 new posts**\n1. [a](b) `300€`"},
+		{"no [link] here", "no [link] here"},
+	}
+	for _, v := range data {
+		if got := DiscordMarkdown(v.Markdown); got != v.Expected {
+			t.Errorf("Result is incorrect, got: '%s', want: '%s'.", got, v.Expected)
+		}
+	}
+}
+
+func TestHTML(t *testing.T) {
+	var data = []struct {
+		Markdown string
+		Expected string
+	}{
+		{"*Bold* and `<code>`", "<b>Bold</b> and <code>&lt;code&gt;</code>"},
+		{"1. https://example.com/?a=1&b=2\n", "1. <a href=\"https://example.com/?a=1&amp;b=2\">https://example.com/?a=1&amp;b=2</a><br>\n"},
+		{"[A & B](https://example.com/?q=A+%26+B)", "<a href=\"https://example.com/?q=A+%26+B\">A &amp; B</a>"},
+	}
+	for _, v := range data {
+		if got := HTML(v.Markdown); got != v.Expected {
+			t.Errorf("Result is incorrect, got: '%s', want: '%s'.", got, v.Expected)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Matrix sends messages to Matrix rooms using client-server API. Target is
+// room ID, e.g. "!abcdef:matrix.org". Bot user must be joined to the room.
+type Matrix struct {
+	Client *http.Client
+	// Homeserver is base URL of the homeserver, e.g. "https://matrix.org"
+	Homeserver  string
+	AccessToken string
+}
+
+type matrixMessage struct {
+	MsgType       string `json:"msgtype"`
+	Body          string `json:"body"`
+	Format        string `json:"format"`
+	FormattedBody string `json:"formatted_body"`
+}
+
+type matrixError struct {
+	ErrCode      string `json:"errcode"`
+	Error        string `json:"error"`
+	RetryAfterMS int64  `json:"retry_after_ms"`
+}
+
+func (mx *Matrix) ValidateTarget(target string) error {
+	if !strings.HasPrefix(target, "!") || !strings.Contains(target, ":") {
+		return errors.New("room ID must look like !abcdef:matrix.org")
+	}
+	return nil
+}
+
+func (mx *Matrix) Send(target string, m *Message) (int, error) {
+	// Silent messages are sent as notices, which do not notify by default
+	msgType := "m.text"
+	if m.Silent {
+		msgType = "m.notice"
+	}
+	msg := &matrixMessage{
+		MsgType:       msgType,
+		Body:          PlainText(m.Text),
+		Format:        "org.matrix.custom.html",
+		FormattedBody: HTML(m.Text),
+	}
+
+	// Transaction ID makes retries of the same message idempotent
+	txnID := "bbtmvbot-" + strconv.FormatInt(m.ID, 10)
+	if m.ID == 0 {
+		txnID = "bbtmvbot-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	}
+	endpoint := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s",
+		strings.TrimSuffix(mx.Homeserver, "/"), url.PathEscape(target), url.PathEscape(txnID))
+	header := http.Header{"Authorization": {"Bearer " + mx.AccessToken}}
+	return 0, requestJSON(mx.Client, http.MethodPut, endpoint, header, msg, nil, matrixStatusError)
+}
+
+// matrixStatusError returns error classified by Matrix error code, or nil if
+// request succeeded.
+func matrixStatusError(res *http.Response) error {
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return nil
+	}
+	text, _ := ioutil.ReadAll(io.LimitReader(res.Body, 512))
+	var e matrixError
+	json.Unmarshal(text, &e)
+	err := fmt.Errorf("%s: %s", res.Status, bytes.TrimSpace(text))
+
+	switch {
+	case e.ErrCode == "M_LIMIT_EXCEEDED" || res.StatusCode == http.StatusTooManyRequests:
+		// Delay is optional, so it may be 0
+		return retryAfter(time.Duration(e.RetryAfterMS)*time.Millisecond, err)
+	case e.ErrCode == "M_FORBIDDEN" || e.ErrCode == "M_NOT_FOUND":
+		// Bot is not in the room or room does not exist
+		return inactive(err)
+	case e.ErrCode == "M_UNKNOWN_TOKEN" || e.ErrCode == "M_MISSING_TOKEN":
+		// Access token is not valid, it is a configuration issue
+		return err
+	case res.StatusCode >= 400 && res.StatusCode < 500:
+		return &PermanentError{err}
+	}
+	return err
+}
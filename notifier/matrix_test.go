@@ -0,0 +1,109 @@
+package notifier
+
+import (
+	"bbtmvbot/website"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMatrixValidateTarget(t *testing.T) {
+	var data = []struct {
+		Target string
+		Valid  bool
+	}{
+		{"!abcdef:matrix.org", true},
+		{"#room:matrix.org", false},
+		{"!abcdef", false},
+	}
+	mx := &Matrix{}
+	for _, v := range data {
+		if got := mx.ValidateTarget(v.Target) == nil; got != v.Valid {
+			t.Errorf("Result of '%s' is incorrect, got: '%t', want: '%t'.", v.Target, got, v.Valid)
+		}
+	}
+}
+
+func TestMatrixSend(t *testing.T) {
+	var got matrixMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := "/_matrix/client/v3/rooms/!abcdef:matrix.org/send/m.room.message/bbtmvbot-42"
+		if r.Method != http.MethodPut || r.URL.Path != path || r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("Result is incorrect, got: '%s %s %s', want: 'PUT %s Bearer token'.", r.Method, r.URL.Path, r.Header.Get("Authorization"), path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		w.Write([]byte(`{"event_id":"$event"}`))
+	}))
+	defer server.Close()
+
+	post := &website.Post{
+		Link:    "https://example.com/1",
+		Phone:   "+37060000000",
+		Address: "Vilnius, Žirmūnų g.",
+		Price:   300,
+		Rooms:   2,
+		Floor:   2,
+	}
+	m := &Message{ID: 42, Text: post.FormatTelegramMessage(5), PostID: 5, Post: post}
+	mx := &Matrix{Homeserver: server.URL + "/", AccessToken: "token"}
+	if _, err := mx.Send("!abcdef:matrix.org", m); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "5. <a href=\"https://example.com/1\">https://example.com/1</a><br>\n" +
+		"» <b>Phone number:</b> <a href=\"tel:+37060000000\">+37060000000</a><br>\n" +
+		"» <b>Address:</b> <a href=\"https://maps.google.com/?q=Vilnius%2C+%C5%BDirm%C5%ABn%C5%B3+g.\">Vilnius, Žirmūnų g.</a><br>\n" +
+		"» <b>Price:</b> <code>300€</code><br>\n" +
+		"» <b>Rooms:</b> <code>2</code><br>\n" +
+		"» <b>Floor:</b> <code>2</code><br>\n" +
+		"» <b>With fee:</b> no<br>\n"
+	if got.FormattedBody != expected || got.Format != "org.matrix.custom.html" {
+		t.Errorf("Result is incorrect, got: '%s', want: '%s'.", got.FormattedBody, expected)
+	}
+	if got.MsgType != "m.text" || got.Body != PlainText(m.Text) {
+		t.Errorf("Result is incorrect, got: '%+v', want: plain text body.", got)
+	}
+}
+
+func TestMatrixSendError(t *testing.T) {
+	var data = []struct {
+		Status     int
+		Body       string
+		RetryAfter time.Duration
+		Permanent  bool
+		Inactive   bool
+	}{
+		{http.StatusTooManyRequests, `{"errcode":"M_LIMIT_EXCEEDED","retry_after_ms":2000}`, 2 * time.Second, false, false},
+		{http.StatusTooManyRequests, `{"errcode":"M_LIMIT_EXCEEDED","error":"Too many requests"}`, DefaultRetryAfter, false, false},
+		{http.StatusForbidden, `{"errcode":"M_FORBIDDEN","error":"not in room"}`, 0, false, true},
+		{http.StatusUnauthorized, `{"errcode":"M_UNKNOWN_TOKEN"}`, 0, false, false},
+		{http.StatusBadRequest, `{"errcode":"M_BAD_JSON"}`, 0, true, false},
+		{http.StatusBadGateway, ``, 0, false, false},
+	}
+	for _, v := range data {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(v.Status)
+			w.Write([]byte(v.Body))
+		}))
+		_, err := (&Matrix{Homeserver: server.URL}).Send("!abcdef:matrix.org", &Message{Text: "text", Silent: true})
+		server.Close()
+
+		var retry *RetryAfterError
+		var permanent *PermanentError
+		if err == nil {
+			t.Errorf("Result of status %d is incorrect, got: 'nil', want: error.", v.Status)
+			continue
+		}
+		if errors.As(err, &retry) != (v.RetryAfter != 0) || retry != nil && retry.After != v.RetryAfter {
+			t.Errorf("Result of status %d is incorrect, got: '%v', want retry after: '%s'.", v.Status, err, v.RetryAfter)
+		}
+		if errors.As(err, &permanent) != v.Permanent || errors.Is(err, ErrInactive) != v.Inactive {
+			t.Errorf("Result of status %d is incorrect, got: '%v', want permanent: '%t', inactive: '%t'.", v.Status, err, v.Permanent, v.Inactive)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+// Package notifier delivers notifications about posts to Telegram and other
+// channels.
+package notifier
+
+import (
+	"bbtmvbot/website"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Message is a notification. Text is formatted using Telegram Markdown, as
+// returned by website.Post.FormatTelegramMessage, and is converted to other
+// formats by notifiers.
+type Message struct {
+	// ID identifies the message, so it is not sent twice when retried
+	ID     int64
+	Text   string
+	Silent bool
+	// PostID and Post are set if message is about a single post
+	PostID int64
+	Post   *website.Post
+	// EditID is ID of the Telegram message this message replaces, or 0
+	EditID int
+}
+
+// Notifier sends messages to targets of a single channel, e.g. chat IDs or
+// email addresses.
+type Notifier interface {
+	// Send sends message to target and returns ID of the sent message, or 0
+	// if channel does not support editing messages.
+	Send(target string, m *Message) (int, error)
+	// ValidateTarget checks target provided by user.
+	ValidateTarget(target string) error
+}
+
+// ErrInactive means that target does not accept messages anymore, e.g. bot
+// was blocked.
+var ErrInactive = errors.New("target does not accept messages")
+
+// RetryAfterError means that target is rate limited and message can be sent
+// again after the given duration.
+type RetryAfterError struct {
+	After time.Duration
+	Err   error
+}
+
+func (e *RetryAfterError) Error() string {
+	return fmt.Sprintf("retry after %s: %s", e.After, e.Err)
+}
+
+func (e *RetryAfterError) Unwrap() error {
+	return e.Err
+}
+
+// DefaultRetryAfter is used when target is rate limited, but did not tell
+// when to retry.
+const DefaultRetryAfter = 5 * time.Second
+
+// retryAfter returns RetryAfterError, which duration is at least
+// DefaultRetryAfter if it is not known.
+func retryAfter(after time.Duration, err error) *RetryAfterError {
+	if after <= 0 {
+		after = DefaultRetryAfter
+	}
+	return &RetryAfterError{After: after, Err: err}
+}
+
+// PermanentError means that message was rejected and sending it again would
+// not help.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// inactive wraps error, so it matches ErrInactive.
+func inactive(err error) error {
+	return fmt.Errorf("%w: %s", ErrInactive, err)
+}
@@ -0,0 +1,72 @@
+package notifier
+
+import (
+	"bbtmvbot/ratelimit"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	telebot "gopkg.in/tucnak/telebot.v2"
+)
+
+// Telegram sends messages to Telegram chats. Target is chat ID.
+type Telegram struct {
+	Bot     *telebot.Bot
+	Limiter *ratelimit.Limiter
+	// Markup returns inline keyboard of a message about the post
+	Markup func(postID int64) *telebot.ReplyMarkup
+}
+
+func (t *Telegram) ValidateTarget(target string) error {
+	_, err := strconv.ParseInt(target, 10, 64)
+	return err
+}
+
+func (t *Telegram) Send(target string, m *Message) (int, error) {
+	chatID, err := strconv.ParseInt(target, 10, 64)
+	if err != nil {
+		return 0, &PermanentError{err}
+	}
+
+	opts := &telebot.SendOptions{ParseMode: telebot.ModeMarkdown, DisableNotification: m.Silent}
+	if m.PostID != 0 && t.Markup != nil {
+		opts.ReplyMarkup = t.Markup(m.PostID)
+	}
+	t.Limiter.Wait(chatID)
+	var sent *telebot.Message
+	if m.EditID != 0 {
+		stored := telebot.StoredMessage{MessageID: strconv.Itoa(m.EditID), ChatID: chatID}
+		sent, err = t.Bot.Edit(stored, m.Text, opts)
+	} else {
+		sent, err = t.Bot.Send(&telebot.Chat{ID: chatID}, m.Text, opts)
+	}
+	if err != nil {
+		return 0, classifyTelegramError(err)
+	}
+	return sent.ID, nil
+}
+
+// classifyTelegramError wraps error returned by Telegram, so sender knows if
+// it is worth retrying.
+func classifyTelegramError(err error) error {
+	var flood telebot.FloodError
+	if errors.As(err, &flood) {
+		return retryAfter(time.Duration(flood.RetryAfter)*time.Second, err)
+	}
+
+	switch err {
+	case telebot.ErrBlockedByUser, telebot.ErrUserIsDeactivated, telebot.ErrNotStartedByUser, telebot.ErrChatNotFound:
+		return inactive(err)
+	}
+	// Errors not known by telebot, like being kicked from a group
+	if strings.Contains(err.Error(), "Forbidden:") {
+		return inactive(err)
+	}
+
+	var apiErr *telebot.APIError
+	if errors.As(err, &apiErr) && apiErr.Code == 400 || strings.HasPrefix(err.Error(), "telegram unknown: Bad Request") {
+		return &PermanentError{err}
+	}
+	return err
+}
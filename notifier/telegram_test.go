@@ -0,0 +1,46 @@
+package notifier
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	telebot "gopkg.in/tucnak/telebot.v2"
+)
+
+func TestClassifyTelegramError(t *testing.T) {
+	var data = []struct {
+		Err       error
+		Inactive  bool
+		Permanent bool
+		After     time.Duration // Zero if error is not rate limited
+	}{
+		{telebot.FloodError{APIError: telebot.NewAPIError(429, "Too Many Requests: retry after 3"), RetryAfter: 3}, false, false, 3 * time.Second},
+		{telebot.FloodError{APIError: telebot.NewAPIError(429, "Too Many Requests"), RetryAfter: 0}, false, false, DefaultRetryAfter},
+		{telebot.ErrBlockedByUser, true, false, 0},
+		{telebot.ErrUserIsDeactivated, true, false, 0},
+		{telebot.ErrNotStartedByUser, true, false, 0},
+		{telebot.ErrChatNotFound, true, false, 0},
+		{fmt.Errorf("telegram unknown: Forbidden: bot was kicked from the group chat (403)"), true, false, 0},
+		{telebot.ErrCantEditMessage, false, true, 0},
+		{fmt.Errorf("telegram unknown: Bad Request: can't parse entities (400)"), false, true, 0},
+		{telebot.ErrInternal, false, false, 0},
+		{errors.New("connection reset by peer"), false, false, 0},
+	}
+
+	for i, v := range data {
+		err := classifyTelegramError(v.Err)
+		var permanent *PermanentError
+		var after time.Duration
+		var retry *RetryAfterError
+		if errors.As(err, &retry) {
+			after = retry.After
+		}
+		inactive := errors.Is(err, ErrInactive)
+		isPermanent := errors.As(err, &permanent)
+		if inactive != v.Inactive || isPermanent != v.Permanent || after != v.After {
+			t.Errorf("Result of case %d is incorrect, got: '%t %t %s', want: '%t %t %s'.", i, inactive, isPermanent, after, v.Inactive, v.Permanent, v.After)
+		}
+	}
+}
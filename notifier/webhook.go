@@ -0,0 +1,168 @@
+package notifier
+
+import (
+	"bbtmvbot/website"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Webhook POSTs messages as JSON to URLs provided by users. Target is URL.
+type Webhook struct {
+	Client *http.Client
+}
+
+// WebhookPayload is JSON body of webhook requests.
+type WebhookPayload struct {
+	// Text is the message as plain text
+	Text string `json:"text"`
+	// Post is set if message is about a single post
+	Post *WebhookPost `json:"post,omitempty"`
+}
+
+// WebhookPost is JSON representation of a post.
+type WebhookPost struct {
+	ID          int64  `json:"id"`
+	Link        string `json:"link"`
+	Phone       string `json:"phone,omitempty"`
+	Description string `json:"description,omitempty"`
+	Address     string `json:"address,omitempty"`
+	Heating     string `json:"heating,omitempty"`
+	Floor       int    `json:"floor,omitempty"`
+	FloorTotal  int    `json:"floor_total,omitempty"`
+	Area        int    `json:"area,omitempty"`
+	Price       int    `json:"price"`
+	Rooms       int    `json:"rooms,omitempty"`
+	Year        int    `json:"year,omitempty"`
+	WithFee     bool   `json:"with_fee"`
+	Source      string `json:"source,omitempty"`
+	City        string `json:"city,omitempty"`
+}
+
+func newWebhookPost(id int64, p *website.Post) *WebhookPost {
+	return &WebhookPost{
+		ID:          id,
+		Link:        p.Link,
+		Phone:       p.Phone,
+		Description: p.Description,
+		Address:     p.Address,
+		Heating:     p.Heating,
+		Floor:       p.Floor,
+		FloorTotal:  p.FloorTotal,
+		Area:        p.Area,
+		Price:       p.Price,
+		Rooms:       p.Rooms,
+		Year:        p.Year,
+		WithFee:     p.IsWithFee(),
+		Source:      p.Source,
+		City:        p.City,
+	}
+}
+
+func (w *Webhook) ValidateTarget(target string) error {
+	return validateURL(target)
+}
+
+// validateURL checks that target is an absolute HTTP(S) URL of a public host.
+func validateURL(target string) error {
+	u, err := url.Parse(target)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Hostname() == "" {
+		return errors.New("URL must start with http:// or https://")
+	}
+	return checkHost(u.Hostname())
+}
+
+func (w *Webhook) Send(target string, m *Message) (int, error) {
+	payload := &WebhookPayload{Text: PlainText(m.Text)}
+	if m.Post != nil {
+		payload.Post = newWebhookPost(m.PostID, m.Post)
+	}
+	return 0, postJSON(w.Client, target, payload, nil)
+}
+
+// postJSON POSTs value as JSON and decodes JSON response into result, unless
+// it is nil. Errors are classified by response status.
+func postJSON(client *http.Client, target string, value, result interface{}) error {
+	return requestJSON(client, http.MethodPost, target, nil, value, result, statusError)
+}
+
+// requestJSON sends value as JSON with given headers and decodes JSON
+// response into result, unless it is nil. Unsuccessful responses are turned
+// into errors by classify.
+func requestJSON(client *http.Client, method, target string, header http.Header, value, result interface{}, classify func(*http.Response) error) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	body, err := json.Marshal(value)
+	if err != nil {
+		return &PermanentError{err}
+	}
+	req, err := http.NewRequest(method, target, bytes.NewReader(body))
+	if err != nil {
+		return &PermanentError{err}
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := client.Do(req)
+	if errors.Is(err, ErrForbiddenAddress) {
+		return &PermanentError{err}
+	}
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if err = classify(res); err != nil {
+		return err
+	}
+	if result == nil {
+		io.Copy(ioutil.Discard, res.Body)
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(result)
+}
+
+// statusError returns error classified by response status, or nil if
+// request succeeded.
+func statusError(res *http.Response) error {
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return nil
+	}
+	text, _ := ioutil.ReadAll(io.LimitReader(res.Body, 512))
+	err := fmt.Errorf("%s: %s", res.Status, bytes.TrimSpace(text))
+
+	switch {
+	case res.StatusCode == http.StatusTooManyRequests:
+		return retryAfter(parseRetryAfter(res.Header.Get("Retry-After"), time.Now()), err)
+	case res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusGone:
+		return inactive(err)
+	case res.StatusCode >= 400 && res.StatusCode < 500:
+		return &PermanentError{err}
+	}
+	return err
+}
+
+// parseRetryAfter parses value of Retry-After header, which is either delay
+// in seconds or HTTP date. Returns 0 if value is missing or invalid.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	// Some services, like Discord, return fractional seconds
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return date.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,121 @@
+package notifier
+
+import (
+	"bbtmvbot/website"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWebhookValidateTarget(t *testing.T) {
+	var data = []struct {
+		Target string
+		Valid  bool
+	}{
+		{"https://example.com/hook", true},
+		{"http://example.com:8080", true},
+		{"http://localhost:8080", false},
+		{"http://169.254.169.254/latest/meta-data/", false},
+		{"http://[::1]/", false},
+		{"ftp://example.com", false},
+		{"example.com/hook", false},
+		{"https://", false},
+	}
+	stubLookup(t)
+	w := &Webhook{}
+	for _, v := range data {
+		if got := w.ValidateTarget(v.Target) == nil; got != v.Valid {
+			t.Errorf("Result of '%s' is incorrect, got: '%t', want: '%t'.", v.Target, got, v.Valid)
+		}
+	}
+}
+
+func TestWebhookSend(t *testing.T) {
+	var got WebhookPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Result is incorrect, got: '%s %s', want: 'POST application/json'.", r.Method, r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	post := &website.Post{Link: "https://example.com/1", Price: 300, Rooms: 2, Area: 50, Description: "Agentūros mokestis 100 eur"}
+	m := &Message{Text: post.FormatTelegramMessage(5), PostID: 5, Post: post}
+	if _, err := (&Webhook{}).Send(server.URL, m); err != nil {
+		t.Fatal(err)
+	}
+	if got.Post == nil || got.Post.ID != 5 || got.Post.Link != post.Link || got.Post.Price != 300 || got.Post.Rooms != 2 || !got.Post.WithFee {
+		t.Errorf("Result is incorrect, got: '%+v', want: post 5.", got.Post)
+	}
+	if got.Text != PlainText(m.Text) {
+		t.Errorf("Result is incorrect, got: '%s', want: '%s'.", got.Text, PlainText(m.Text))
+	}
+}
+
+func TestWebhookSendError(t *testing.T) {
+	var data = []struct {
+		Status     int
+		Header     string
+		RetryAfter time.Duration
+		Permanent  bool
+		Inactive   bool
+	}{
+		{http.StatusTooManyRequests, "30", 30 * time.Second, false, false},
+		// Without delay message must not be retried right away
+		{http.StatusTooManyRequests, "", DefaultRetryAfter, false, false},
+		{http.StatusTooManyRequests, "soon", DefaultRetryAfter, false, false},
+		{http.StatusBadRequest, "", 0, true, false},
+		{http.StatusNotFound, "", 0, false, true},
+		{http.StatusGone, "", 0, false, true},
+		{http.StatusInternalServerError, "", 0, false, false},
+	}
+	for _, v := range data {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if v.Header != "" {
+				w.Header().Set("Retry-After", v.Header)
+			}
+			w.WriteHeader(v.Status)
+		}))
+		_, err := (&Webhook{}).Send(server.URL, &Message{Text: "text"})
+		server.Close()
+
+		var retry *RetryAfterError
+		var permanent *PermanentError
+		if err == nil {
+			t.Errorf("Result of status %d is incorrect, got: 'nil', want: error.", v.Status)
+			continue
+		}
+		if errors.As(err, &retry) != (v.RetryAfter != 0) || retry != nil && retry.After != v.RetryAfter {
+			t.Errorf("Result of status %d is incorrect, got: '%v', want retry after: '%s'.", v.Status, err, v.RetryAfter)
+		}
+		if errors.As(err, &permanent) != v.Permanent || errors.Is(err, ErrInactive) != v.Inactive {
+			t.Errorf("Result of status %d is incorrect, got: '%v', want permanent: '%t', inactive: '%t'.", v.Status, err, v.Permanent, v.Inactive)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2021, time.March, 10, 12, 0, 0, 0, time.UTC)
+	var data = []struct {
+		Value    string
+		Expected time.Duration
+	}{
+		{"120", 2 * time.Minute},
+		{"1.5", 1500 * time.Millisecond},
+		{"Wed, 10 Mar 2021 12:00:30 GMT", 30 * time.Second},
+		{"Wed, 10 Mar 2021 11:59:00 GMT", -time.Minute},
+		{"", 0},
+		{"later", 0},
+	}
+	for _, v := range data {
+		if got := parseRetryAfter(v.Value, now); got != v.Expected {
+			t.Errorf("Result of '%s' is incorrect, got: '%s', want: '%s'.", v.Value, got, v.Expected)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package bbtmvbot
+
+import (
+	"bbtmvbot/config"
+	"bbtmvbot/database"
+	"bbtmvbot/notifier"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Notifications are sent through a persistent outbox, so they are not lost
+// when Telegram or other channel is unavailable. A single worker takes
+// messages in order and sends them to different chats in parallel, while
+// messages of the same chat, including its linked channels, are sent one by
+// one. Message waiting for retry holds back later messages to the same
+// target, so they are not delivered out of order.
+
+const (
+	// How often outbox is checked when there is nothing to send
+	outboxPollInterval = time.Second
+	// Count of messages sent at the same time
+	outboxParallel = 8
+	// Delay after the first failed attempt, doubled after each next one
+	outboxBaseDelay = 5 * time.Second
+	outboxMaxDelay  = time.Hour
+	// Message is dropped after this many failed attempts
+	outboxMaxAttempts = 10
+	// Message is dropped after being rate limited this many times
+	outboxMaxRateLimits = 30
+)
+
+// notifiers send messages of each channel, keyed by channel name
+var notifiers map[string]notifier.Notifier
+
+// initNotifiers sets up Telegram and configured additional channels.
+func initNotifiers(c *config.Config) {
+	notifiers = map[string]notifier.Notifier{
+		database.ChannelTelegram: &notifier.Telegram{Bot: tb, Limiter: limiter, Markup: postMarkup},
+	}
+	if e := c.Notifiers.Email; e != nil {
+		notifiers["email"] = &notifier.Email{Host: e.Host, Port: e.Port, Username: e.Username, Password: e.Password, From: e.From}
+	}
+	if w := c.Notifiers.Webhook; w != nil {
+		notifiers["webhook"] = &notifier.Webhook{Client: notifier.NewPublicClient(timeout(w.Timeout))}
+	}
+	if d := c.Notifiers.Discord; d != nil {
+		notifiers["discord"] = &notifier.Discord{Client: notifier.NewPublicClient(timeout(d.Timeout))}
+	}
+	if m := c.Notifiers.Matrix; m != nil {
+		// Homeserver is configured by admin, so it may be an internal one
+		client := &http.Client{Timeout: timeout(m.Timeout)}
+		notifiers["matrix"] = &notifier.Matrix{Client: client, Homeserver: m.Homeserver, AccessToken: m.AccessToken}
+	}
+}
+
+// Timeout of requests to channels, if it is not configured
+const notifierTimeout = 10 * time.Second
+
+// timeout returns configured timeout or the default one.
+func timeout(configured time.Duration) time.Duration {
+	if configured == 0 {
+		return notifierTimeout
+	}
+	return configured
+}
+
+// queueTelegram adds message to the outbox of the chat and its linked
+// channels. Notification about a post gets post buttons and is remembered
+// once sent, so it can be edited later.
+func queueTelegram(chatID int64, msg string, silent bool, postID int64) {
+	db.Enqueue(chatID, msg, silent, postID)
+}
+
+func runOutbox() {
+	var mu sync.Mutex
+	busy := map[int64]bool{} // Chats with a message being sent
+	slots := make(chan struct{}, outboxParallel)
+
+	for {
+		slots <- struct{}{}
+
+		mu.Lock()
+		skip := make([]int64, 0, len(busy))
+		for id := range busy {
+			skip = append(skip, id)
+		}
+		m := db.NextOutbox(time.Now(), skip...)
+		if m != nil {
+			busy[m.TelegramID] = true
+		}
+		mu.Unlock()
+
+		if m == nil {
+			<-slots
+			time.Sleep(outboxPollInterval)
+			continue
+		}
+		go func() {
+			deliver(m)
+			mu.Lock()
+			delete(busy, m.TelegramID)
+			mu.Unlock()
+			<-slots
+		}()
+	}
+}
+
+// deliver sends message from the outbox and records the result.
+func deliver(m *database.OutboxMessage) {
+	n, found := notifiers[m.Channel]
+	if !found {
+		log.Printf("failed to send outbox message %d: channel '%s' is not configured", m.ID, m.Channel)
+		db.MarkFailed(m.ID, "channel is not configured")
+		return
+	}
+
+	msg := &notifier.Message{ID: m.ID, Text: m.Text, Silent: m.Silent, PostID: m.PostID, EditID: m.EditMessageID}
+	if m.PostID != 0 && m.Channel != database.ChannelTelegram {
+		if post := db.GetPost(m.PostID); post != nil {
+			msg.Post = &post.Post
+		}
+	}
+	messageID, err := n.Send(m.Target, msg)
+	if err == nil {
+		db.MarkSent(m.ID, messageID)
+		if m.PostID != 0 && m.Channel == database.ChannelTelegram && m.EditMessageID == 0 {
+			db.AddNotification(m.PostID, m.TelegramID, messageID, m.Text)
+		}
+		return
+	}
+
+	var retry *notifier.RetryAfterError
+	var permanent *notifier.PermanentError
+	switch {
+	case errors.As(err, &retry) && m.RateLimits+1 >= outboxMaxRateLimits:
+		log.Printf("failed to send outbox message %d to %s %s, rate limited too many times: %s", m.ID, m.Channel, m.Target, err)
+		db.MarkFailed(m.ID, err.Error())
+	case errors.As(err, &retry):
+		// Retrying right away would only hit the limit again
+		delay := retry.After
+		if delay <= 0 {
+			delay = outboxBaseDelay
+		}
+		log.Printf("outbox message %d is rate limited, retrying after %s", m.ID, delay)
+		db.RateLimited(m.ID, time.Now().Add(delay), err.Error())
+	case errors.Is(err, notifier.ErrInactive) && m.Channel == database.ChannelTelegram:
+		log.Printf("disabling chat %d, that cannot receive messages: %s", m.TelegramID, err)
+		db.DeactivateChat(m.TelegramID, err.Error())
+	case errors.Is(err, notifier.ErrInactive):
+		log.Printf("unlinking %s of chat %d, that cannot receive messages: %s", m.Channel, m.TelegramID, err)
+		db.DeactivateChannel(m.TelegramID, m.Channel, m.Target, err.Error())
+	case errors.As(err, &permanent) || m.Attempts+1 >= outboxMaxAttempts:
+		log.Printf("failed to send outbox message %d to %s %s: %s", m.ID, m.Channel, m.Target, err)
+		db.MarkFailed(m.ID, err.Error())
+	default:
+		delay := backoff(m.Attempts + 1)
+		log.Printf("failed to send outbox message %d to %s %s, retrying after %s: %s", m.ID, m.Channel, m.Target, delay, err)
+		db.RetryLater(m.ID, time.Now().Add(delay), err.Error())
+	}
+}
+
+// backoff returns delay after given count of failed attempts.
+func backoff(attempts int) time.Duration {
+	delay := outboxBaseDelay
+	for i := 1; i < attempts && delay < outboxMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > outboxMaxDelay {
+		return outboxMaxDelay
+	}
+	return delay
+}
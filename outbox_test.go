@@ -0,0 +1,27 @@
+package bbtmvbot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	var data = []struct {
+		Attempts int
+		Expected time.Duration
+	}{
+		{0, outboxBaseDelay},
+		{1, outboxBaseDelay},
+		{2, 2 * outboxBaseDelay},
+		{3, 4 * outboxBaseDelay},
+		{10, 512 * outboxBaseDelay},
+		{11, outboxMaxDelay},
+		{1000, outboxMaxDelay},
+	}
+
+	for _, v := range data {
+		if got := backoff(v.Attempts); got != v.Expected {
+			t.Errorf("Result of %d attempts is incorrect, got: '%s', want: '%s'.", v.Attempts, got, v.Expected)
+		}
+	}
+}
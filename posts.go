@@ -1 +1,190 @@
 package bbtmvbot
+
+import (
+	"bbtmvbot/database"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	telebot "gopkg.in/tucnak/telebot.v2"
+)
+
+// Number of posts shown in a single /saved or /find message
+const postsPageSize = 5
+
+// formatPostsPage renders page of posts starting from offset. Pagination
+// buttons have given unique name and data returned by pageData. Markup is nil
+// if there is only one page.
+func formatPostsPage(title string, posts []*database.Post, offset, total int, unique string, pageData func(offset int) string) (string, *telebot.ReplyMarkup) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "*%s %d-%d of %d:*\n\n", title, offset+1, offset+len(posts), total)
+	for _, p := range posts {
+		sb.WriteString(p.FormatTelegramMessage(p.ID))
+		sb.WriteString("\n")
+	}
+
+	row := make([]telebot.InlineButton, 0, 2)
+	if offset > 0 {
+		row = append(row, telebot.InlineButton{Unique: unique, Text: "◀ Previous", Data: pageData(offset - postsPageSize)})
+	}
+	if offset+len(posts) < total {
+		row = append(row, telebot.InlineButton{Unique: unique, Text: "Next ▶", Data: pageData(offset + postsPageSize)})
+	}
+	if len(row) == 0 {
+		return sb.String(), nil
+	}
+	return sb.String(), &telebot.ReplyMarkup{InlineKeyboard: [][]telebot.InlineButton{row}}
+}
+
+// lastPage returns offset of the last page.
+func lastPage(total int) int {
+	return (total - 1) / postsPageSize * postsPageSize
+}
+
+// sendPage sends first page of a list.
+func sendPage(chatID int64, msg string, markup *telebot.ReplyMarkup) {
+	if markup == nil {
+		sendTelegram(chatID, msg)
+		return
+	}
+	sendTelegram(chatID, msg, markup)
+}
+
+// editPage replaces list message with another page.
+func editPage(c *telebot.Callback, msg string, markup *telebot.ReplyMarkup) {
+	if markup == nil {
+		markup = &telebot.ReplyMarkup{} // Removes buttons
+	}
+	respond(c, "")
+	editTelegram(c.Message.Chat.ID, c.Message.ID, msg, markup)
+}
+
+func handleCommandPost(m *telebot.Message) {
+	id, ok := commandPostID(m.Text)
+	if !ok {
+		sendTelegram(m.Chat.ID, "Wrong input! Use this format:\n\n```\n/post <id>\n```")
+		return
+	}
+	showPost(m.Chat.ID, id)
+}
+
+// showPost sends post with its buttons.
+func showPost(chatID, id int64) {
+	post := db.GetPost(id)
+	if post == nil {
+		sendTelegram(chatID, "Post "+strconv.FormatInt(id, 10)+" does not exist!")
+		return
+	}
+	sendTelegram(chatID, post.FormatTelegramMessage(post.ID), postMarkup(post.ID))
+}
+
+// Posts listed without buttons, like in digests, link to "/post_<id>"
+// command, which Telegram makes clickable unlike "/post <id>"
+const postLinkPrefix = "/post_"
+
+// postLink returns Markdown of the command showing the post, with underscore
+// escaped.
+func postLink(id int64) string {
+	return "/post\\_" + strconv.FormatInt(id, 10)
+}
+
+// linkedPostID returns ID of the post, if text is a post link command.
+func linkedPostID(text string) (int64, bool) {
+	if !strings.HasPrefix(text, postLinkPrefix) {
+		return 0, false
+	}
+	// Bot name is added to commands in groups
+	text = strings.SplitN(strings.TrimPrefix(text, postLinkPrefix), "@", 2)[0]
+	id, err := strconv.ParseInt(text, 10, 64)
+	return id, err == nil && id > 0
+}
+
+// Unique name of /find pagination buttons. Button data is page offset
+// followed by /find arguments.
+const findUnique = "find"
+
+const (
+	findDefaultDays = 7
+	findMaxDays     = 30 // Older posts are deleted
+)
+
+const findText = "Use this format:\n\n```\n/find [<days>] [<price_from> <price_to> <rooms_from> <rooms_to> <year_from> <min_flor> <show with fee?(yes/no)>]\n```\nWithout config values your config is used. Posts of the last 7 days are searched by default, up to 30 days.\n\nExample:\n```\n/find 3 200 330 1 2 2000 2 yes\n```"
+
+// parseFind parses /find arguments into a query of the first page.
+func parseFind(telegramID int64, args []string) (*database.PostQuery, error) {
+	days := findDefaultDays
+	if len(args) == 1 || len(args) == 8 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 || n > findMaxDays {
+			return nil, fmt.Errorf("days must be between 1 and %d", findMaxDays)
+		}
+		days = n
+		args = args[1:]
+	}
+
+	q := &database.PostQuery{
+		Cities:     db.UserCities(telegramID),
+		TelegramID: telegramID,
+		Since:      time.Now().AddDate(0, 0, -days),
+		Limit:      postsPageSize,
+	}
+	switch len(args) {
+	case 0:
+		q.Filter = db.GetUser(telegramID).Filter
+	case 7:
+		filter, err := database.ParseConfig(strings.Join(args, " "))
+		if err != nil {
+			return nil, err
+		}
+		q.Filter = *filter
+	default:
+		return nil, fmt.Errorf("wrong number of arguments")
+	}
+	return q, nil
+}
+
+func handleCommandFind(m *telebot.Message) {
+	args := strings.Fields(strings.ToLower(m.Text))[1:]
+	q, err := parseFind(m.Chat.ID, args)
+	if err != nil {
+		sendTelegram(m.Chat.ID, "Wrong input!\n"+err.Error()+"\n\n"+findText)
+		return
+	}
+	msg, markup := findPage(q, args)
+	sendPage(m.Chat.ID, msg, markup)
+}
+
+func handleFindButton(c *telebot.Callback) {
+	args := strings.Fields(c.Data)
+	if len(args) == 0 {
+		respond(c, "")
+		return
+	}
+	offset, err := strconv.Atoi(args[0])
+	q, errFind := parseFind(c.Message.Chat.ID, args[1:])
+	if err != nil || offset < 0 || errFind != nil {
+		respond(c, "")
+		return
+	}
+	q.Offset = offset
+	msg, markup := findPage(q, args[1:])
+	editPage(c, msg, markup)
+}
+
+// findPage renders page of found posts.
+func findPage(q *database.PostQuery, args []string) (string, *telebot.ReplyMarkup) {
+	posts, total := db.FindPosts(q)
+	if total == 0 {
+		return "No posts found!", nil
+	}
+	if len(posts) == 0 {
+		// Posts were deleted since the page was shown
+		q.Offset = lastPage(total)
+		posts, total = db.FindPosts(q)
+	}
+	pageData := func(offset int) string {
+		return strings.Join(append([]string{strconv.Itoa(offset)}, args...), " ")
+	}
+	return formatPostsPage("Found posts", posts, q.Offset, total, findUnique, pageData)
+}
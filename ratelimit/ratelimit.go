@@ -0,0 +1,155 @@
+// Package ratelimit limits rate of messages sent to Telegram using token
+// buckets: a global one, shared by all chats, and one per chat.
+//
+// See https://core.telegram.org/bots/faq#my-bot-is-hitting-limits-how-do-i-avoid-this
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limit is a rate of a token bucket.
+type Limit struct {
+	// Rate is count of tokens added per second
+	Rate float64
+	// Burst is count of tokens bucket can hold
+	Burst int
+}
+
+// Limits used by Telegram bots
+var (
+	// No more than 30 messages per second to all chats
+	Global = Limit{Rate: 25, Burst: 5}
+	// No more than one message per second to the same chat, short bursts are
+	// allowed
+	Private = Limit{Rate: 1, Burst: 3}
+	// No more than 20 messages per minute to the same group
+	Group = Limit{Rate: 17.0 / 60, Burst: 3}
+)
+
+// Clock provides time, so it can be faked in tests.
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time        { return time.Now() }
+func (realClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+// bucket is a token bucket, which tokens may go negative when reserved in
+// advance.
+type bucket struct {
+	limit  Limit
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(limit Limit, now time.Time) *bucket {
+	return &bucket{limit: limit, tokens: float64(limit.Burst), last: now}
+}
+
+// refill adds tokens accumulated since the last call.
+func (b *bucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * b.limit.Rate
+		if b.tokens > float64(b.limit.Burst) {
+			b.tokens = float64(b.limit.Burst)
+		}
+		b.last = now
+	}
+}
+
+// take takes a token and returns how long to wait until it is available.
+func (b *bucket) take(now time.Time) time.Duration {
+	b.refill(now)
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.limit.Rate * float64(time.Second))
+}
+
+// full reports whether bucket is full, so it can be forgotten.
+func (b *bucket) full(now time.Time) bool {
+	b.refill(now)
+	return b.tokens >= float64(b.limit.Burst)
+}
+
+// Buckets of idle chats are removed when there are more of them
+const maxIdleBuckets = 1000
+
+// Limiter limits rate of messages globally and per chat. It is safe for
+// concurrent use; waiting for one chat does not block other chats, unless
+// global limit is reached.
+type Limiter struct {
+	mu      sync.Mutex
+	clock   Clock
+	global  *bucket
+	chats   map[int64]*bucket
+	private Limit
+	group   Limit
+}
+
+// New returns limiter using Telegram limits.
+func New() *Limiter {
+	return NewWithClock(realClock{}, Global, Private, Group)
+}
+
+// NewWithClock returns limiter with given clock and limits.
+func NewWithClock(clock Clock, global, private, group Limit) *Limiter {
+	return &Limiter{
+		clock:   clock,
+		global:  newBucket(global, clock.Now()),
+		chats:   map[int64]*bucket{},
+		private: private,
+		group:   group,
+	}
+}
+
+// Reserve reserves a message to the chat and returns how long to wait before
+// sending it. Group chats have negative IDs.
+func (l *Limiter) Reserve(chatID int64) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	chat, found := l.chats[chatID]
+	if !found {
+		l.forgetIdle(now)
+		limit := l.private
+		if chatID < 0 {
+			limit = l.group
+		}
+		chat = newBucket(limit, now)
+		l.chats[chatID] = chat
+	}
+
+	wait := l.global.take(now)
+	if chatWait := chat.take(now); chatWait > wait {
+		wait = chatWait
+	}
+	return wait
+}
+
+// Wait blocks until message can be sent to the chat.
+func (l *Limiter) Wait(chatID int64) {
+	if wait := l.Reserve(chatID); wait > 0 {
+		l.clock.Sleep(wait)
+	}
+}
+
+// forgetIdle removes full buckets if there are too many of them. Full bucket
+// is the same as a new one.
+func (l *Limiter) forgetIdle(now time.Time) {
+	if len(l.chats) < maxIdleBuckets {
+		return
+	}
+	for id, b := range l.chats {
+		if b.full(now) {
+			delete(l.chats, id)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package ratelimit
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock advances only when slept on.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Sleep(d time.Duration) {
+	c.advance(d)
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2021, time.March, 10, 12, 0, 0, 0, time.UTC)}
+}
+
+// Limits without global limit getting in the way
+var unlimited = Limit{Rate: 1000, Burst: 1000}
+
+func TestReservePrivate(t *testing.T) {
+	l := NewWithClock(newFakeClock(), unlimited, Limit{Rate: 1, Burst: 3}, Limit{Rate: 1.0 / 3, Burst: 1})
+
+	var data = []struct {
+		ChatID   int64
+		Expected time.Duration
+	}{
+		// Burst is sent right away, the rest once per second
+		{1, 0},
+		{1, 0},
+		{1, 0},
+		{1, time.Second},
+		{1, 2 * time.Second},
+		// Other chats are not affected
+		{2, 0},
+		// Group chats have stricter limits
+		{-100, 0},
+		{-100, 3 * time.Second},
+		{-100, 6 * time.Second},
+	}
+	for i, v := range data {
+		if got := l.Reserve(v.ChatID); got != v.Expected {
+			t.Errorf("Result of case %d is incorrect, got: '%s', want: '%s'.", i, got, v.Expected)
+		}
+	}
+}
+
+func TestReserveRefill(t *testing.T) {
+	clock := newFakeClock()
+	l := NewWithClock(clock, unlimited, Limit{Rate: 1, Burst: 2}, Limit{Rate: 1, Burst: 2})
+
+	l.Reserve(1)
+	l.Reserve(1)
+	clock.advance(1500 * time.Millisecond)
+	if got := l.Reserve(1); got != 0 {
+		t.Errorf("Result is incorrect, got: '%s', want: '0s'.", got)
+	}
+	if got := l.Reserve(1); got != 500*time.Millisecond {
+		t.Errorf("Result is incorrect, got: '%s', want: '500ms'.", got)
+	}
+
+	// Bucket does not hold more tokens than burst
+	clock.advance(time.Hour)
+	for i, expected := range []time.Duration{0, 0, time.Second} {
+		if got := l.Reserve(1); got != expected {
+			t.Errorf("Result of reservation %d is incorrect, got: '%s', want: '%s'.", i, got, expected)
+		}
+	}
+}
+
+func TestReserveGlobal(t *testing.T) {
+	l := NewWithClock(newFakeClock(), Limit{Rate: 10, Burst: 2}, unlimited, unlimited)
+
+	// Different chats share the global limit
+	for i, expected := range []time.Duration{0, 0, 100 * time.Millisecond, 200 * time.Millisecond} {
+		if got := l.Reserve(int64(i)); got != expected {
+			t.Errorf("Result of chat %d is incorrect, got: '%s', want: '%s'.", i, got, expected)
+		}
+	}
+}
+
+func TestWait(t *testing.T) {
+	clock := newFakeClock()
+	start := clock.Now()
+	l := NewWithClock(clock, unlimited, Limit{Rate: 2, Burst: 1}, unlimited)
+
+	for i := 0; i < 5; i++ {
+		l.Wait(1)
+	}
+	// First message is sent right away, the rest every 500ms
+	if got := clock.Now().Sub(start); got != 2*time.Second {
+		t.Errorf("Result is incorrect, got: '%s', want: '2s'.", got)
+	}
+}
+
+func TestWaitParallel(t *testing.T) {
+	clock := newFakeClock()
+	l := NewWithClock(clock, unlimited, Limit{Rate: 1, Burst: 1}, unlimited)
+
+	// Reservations of different chats made at the same time do not wait for
+	// each other
+	var wg sync.WaitGroup
+	waits := make([]time.Duration, 10)
+	for i := range waits {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			waits[i] = l.Reserve(int64(i))
+		}(i)
+	}
+	wg.Wait()
+	for i, wait := range waits {
+		if wait != 0 {
+			t.Errorf("Result of chat %d is incorrect, got: '%s', want: '0s'.", i, wait)
+		}
+	}
+}
+
+func TestForgetIdle(t *testing.T) {
+	clock := newFakeClock()
+	l := NewWithClock(clock, unlimited, Limit{Rate: 1, Burst: 1}, unlimited)
+
+	for i := int64(0); i < maxIdleBuckets; i++ {
+		l.Reserve(i)
+	}
+	clock.advance(time.Second)
+	l.Reserve(maxIdleBuckets)
+	if len(l.chats) != 1 {
+		t.Errorf("Result is incorrect, got: '%d', want: '1'.", len(l.chats))
+	}
+}
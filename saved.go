@@ -0,0 +1,82 @@
+package bbtmvbot
+
+import (
+	"strconv"
+	"strings"
+
+	telebot "gopkg.in/tucnak/telebot.v2"
+)
+
+// Unique name of /saved pagination buttons. Button data is page offset.
+const savedUnique = "saved"
+
+const saveText = "Use this format:\n\n```\n/save <id>\n/unsave <id>\n/saved\n```\nID is the number at the beginning of each notification."
+
+// commandPostID returns post ID given as the only argument of a command.
+func commandPostID(text string) (int64, bool) {
+	fields := strings.Fields(text)
+	if len(fields) != 2 {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(strings.TrimSuffix(fields[1], "."), 10, 64)
+	return id, err == nil && id > 0
+}
+
+func handleCommandSave(m *telebot.Message) {
+	id, ok := commandPostID(m.Text)
+	if !ok {
+		sendTelegram(m.Chat.ID, "Wrong input! "+saveText)
+		return
+	}
+	if db.GetPost(id) == nil {
+		sendTelegram(m.Chat.ID, "Post "+strconv.FormatInt(id, 10)+" does not exist!")
+		return
+	}
+	if !db.SavePost(m.Chat.ID, id) {
+		sendTelegram(m.Chat.ID, "Post "+strconv.FormatInt(id, 10)+" is already saved!")
+		return
+	}
+	sendTelegram(m.Chat.ID, "Post "+strconv.FormatInt(id, 10)+" saved! Use /saved to see all saved posts.")
+}
+
+func handleCommandUnsave(m *telebot.Message) {
+	id, ok := commandPostID(m.Text)
+	if !ok {
+		sendTelegram(m.Chat.ID, "Wrong input! "+saveText)
+		return
+	}
+	if !db.UnsavePost(m.Chat.ID, id) {
+		sendTelegram(m.Chat.ID, "Post "+strconv.FormatInt(id, 10)+" is not saved!")
+		return
+	}
+	sendTelegram(m.Chat.ID, "Post "+strconv.FormatInt(id, 10)+" removed from saved posts!")
+}
+
+func handleCommandSaved(m *telebot.Message) {
+	msg, markup := savedPage(m.Chat.ID, 0)
+	sendPage(m.Chat.ID, msg, markup)
+}
+
+func handleSavedButton(c *telebot.Callback) {
+	offset, err := strconv.Atoi(c.Data)
+	if err != nil || offset < 0 {
+		respond(c, "")
+		return
+	}
+	msg, markup := savedPage(c.Message.Chat.ID, offset)
+	editPage(c, msg, markup)
+}
+
+// savedPage renders page of saved posts starting from offset.
+func savedPage(telegramID int64, offset int) (string, *telebot.ReplyMarkup) {
+	posts, total := db.GetSaved(telegramID, offset, postsPageSize)
+	if total == 0 {
+		return "You have no saved posts! Press ⭐ Save on a notification or use `/save <id>`.", nil
+	}
+	if len(posts) == 0 {
+		// Posts were unsaved since the page was shown
+		offset = lastPage(total)
+		posts, total = db.GetSaved(telegramID, offset, postsPageSize)
+	}
+	return formatPostsPage("Saved posts", posts, offset, total, savedUnique, strconv.Itoa)
+}
@@ -0,0 +1,157 @@
+package bbtmvbot
+
+import (
+	"bbtmvbot/database"
+	"fmt"
+	"strings"
+
+	telebot "gopkg.in/tucnak/telebot.v2"
+)
+
+const searchText = "Searches let one chat look for several kinds of flats at once, in addition to /config. Use these formats:\n\n```\n/search add <name> <price_from> <price_to> <rooms_from> <rooms_to> <year_from> <min_flor> <show with fee?(yes/no)>\n/search filter <name> <filter> <value>\n/search list\n/search pause <name>\n/search resume <name>\n/search delete <name>\n```\nExample:\n```\n/search add cheap 1-room 200 350 1 1 1990 0 no\n/search filter cheap 1-room districts Naujamiestis, Senamiestis\n```\nSee /filter for available filters."
+
+// Characters that would break Markdown of messages containing search name
+const searchNameForbidden = "*_`[]"
+
+func handleCommandSearch(m *telebot.Message) {
+	// Remove @<botname> from command if exists
+	fields := strings.Fields(strings.TrimSpace(m.Text))
+	fields[0] = strings.Split(fields[0], "@")[0]
+
+	if len(fields) < 2 {
+		sendTelegram(m.Chat.ID, searchText)
+		return
+	}
+
+	args := fields[2:]
+	switch strings.ToLower(fields[1]) {
+	case "list":
+		sendTelegram(m.Chat.ID, userSearches(m.Chat.ID))
+	case "add":
+		handleSearchAdd(m, args)
+	case "filter":
+		handleSearchFilter(m, args)
+	case "pause", "resume":
+		s := db.GetSearch(m.Chat.ID, strings.Join(args, " "))
+		if s == nil {
+			sendTelegram(m.Chat.ID, "There is no such search!\n\n"+userSearches(m.Chat.ID))
+			return
+		}
+		s.Paused = strings.ToLower(fields[1]) == "pause"
+		db.UpdateSearch(s)
+		sendTelegram(m.Chat.ID, "Search updated!\n\n"+userSearches(m.Chat.ID))
+	case "delete":
+		if !db.DeleteSearch(m.Chat.ID, strings.Join(args, " ")) {
+			sendTelegram(m.Chat.ID, "There is no such search!\n\n"+userSearches(m.Chat.ID))
+			return
+		}
+		sendTelegram(m.Chat.ID, "Search deleted!\n\n"+userSearches(m.Chat.ID))
+	default:
+		sendTelegram(m.Chat.ID, "Wrong input! "+searchText)
+	}
+}
+
+func handleSearchAdd(m *telebot.Message, args []string) {
+	// Name is everything before config values
+	if len(args) < 8 {
+		sendTelegram(m.Chat.ID, "Wrong input! "+searchText)
+		return
+	}
+	name := strings.Join(args[:len(args)-7], " ")
+	if strings.ContainsAny(name, searchNameForbidden) {
+		sendTelegram(m.Chat.ID, "Search name must not contain any of "+searchNameForbidden+" characters!")
+		return
+	}
+	filter, err := database.ParseConfig(strings.Join(args[len(args)-7:], " "))
+	if err != nil {
+		sendTelegram(m.Chat.ID, "Wrong input!\n"+err.Error()+"\n\n"+searchText)
+		return
+	}
+
+	s := &database.Search{TelegramID: m.Chat.ID, Name: name, Filter: *filter}
+	if !db.AddSearch(s) {
+		sendTelegram(m.Chat.ID, "Search with this name already exists!\n\n"+userSearches(m.Chat.ID))
+		return
+	}
+	db.SetEnabled(m.Chat.ID, true)
+	sendTelegram(m.Chat.ID, "Search added!\n\n"+userSearches(m.Chat.ID))
+}
+
+var filterNames = []string{"area", "max_floor", "not_last_floor", "price_m2", "heating", "districts"}
+
+func handleSearchFilter(m *telebot.Message, args []string) {
+	// Name is everything before filter name
+	for i, arg := range args {
+		for _, filterName := range filterNames {
+			if i == 0 || i == len(args)-1 || strings.ToLower(arg) != filterName {
+				continue
+			}
+
+			s := db.GetSearch(m.Chat.ID, strings.Join(args[:i], " "))
+			if s == nil {
+				sendTelegram(m.Chat.ID, "There is no such search!\n\n"+userSearches(m.Chat.ID))
+				return
+			}
+			if err := applyFilter(&s.Filter, filterName, strings.Join(args[i+1:], " ")); err != nil {
+				sendTelegram(m.Chat.ID, "Wrong input! "+err.Error()+"\n\n"+filterText)
+				return
+			}
+			db.UpdateSearch(s)
+			sendTelegram(m.Chat.ID, "Search updated!\n\n"+userSearches(m.Chat.ID))
+			return
+		}
+	}
+	sendTelegram(m.Chat.ID, "Wrong input! "+searchText)
+}
+
+func userSearches(telegramID int64) string {
+	searches := db.GetSearches(telegramID)
+	if len(searches) == 0 {
+		return "*Your searches:* none"
+	}
+
+	var sb strings.Builder
+	sb.WriteString("*Your searches:*\n")
+	for _, s := range searches {
+		status := ""
+		if s.Paused {
+			status = " (paused)"
+		}
+		fmt.Fprintf(&sb, "» *%s*%s: %s\n", s.Name, status, formatFilter(&s.Filter))
+	}
+	return sb.String()
+}
+
+// formatFilter formats filter into a single line.
+func formatFilter(f *database.Filter) string {
+	showWithFee := "yes"
+	if !f.ShowWithFees {
+		showWithFee = "no"
+	}
+	parts := []string{
+		fmt.Sprintf("%d-%d€", f.PriceFrom, f.PriceTo),
+		fmt.Sprintf("%d-%d rooms", f.RoomsFrom, f.RoomsTo),
+		fmt.Sprintf("from %d", f.YearFrom),
+		fmt.Sprintf("min floor %d", f.MinFloor),
+		"with fee " + showWithFee,
+	}
+	if f.MinArea != 0 || f.MaxArea != 0 {
+		parts = append(parts, "area "+formatRange(f.MinArea, f.MaxArea, "m²"))
+	}
+	if f.MaxFloor != 0 {
+		parts = append(parts, fmt.Sprintf("max floor %d", f.MaxFloor))
+	}
+	if f.NotLastFloor {
+		parts = append(parts, "not last floor")
+	}
+	if f.MaxPricePerM2 != 0 {
+		parts = append(parts, fmt.Sprintf("max %.2f€/m²", f.MaxPricePerM2))
+	}
+	if len(f.Heating) != 0 {
+		parts = append(parts, "heating "+formatList(f.Heating))
+	}
+	if len(f.Districts) != 0 {
+		parts = append(parts, "districts "+formatList(f.Districts))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -2,12 +2,13 @@ package bbtmvbot
 
 import (
 	"bbtmvbot/database"
+	"bbtmvbot/ratelimit"
+	"bbtmvbot/website"
+	"errors"
 	"fmt"
-	"regexp"
+	"log"
 	"strconv"
 	"strings"
-	"sync"
-	"time"
 
 	telebot "gopkg.in/tucnak/telebot.v2"
 )
@@ -18,15 +19,44 @@ func initTelegramHandlers() {
 	tb.Handle("/enable", handleCommandEnable)
 	tb.Handle("/disable", handleCommandDisable)
 	tb.Handle("/config", handleCommandConfig)
+	tb.Handle("/city", handleCommandCity)
+	tb.Handle("/filter", handleCommandFilter)
+	tb.Handle("/keywords", handleCommandKeywords)
+	tb.Handle("/search", handleCommandSearch)
+	tb.Handle("/save", handleCommandSave)
+	tb.Handle("/unsave", handleCommandUnsave)
+	tb.Handle("/saved", handleCommandSaved)
+	tb.Handle("/post", handleCommandPost)
+	tb.Handle("/find", handleCommandFind)
+	tb.Handle("/delivery", handleCommandDelivery)
+	tb.Handle("/quiet", handleCommandQuiet)
+	tb.Handle("/unmute", handleCommandUnmute)
+	tb.Handle("/unhide", handleCommandUnhide)
+	tb.Handle("/link", handleCommandLink)
+	tb.Handle("/confirm", handleCommandConfirm)
+	tb.Handle("/unlink", handleCommandUnlink)
+	tb.Handle(&telebot.InlineButton{Unique: wizardUnique}, handleConfigCallback)
+	tb.Handle(&telebot.InlineButton{Unique: savedUnique}, handleSavedButton)
+	tb.Handle(&telebot.InlineButton{Unique: findUnique}, handleFindButton)
+	initButtonHandlers()
+	tb.Handle(telebot.OnText, handleText)
 }
 
 func handleCommandInfo(m *telebot.Message) {
 	sendTelegram(m.Chat.ID, "BBTMV-noRestrict - 'Butų NE TIK Be Tarpininkavimo Mokesčio Vilniuje' is a project intended to help find flats for a rent in Vilnius, Lithuania. All you have to do is to set config using /config command and wait until bot sends you notifications.\n\n**Fun fact** - if you are couple and looking for a flat, then create group chat and add this bot into that group - enable settings and bot will send notifications to the same chat. :)")
 }
 
+// configured reports whether chat has its config or at least one search set.
+func configured(user *database.User) bool {
+	if user.PriceFrom == 0 && user.PriceTo == 0 && user.RoomsFrom == 0 && user.RoomsTo == 0 && user.YearFrom == 0 {
+		return len(db.GetSearches(user.TelegramID)) > 0
+	}
+	return true
+}
+
 func handleCommandEnable(m *telebot.Message) {
 	user := db.GetUser(m.Chat.ID)
-	if user.PriceFrom == 0 && user.PriceTo == 0 && user.RoomsFrom == 0 && user.RoomsTo == 0 && user.YearFrom == 0 {
+	if !configured(user) {
 		sendTelegram(m.Chat.ID, "You must first use /config command before using /enable or /disable commands!")
 		return
 	}
@@ -40,7 +70,7 @@ func handleCommandEnable(m *telebot.Message) {
 
 func handleCommandDisable(m *telebot.Message) {
 	user := db.GetUser(m.Chat.ID)
-	if user.PriceFrom == 0 && user.PriceTo == 0 && user.RoomsFrom == 0 && user.RoomsTo == 0 && user.YearFrom == 0 {
+	if !configured(user) {
 		sendTelegram(m.Chat.ID, "You must first use `/config` command before using `/enable` or `/disable` commands!")
 		return
 	}
@@ -52,11 +82,12 @@ func handleCommandDisable(m *telebot.Message) {
 	sendTelegram(m.Chat.ID, "Notifications disabled!")
 }
 
-var reConfigCommand = regexp.MustCompile(`^/config (\d{1,5}) (\d{1,5}) (\d{1,2}) (\d{1,2}) (\d{4}) (\d{1,3}) (yes|no)$`)
-
 const configText = "Use this format:\n\n```\n/config <price_from> <price_to> <rooms_from> <rooms_to> <year_from> <min_flor> <show with fee?(yes/no)>\n```\nExample:\n```\n/config 200 330 1 2 2000 2 yes\n```"
 
-const configErrorText = "Wrong input! " + configText
+// configError formats validation errors of config values.
+func configError(err error) string {
+	return "Wrong input!\n" + err.Error() + "\n\n" + configText
+}
 
 func handleCommandConfig(m *telebot.Message) {
 	msg := strings.ToLower(strings.TrimSpace(m.Text))
@@ -64,53 +95,274 @@ func handleCommandConfig(m *telebot.Message) {
 	// Remove @<botname> from command if exists
 	msg = strings.Split(msg, "@")[0]
 
-	// Check if default
+	// Without values config is asked step by step
 	if msg == "/config" {
-		sendTelegram(m.Chat.ID, configText+"\n"+activeSettings(m.Chat.ID))
-		return
-	}
-
-	if !reConfigCommand.MatchString(msg) {
-		sendTelegram(m.Chat.ID, configErrorText)
+		sendTelegram(m.Chat.ID, activeSettings(m.Chat.ID)+"\nYou can also set whole config at once. "+configText)
+		startConfigWizard(m.Chat.ID, senderID(m.Sender))
 		return
 	}
 
-	// Extract variables from message (using regex)
-	match := reConfigCommand.FindStringSubmatch(msg)
-	priceFrom, _ := strconv.Atoi(match[1])
-	priceTo, _ := strconv.Atoi(match[2])
-	roomsFrom, _ := strconv.Atoi(match[3])
-	roomsTo, _ := strconv.Atoi(match[4])
-	yearFrom, _ := strconv.Atoi(match[5])
-	minFloor, _ := strconv.Atoi(match[6])
-	showWithFees := strings.ToLower(match[7]) == "yes"
-
-	// Values check
-	priceCorrect := priceFrom >= 0 || priceTo <= 100000 && priceTo >= priceFrom
-	roomsCorrect := roomsFrom >= 0 || roomsTo <= 100 && roomsTo >= roomsFrom
-	yearCorrect := yearFrom <= time.Now().Year()
-	minFloorCorrect := minFloor >= 0 && minFloor <= 100
-
-	if !(priceCorrect && roomsCorrect && yearCorrect && minFloorCorrect) {
-		sendTelegram(m.Chat.ID, configErrorText)
+	filter, err := database.ParseConfig(strings.TrimPrefix(msg, "/config "))
+	if err != nil {
+		sendTelegram(m.Chat.ID, configError(err))
 		return
 	}
 
 	user := &database.User{
-		TelegramID:   m.Chat.ID,
-		Enabled:      true,
-		PriceFrom:    priceFrom,
-		PriceTo:      priceTo,
-		RoomsFrom:    roomsFrom,
-		RoomsTo:      roomsTo,
-		YearFrom:     yearFrom,
-		MinFloor:     minFloor,
-		ShowWithFees: showWithFees,
+		TelegramID: m.Chat.ID,
+		Enabled:    true,
+		Filter:     *filter,
 	}
 	db.UpdateUser(user)
 	sendTelegram(m.Chat.ID, "Config updated!\n\n"+activeSettings(m.Chat.ID))
 }
 
+const cityText = "Use this format:\n\n```\n/city <city> [<city>...]\n```\nExample:\n```\n/city vilnius kaunas\n```\nUse `/city all` to receive posts from all cities.\n\nAvailable cities: %s"
+
+func handleCommandCity(m *telebot.Message) {
+	msg := strings.ToLower(strings.TrimSpace(m.Text))
+
+	// Remove @<botname> from command if exists
+	fields := strings.Fields(msg)
+	fields[0] = strings.Split(fields[0], "@")[0]
+
+	available := make([]string, 0, len(cities))
+	for _, c := range cities {
+		available = append(available, c.ID)
+	}
+	help := fmt.Sprintf(cityText, strings.Join(available, ", "))
+
+	// Check if default
+	if len(fields) == 1 {
+		sendTelegram(m.Chat.ID, help+"\n\n"+activeSettings(m.Chat.ID))
+		return
+	}
+
+	selected := make([]string, 0, len(fields)-1)
+	if !(len(fields) == 2 && fields[1] == "all") {
+		for _, id := range fields[1:] {
+			if !isConfiguredCity(id) {
+				sendTelegram(m.Chat.ID, "Unknown city '"+id+"'! "+help)
+				return
+			}
+			selected = append(selected, id)
+		}
+	}
+
+	db.SetUserCities(m.Chat.ID, selected)
+	sendTelegram(m.Chat.ID, "Cities updated!\n\n"+activeSettings(m.Chat.ID))
+}
+
+func isConfiguredCity(id string) bool {
+	for _, c := range cities {
+		if c.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// userCityNames returns names of the cities user is subscribed to.
+func userCityNames(telegramID int64) string {
+	ids := db.UserCities(telegramID)
+	if len(ids) == 0 {
+		return "all"
+	}
+	names := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if c, ok := website.Cities[id]; ok {
+			names = append(names, c.Name)
+		} else {
+			names = append(names, id)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+const filterText = "Use one of these formats (`0` or `any` removes the filter):\n\n```\n/filter area <min_m²> <max_m²>\n/filter max_floor <floor>\n/filter not_last_floor <yes/no>\n/filter price_m2 <max_€/m²>\n/filter heating <type>[, <type>...]\n/filter districts <district>[, <district>...]\n```\nExample:\n```\n/filter districts Žirmūnai, Naujoji Vilnia\n```"
+
+func handleCommandFilter(m *telebot.Message) {
+	// Remove @<botname> from command if exists
+	fields := strings.SplitN(strings.TrimSpace(m.Text), " ", 3)
+	fields[0] = strings.Split(fields[0], "@")[0]
+
+	// Check if default
+	if len(fields) < 3 {
+		sendTelegram(m.Chat.ID, filterText+"\n"+activeSettings(m.Chat.ID))
+		return
+	}
+
+	user := db.GetUser(m.Chat.ID)
+	if err := applyFilter(&user.Filter, strings.ToLower(fields[1]), strings.TrimSpace(fields[2])); err != nil {
+		sendTelegram(m.Chat.ID, "Wrong input! "+err.Error()+"\n\n"+filterText)
+		return
+	}
+	db.UpdateFilters(user)
+	sendTelegram(m.Chat.ID, "Filter updated!\n\n"+activeSettings(m.Chat.ID))
+}
+
+// applyFilter sets filter with given name to the value provided by user.
+func applyFilter(u *database.Filter, name, value string) error {
+	if value == "any" {
+		value = "0"
+	}
+	f := *u
+	var fields []string
+	switch name {
+	case "area":
+		bounds := strings.Fields(value)
+		if len(bounds) == 1 && bounds[0] == "0" {
+			bounds = []string{"0", "0"}
+		}
+		if len(bounds) != 2 {
+			return errors.New("area needs minimum and maximum")
+		}
+		minArea, errMin := strconv.Atoi(bounds[0])
+		maxArea, errMax := strconv.Atoi(bounds[1])
+		if errMin != nil || errMax != nil {
+			return errors.New("area must be two numbers")
+		}
+		f.MinArea, f.MaxArea = minArea, maxArea
+		fields = []string{"min_area", "max_area"}
+	case "max_floor":
+		maxFloor, err := strconv.Atoi(value)
+		if err != nil {
+			return errors.New("max_floor must be a number")
+		}
+		f.MaxFloor = maxFloor
+		fields = []string{"max_floor"}
+	case "not_last_floor":
+		switch strings.ToLower(value) {
+		case "yes":
+			f.NotLastFloor = true
+		case "no", "0":
+			f.NotLastFloor = false
+		default:
+			return errors.New("not_last_floor must be yes or no")
+		}
+	case "price_m2":
+		maxPrice, err := strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 64)
+		if err != nil {
+			return errors.New("price_m2 must be a number")
+		}
+		f.MaxPricePerM2 = maxPrice
+		fields = []string{"max_price_per_m2"}
+	case "heating":
+		f.Heating = filterList(value)
+	case "districts":
+		f.Districts = filterList(value)
+	default:
+		return errors.New("unknown filter '" + name + "'")
+	}
+
+	// Only errors of changed fields are reported
+	if err := f.Validate(); err != nil {
+		for _, field := range fields {
+			if e := err.(database.ValidationError).Field(field); e != nil {
+				return e
+			}
+		}
+	}
+	*u = f
+	return nil
+}
+
+// filterList splits comma separated list and normalizes its values.
+func filterList(value string) []string {
+	list := make([]string, 0)
+	if value == "0" {
+		return list
+	}
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(website.Normalize(v)); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+const keywordsText = "Posts must contain at least one of included keywords (if there are any) and none of excluded ones. Description and address are searched ignoring case and Lithuanian letters. Use these formats:\n\n```\n/keywords add <include/exclude> <keyword>\n/keywords remove <keyword>\n/keywords list\n```\nExample:\n```\n/keywords add exclude tik studentams\n```"
+
+func handleCommandKeywords(m *telebot.Message) {
+	// Remove @<botname> from command if exists
+	fields := strings.Fields(strings.TrimSpace(m.Text))
+	fields[0] = strings.Split(fields[0], "@")[0]
+
+	if len(fields) < 2 {
+		sendTelegram(m.Chat.ID, keywordsText)
+		return
+	}
+
+	switch strings.ToLower(fields[1]) {
+	case "list":
+		sendTelegram(m.Chat.ID, userKeywords(m.Chat.ID))
+	case "add":
+		if len(fields) < 4 {
+			sendTelegram(m.Chat.ID, "Wrong input! "+keywordsText)
+			return
+		}
+		list := strings.ToLower(fields[2])
+		if list != "include" && list != "exclude" {
+			sendTelegram(m.Chat.ID, "Wrong input! "+keywordsText)
+			return
+		}
+		keyword := website.Normalize(strings.Join(fields[3:], " "))
+		db.AddKeyword(m.Chat.ID, keyword, list == "exclude")
+		sendTelegram(m.Chat.ID, "Keyword added!\n\n"+userKeywords(m.Chat.ID))
+	case "remove":
+		if len(fields) < 3 {
+			sendTelegram(m.Chat.ID, "Wrong input! "+keywordsText)
+			return
+		}
+		keyword := website.Normalize(strings.Join(fields[2:], " "))
+		if !db.RemoveKeyword(m.Chat.ID, keyword) {
+			sendTelegram(m.Chat.ID, "There is no such keyword!\n\n"+userKeywords(m.Chat.ID))
+			return
+		}
+		sendTelegram(m.Chat.ID, "Keyword removed!\n\n"+userKeywords(m.Chat.ID))
+	default:
+		sendTelegram(m.Chat.ID, "Wrong input! "+keywordsText)
+	}
+}
+
+func userKeywords(telegramID int64) string {
+	include, exclude := db.GetKeywords(telegramID)
+	return fmt.Sprintf("*Included keywords:* %s\n*Excluded keywords:* %s", formatList(include), formatList(exclude))
+}
+
+// formatRange formats filter bounds, where zero means no limit.
+func formatRange(from, to int, unit string) string {
+	switch {
+	case from == 0 && to == 0:
+		return "any"
+	case to == 0:
+		return fmt.Sprintf("from %d%s", from, unit)
+	case from == 0:
+		return fmt.Sprintf("up to %d%s", to, unit)
+	}
+	return fmt.Sprintf("%d-%d%s", from, to, unit)
+}
+
+// formatKeywords formats included keywords with + and excluded ones with -.
+func formatKeywords(telegramID int64) string {
+	include, exclude := db.GetKeywords(telegramID)
+	keywords := make([]string, 0, len(include)+len(exclude))
+	for _, k := range include {
+		keywords = append(keywords, "+"+k)
+	}
+	for _, k := range exclude {
+		keywords = append(keywords, "-"+k)
+	}
+	return formatList(keywords)
+}
+
+func formatList(list []string) string {
+	if len(list) == 0 {
+		return "any"
+	}
+	return strings.Join(list, ", ")
+}
+
 const userSettingsTemplate = `*Your active settings:*
 » *Notifications:* %[1]s
 » *Price:* %[2]d-%[3]d€
@@ -118,6 +370,16 @@ const userSettingsTemplate = `*Your active settings:*
 » *From construction year:* %[6]d
 » *Min floor:* %[7]d
 » *Show with extra fees:* %[8]s
+» *Cities:* %[9]s
+» *Area:* %[10]s
+» *Max floor:* %[11]s
+» *Not last floor:* %[12]s
+» *Max price per m²:* %[13]s
+» *Heating:* %[14]s
+» *Districts:* %[15]s
+» *Keywords:* %[16]s
+» *Delivery:* %[17]s
+» *Quiet hours:* %[18]s
 
 Current config:
 ` + "`/config %[2]d %[3]d %[4]d %[5]d %[6]d %[7]d %[8]s`"
@@ -133,6 +395,19 @@ func activeSettings(telegramID int64) string {
 	if !u.ShowWithFees {
 		showWithFee = "no"
 	}
+	notLastFloor := "yes"
+	if !u.NotLastFloor {
+		notLastFloor = "no"
+	}
+	maxFloor := "any"
+	if u.MaxFloor != 0 {
+		maxFloor = strconv.Itoa(u.MaxFloor)
+	}
+	delivery := db.GetDelivery(telegramID)
+	maxPricePerM2 := "any"
+	if u.MaxPricePerM2 != 0 {
+		maxPricePerM2 = fmt.Sprintf("%.2f€", u.MaxPricePerM2)
+	}
 
 	msg := fmt.Sprintf(
 		userSettingsTemplate,
@@ -144,25 +419,55 @@ func activeSettings(telegramID int64) string {
 		u.YearFrom,
 		u.MinFloor,
 		showWithFee,
+		userCityNames(telegramID),
+		formatRange(u.MinArea, u.MaxArea, "m²"),
+		maxFloor,
+		notLastFloor,
+		maxPricePerM2,
+		formatList(u.Heating),
+		formatList(u.Districts),
+		formatKeywords(telegramID),
+		formatDelivery(delivery),
+		formatQuiet(delivery),
 	)
 
 	return msg
 }
 
-var telegramMux sync.Mutex
-var elapsedTime time.Duration
+// Limits messages sent to Telegram, both globally and per chat
+var limiter = ratelimit.New()
 
-func sendTelegram(chatID int64, msg string) {
-	telegramMux.Lock()
-	defer telegramMux.Unlock()
+// sendTelegram sends reply with optional inline keyboard right away and
+// returns it, or nil if sending failed. Notifications are sent using
+// queueTelegram instead.
+func sendTelegram(chatID int64, msg string, markup ...*telebot.ReplyMarkup) *telebot.Message {
+	limiter.Wait(chatID)
+	m, err := tb.Send(&telebot.Chat{ID: chatID}, msg, sendOptions(markup))
+	if err != nil {
+		log.Printf("failed to send message to chat %d: %s", chatID, err)
+		return nil
+	}
+	return m
+}
 
-	startTime := time.Now()
-	tb.Send(&telebot.Chat{ID: chatID}, msg, &telebot.SendOptions{
+// editTelegram replaces text and optional inline keyboard of already sent
+// message.
+func editTelegram(chatID int64, messageID int, msg string, markup ...*telebot.ReplyMarkup) {
+	limiter.Wait(chatID)
+	stored := telebot.StoredMessage{MessageID: strconv.Itoa(messageID), ChatID: chatID}
+	_, err := tb.Edit(stored, msg, sendOptions(markup))
+	if err != nil {
+		log.Printf("failed to edit message %d in chat %d: %s", messageID, chatID, err)
+	}
+}
+
+func sendOptions(markup []*telebot.ReplyMarkup) *telebot.SendOptions {
+	opts := &telebot.SendOptions{
 		ParseMode:             "Markdown",
 		DisableWebPagePreview: false,
-	})
-	elapsedTime = time.Since(startTime)
-
-	// See https://core.telegram.org/bots/faq#my-bot-is-hitting-limits-how-do-i-avoid-this
-	time.Sleep(30*time.Millisecond - elapsedTime)
+	}
+	if len(markup) > 0 {
+		opts.ReplyMarkup = markup[0]
+	}
+	return opts
 }
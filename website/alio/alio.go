@@ -1,161 +1,167 @@
 package alio
 
 import (
-	"bbtmvbot/database"
 	"bbtmvbot/website"
-	"log"
+	"context"
 	"strconv"
 	"strings"
 
 	"github.com/PuerkitoBio/goquery"
 )
 
-type Alio struct{}
+type Alio struct {
+	settings *website.Settings
+}
 
-const LINK = "https://www.alio.lt/paieska/?category_id=1393&city_id=228626&search_block=1&search[eq][adresas_1]=228626&order=ad_id"
+const BASE_URL = "https://www.alio.lt"
 
-func (obj *Alio) Retrieve(db *database.Database) []*website.Post {
-	posts := make([]*website.Post, 0)
+// searchPaths contains search page of every supported city. Only Vilnius is
+// supported, other cities are skipped unless their search_paths are
+// configured.
+var searchPaths = map[string]string{
+	"vilnius": "/paieska/?category_id=1393&city_id=228626&search_block=1&search[eq][adresas_1]=228626&order=ad_id",
+}
 
-	res, err := website.GetResponse(LINK)
-	if err != nil {
-		return posts
-	}
-	defer res.Body.Close()
-	doc, err := goquery.NewDocumentFromReader(res.Body)
+func (obj *Alio) List(ctx context.Context) ([]*website.Stub, error) {
+	stubs := make([]*website.Stub, 0)
+	link := obj.settings.ListingURL + obj.settings.SearchPath
+
+	doc, err := obj.settings.Client.GetDocument(ctx, link)
 	if err != nil {
-		return posts
+		return stubs, err
 	}
 
-	doc.Find("#main_left_b > #main-content-center > div.result").Each(func(i int, s *goquery.Selection) {
-		p := &website.Post{}
+	items := doc.Find("#main_left_b > #main-content-center > div.result")
+	if items.Length() == 0 {
+		return stubs, &website.LayoutError{URL: link, Field: "posts list"}
+	}
 
+	var lastErr error
+	items.Each(func(i int, s *goquery.Selection) {
 		upstreamID, ok := s.Attr("id")
 		if !ok {
-			log.Println("Post ID is not found in 'alio' website")
+			lastErr = &website.LayoutError{URL: link, Field: "post ID"}
 			return
 		}
-		p.Link = "https://www.alio.lt/skelbimai/ID" + strings.ReplaceAll(upstreamID, "lv_ad_id_", "") + ".html" // https://www.alio.lt/skelbimai/ID60331923.html
+		stub := &website.Stub{ID: strings.ReplaceAll(upstreamID, "lv_ad_id_", "")}
+		stub.Link = obj.settings.BaseURL + "/skelbimai/ID" + stub.ID + ".html" // https://www.alio.lt/skelbimai/ID60331923.html
+		stub.Price = website.ListingPrice(s.Find(".main_price").Text())
+		stubs = append(stubs, stub)
+	})
 
-		if db.InDatabase(p.Link) {
-			return
-		}
+	return stubs, lastErr
+}
 
-		postRes, err := website.GetResponse(p.Link)
-		if err != nil {
-			return
-		}
-		defer postRes.Body.Close()
-		postDoc, err := goquery.NewDocumentFromReader(postRes.Body)
-		if err != nil {
-			return
-		}
+func (obj *Alio) Fetch(ctx context.Context, stub *website.Stub) (*website.Post, error) {
+	p := &website.Post{Link: stub.Link}
 
-		// Extract phone:
-		p.Phone = postDoc.Find("#phone_val_value").Text()
+	postDoc, err := obj.settings.Client.GetDocument(ctx, p.Link)
+	if err != nil {
+		return nil, err
+	}
 
-		// Extract description:
-		p.Description = postDoc.Find("#adv_description_b > .a_line_val").Text()
+	// Extract phone:
+	p.Phone = postDoc.Find("#phone_val_value").Text()
 
-		// Extract address:
-		el := postDoc.Find(".data_moreinfo_b:contains(\"Adresas\")")
-		if el.Length() != 0 {
-			p.Address = el.Find(".a_line_val").Text()
-		}
+	// Extract description:
+	p.Description = postDoc.Find("#adv_description_b > .a_line_val").Text()
 
-		// Extract heating:
-		el = postDoc.Find(".data_moreinfo_b:contains(\"Šildymas\")")
-		if el.Length() != 0 {
-			p.Heating = el.Find(".a_line_val").Text()
-		}
+	// Extract address:
+	el := postDoc.Find(".data_moreinfo_b:contains(\"Adresas\")")
+	if el.Length() != 0 {
+		p.Address = el.Find(".a_line_val").Text()
+	}
 
-		// Extract floor:
-		tmp := ""
-		el = postDoc.Find(".data_moreinfo_b:contains(\"Buto aukštas\")")
-		if el.Length() != 0 {
-			tmp = el.Find(".a_line_val").Text()
-			tmp = strings.TrimSpace(tmp)
-			p.Floor, err = strconv.Atoi(tmp)
-			if err != nil {
-				log.Println("failed to extract Floor number from 'alio' post")
-				return
-			}
-		}
+	// Extract heating:
+	el = postDoc.Find(".data_moreinfo_b:contains(\"Šildymas\")")
+	if el.Length() != 0 {
+		p.Heating = el.Find(".a_line_val").Text()
+	}
 
-		// Extract floor total:
-		el = postDoc.Find(".data_moreinfo_b:contains(\"Aukštų skaičius pastate\")")
-		if el.Length() != 0 {
-			tmp = el.Find(".a_line_val").Text()
-			tmp = strings.TrimSpace(tmp)
-			p.FloorTotal, err = strconv.Atoi(tmp)
-			if err != nil {
-				log.Println("failed to extract FloorTotal number from 'alio' post")
-				return
-			}
+	// Extract floor:
+	tmp := ""
+	el = postDoc.Find(".data_moreinfo_b:contains(\"Buto aukštas\")")
+	if el.Length() != 0 {
+		tmp = el.Find(".a_line_val").Text()
+		tmp = strings.TrimSpace(tmp)
+		p.Floor, err = strconv.Atoi(tmp)
+		if err != nil {
+			return nil, &website.LayoutError{URL: p.Link, Field: "Floor", Err: err}
 		}
+	}
 
-		// Extract area:
-		el = postDoc.Find(".data_moreinfo_b:contains(\"Buto plotas\")")
-		if el.Length() != 0 {
-			tmp = el.Find(".a_line_val").Text()
-			tmp = strings.TrimSpace(tmp)
-			tmp = strings.Split(tmp, " ")[0]
-			var tmpArea, err = strconv.ParseFloat(tmp, 32) // Area is represented as a float and Atoi does not work on it
-			if err != nil {
-				log.Println("failed to extract Area number from 'alio' post")
-				return
-			}
-			p.Area = int(tmpArea)
+	// Extract floor total:
+	el = postDoc.Find(".data_moreinfo_b:contains(\"Aukštų skaičius pastate\")")
+	if el.Length() != 0 {
+		tmp = el.Find(".a_line_val").Text()
+		tmp = strings.TrimSpace(tmp)
+		p.FloorTotal, err = strconv.Atoi(tmp)
+		if err != nil {
+			return nil, &website.LayoutError{URL: p.Link, Field: "FloorTotal", Err: err}
 		}
+	}
 
-		// Extract price:
-		el = postDoc.Find(".data_moreinfo_b:contains(\"Kaina, €\")").First()
-		if el.Length() != 0 {
-			tmp = el.Find(".a_line_val").Text()
-			tmp = strings.TrimSpace(tmp)
-			tmp = strings.Split(tmp, " ")[0]
-			if strings.Contains(tmp, ".") {
-				tmp = strings.Split(tmp, ".")[0]
-			}
-			p.Price, err = strconv.Atoi(tmp)
-			if err != nil {
-				log.Println("failed to extract Price number from 'alio' post")
-				return
-			}
+	// Extract area:
+	el = postDoc.Find(".data_moreinfo_b:contains(\"Buto plotas\")")
+	if el.Length() != 0 {
+		tmp = el.Find(".a_line_val").Text()
+		tmp = strings.TrimSpace(tmp)
+		tmp = strings.Split(tmp, " ")[0]
+		var tmpArea, err = strconv.ParseFloat(tmp, 32) // Area is represented as a float and Atoi does not work on it
+		if err != nil {
+			return nil, &website.LayoutError{URL: p.Link, Field: "Area", Err: err}
 		}
+		p.Area = int(tmpArea)
+	}
 
-		// Extract rooms:
-		el = postDoc.Find(".data_moreinfo_b:contains(\"Kambarių skaičius\")")
-		if el.Length() != 0 {
-			tmp = el.Find(".a_line_val").Text()
-			tmp = strings.TrimSpace(tmp)
-			p.Rooms, err = strconv.Atoi(tmp)
-			if err != nil {
-				log.Println("failed to extract Rooms number from 'alio' post")
-				return
-			}
+	// Extract price:
+	el = postDoc.Find(".data_moreinfo_b:contains(\"Kaina, €\")").First()
+	if el.Length() != 0 {
+		tmp = el.Find(".a_line_val").Text()
+		tmp = strings.TrimSpace(tmp)
+		tmp = strings.Split(tmp, " ")[0]
+		if strings.Contains(tmp, ".") {
+			tmp = strings.Split(tmp, ".")[0]
 		}
+		p.Price, err = strconv.Atoi(tmp)
+		if err != nil {
+			return nil, &website.LayoutError{URL: p.Link, Field: "Price", Err: err}
+		}
+	}
 
-		// Extract year:
-		el = postDoc.Find(".data_moreinfo_b:contains(\"Statybos metai\")")
-		if el.Length() != 0 {
-			tmp = el.Find(".a_line_val").Text()
-			tmp = strings.TrimSpace(tmp)
-			tmp = strings.Split(tmp, " ")[0]
-			p.Year, err = strconv.Atoi(tmp)
-			if err != nil {
-				log.Println("failed to extract Year number from 'alio' post")
-				return
-			}
+	// Extract rooms:
+	el = postDoc.Find(".data_moreinfo_b:contains(\"Kambarių skaičius\")")
+	if el.Length() != 0 {
+		tmp = el.Find(".a_line_val").Text()
+		tmp = strings.TrimSpace(tmp)
+		p.Rooms, err = strconv.Atoi(tmp)
+		if err != nil {
+			return nil, &website.LayoutError{URL: p.Link, Field: "Rooms", Err: err}
 		}
+	}
 
-		p.TrimFields()
-		posts = append(posts, p)
-	})
+	// Extract year:
+	el = postDoc.Find(".data_moreinfo_b:contains(\"Statybos metai\")")
+	if el.Length() != 0 {
+		tmp = el.Find(".a_line_val").Text()
+		tmp = strings.TrimSpace(tmp)
+		tmp = strings.Split(tmp, " ")[0]
+		p.Year, err = strconv.Atoi(tmp)
+		if err != nil {
+			return nil, &website.LayoutError{URL: p.Link, Field: "Year", Err: err}
+		}
+	}
 
-	return posts
+	p.TrimFields()
+	return p, nil
 }
 
 func init() {
-	website.Add("alio", &Alio{})
+	website.Add("alio", website.Portal{
+		BaseURL:     BASE_URL,
+		SearchPaths: searchPaths,
+		New: func(s *website.Settings) website.Website {
+			return &Alio{settings: s}
+		},
+	})
 }
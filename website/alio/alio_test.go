@@ -0,0 +1,27 @@
+package alio
+
+import (
+	"bbtmvbot/website/websitetest"
+	"context"
+	"testing"
+)
+
+func TestAlio(t *testing.T) {
+	srv := websitetest.Serve(t, map[string]string{
+		"/paieska/":                  "listing.html",
+		"/skelbimai/ID60331923.html": "post.html",
+	})
+	w := &Alio{settings: websitetest.Settings(srv, BASE_URL, BASE_URL, searchPaths["vilnius"])}
+
+	stubs, err := w.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	websitetest.Golden(t, "listing", stubs)
+
+	post, err := w.Fetch(context.Background(), stubs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	websitetest.Golden(t, "post", post)
+}
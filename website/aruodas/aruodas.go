@@ -1,139 +1,113 @@
 package aruodas
 
 import (
-	"bbtmvbot/database"
 	"bbtmvbot/website"
-	"github.com/chromedp/cdproto/cdp"
-	"log"
+	"context"
 	"strconv"
 	"strings"
-)
 
-type Aruodas struct{}
+	"github.com/PuerkitoBio/goquery"
+)
 
-const LINK = "https://m.aruodas.lt/?obj=4&FRegion=461&FDistrict=1&FOrder=AddDate&from_search=1&detailed_search=1&FShowOnly=FOwnerDbId0%2CFOwnerDbId1&act=search"
+type Aruodas struct {
+	settings *website.Settings
+}
 
-func processItem(node *cdp.Node, posts []*website.Post, db *database.Database) {
-	p := &website.Post{}
+const BASE_URL = "https://aruodas.lt"
+const LISTING_URL = "https://m.aruodas.lt"
 
-	upstreamID, ok := node.Attribute("data-id")
-	if !ok {
-		log.Println("Post ID is not found in 'aruodas' website")
-		return
-	}
-	p.Link = "https://aruodas.lt/" + strings.ReplaceAll(upstreamID, "loadObject", "") // https://aruodas.lt/4-919937
+// searchPaths contains search page of every supported city, which is
+// identified by region (municipality) filter
+var searchPaths = map[string]string{
+	"vilnius":  searchPath("FRegion=461&FDistrict=1"),
+	"kaunas":   searchPath("FRegion=43"),
+	"klaipeda": searchPath("FRegion=112"),
+}
 
-	if db.InDatabase(p.Link) {
-		return
-	}
+func searchPath(region string) string {
+	return "/?obj=4&" + region + "&FOrder=AddDate&from_search=1&detailed_search=1&FShowOnly=FOwnerDbId0%2CFOwnerDbId1&act=search"
+}
 
-	chromeContext, err := website.CreateChromeContext(p.Link)
+func (obj *Aruodas) Fetch(ctx context.Context, stub *website.Stub) (*website.Post, error) {
+	// Page is rendered by headless browser, which is not available in tests,
+	// so recorded pages are parsed by parsePost directly
+	doc, err := obj.settings.Client.GetDocumentChrome(ctx, stub.Link)
 	if err != nil {
-		return
+		return nil, err
 	}
+	return obj.parsePost(doc, stub.Link)
+}
+
+// detailValue returns text of the first child of details list value, which
+// leaves out additional notes, like price per m².
+func detailValue(s *goquery.Selection) string {
+	return strings.TrimSpace(s.Contents().First().Text())
+}
 
+// parsePost extracts post details from rendered post page.
+func (obj *Aruodas) parsePost(doc *goquery.Document, link string) (*website.Post, error) {
+	p := &website.Post{Link: link}
+	var err error
 	var tmp string
 
 	// Extract phone:
-	tempPhone, err := website.ScrapeExistingText(chromeContext, "span.phone_item_0")
-	if err != nil {
-		return
+	p.Phone = strings.TrimSpace(doc.Find("span.phone_item_0").First().Text())
+	if p.Phone == "" {
+		p.Phone = strings.TrimSpace(doc.Find("div.phone").First().Text())
 	}
 
-	if tempPhone == "" {
-		tempPhone, err = website.ScrapeExistingText(chromeContext, "div.phone")
-
-		if err != nil {
-			return
-		}
-	}
-	p.Phone = tempPhone
-
 	// Extract description:
-	p.Description, err = website.ScrapeExistingText(chromeContext, "#collapsedTextBlock > #collapsedText")
-	if err != nil {
-		return
-	}
+	p.Description = doc.Find("#collapsedTextBlock > #collapsedText").Text()
 
 	// Extract address:
-	temp, err := website.ScrapeExistingText(chromeContext, ".main-content > .obj-cont > h1")
-	if err != nil {
-		return
-	}
-
-	splitAddress := strings.Split(temp, ",")
-
-	dlList, err := website.ScrapeExistingNodes(chromeContext, "dl dt, dl dd")
-	var houseNumberIndex = -1
-	var heatingIndex = -1
-	var floorIndex = -1
-	var floorTotalIndex = -1
-	var areaIndex = -1
-	var priceIndex = -1
-	var roomsIndex = -1
-	var yearIndex = -1
-	for index, keyNode := range dlList {
-		if keyNode.LocalName != "dt" {
-			continue
+	splitAddress := strings.Split(doc.Find(".main-content > .obj-cont > h1").Text(), ",")
+	if len(splitAddress) < 3 {
+		return nil, &website.LayoutError{URL: p.Link, Field: "Address"}
+	}
+
+	// Details list contains term and value pairs
+	details := map[string]*goquery.Selection{}
+	keys := []string{"Namo numeris", "Šildymas", "Aukštas", "Aukštų sk.", "Plotas", "Kaina mėn.", "Kambarių sk.", "Metai"}
+	doc.Find("dl dt").Each(func(i int, s *goquery.Selection) {
+		term := detailValue(s)
+		for _, key := range keys {
+			if strings.Contains(term, key) {
+				details[key] = s.NextFiltered("dd")
+			}
 		}
-
-		var value = strings.TrimSpace(keyNode.Children[0].NodeValue)
-
-		if strings.Contains(value, "Namo numeris") {
-			houseNumberIndex = index + 1
-		} else if strings.Contains(value, "Šildymas") {
-			heatingIndex = index + 1
-		} else if strings.Contains(value, "Aukštas") {
-			floorIndex = index + 1
-		} else if strings.Contains(value, "Aukštų sk.") {
-			floorTotalIndex = index + 1
-		} else if strings.Contains(value, "Plotas") {
-			areaIndex = index + 1
-		} else if strings.Contains(value, "Kaina mėn.") {
-			priceIndex = index + 1
-		} else if strings.Contains(value, "Kambarių sk.") {
-			roomsIndex = index + 1
-		} else if strings.Contains(value, "Metai") {
-			yearIndex = index + 1
-		}
-	}
+	})
 
 	// Extract house number
-	if houseNumberIndex != -1 {
-		var houseNumber = dlList[houseNumberIndex].Value
-		p.Address = website.CompileAddressWithStreet(splitAddress[1], splitAddress[2], houseNumber)
+	if dd, ok := details["Namo numeris"]; ok {
+		p.Address = website.CompileAddressWithStreet(obj.settings.City.Name, splitAddress[1], splitAddress[2], strings.TrimSpace(dd.Text()))
 	} else {
-		p.Address = website.CompileAddress(splitAddress[1], splitAddress[2])
+		p.Address = website.CompileAddress(obj.settings.City.Name, splitAddress[1], splitAddress[2])
 	}
 
 	// Extract heating:
-	if heatingIndex != -1 {
-		p.Heating = strings.TrimSpace(dlList[heatingIndex].Children[0].NodeValue)
+	if dd, ok := details["Šildymas"]; ok {
+		p.Heating = detailValue(dd)
 	}
 
 	// Extract floor:
-	if floorIndex != -1 {
-		tmp = strings.TrimSpace(dlList[floorIndex].Children[0].NodeValue)
-		p.Floor, err = strconv.Atoi(tmp)
+	if dd, ok := details["Aukštas"]; ok {
+		p.Floor, err = strconv.Atoi(detailValue(dd))
 		if err != nil {
-			log.Println("failed to extract Floor number from 'aruodas' post")
-			return
+			return nil, &website.LayoutError{URL: p.Link, Field: "Floor", Err: err}
 		}
 	}
 
 	// Extract floor total:
-	if floorTotalIndex != -1 {
-		tmp = strings.TrimSpace(dlList[floorTotalIndex].Children[0].NodeValue)
-		p.FloorTotal, err = strconv.Atoi(tmp)
+	if dd, ok := details["Aukštų sk."]; ok {
+		p.FloorTotal, err = strconv.Atoi(detailValue(dd))
 		if err != nil {
-			log.Println("failed to extract FloorTotal number from 'aruodas' post")
-			return
+			return nil, &website.LayoutError{URL: p.Link, Field: "FloorTotal", Err: err}
 		}
 	}
 
 	// Extract area:
-	if areaIndex != -1 {
-		tmp = strings.TrimSpace(dlList[areaIndex].Children[0].NodeValue)
+	if dd, ok := details["Plotas"]; ok {
+		tmp = detailValue(dd)
 		if strings.Contains(tmp, ",") {
 			tmp = strings.Split(tmp, ",")[0]
 		} else {
@@ -141,66 +115,86 @@ func processItem(node *cdp.Node, posts []*website.Post, db *database.Database) {
 		}
 		p.Area, err = strconv.Atoi(tmp)
 		if err != nil {
-			log.Println("failed to extract Area number from 'aruodas' post")
-			return
+			return nil, &website.LayoutError{URL: p.Link, Field: "Area", Err: err}
 		}
 	}
 
 	// Extract price:
-	if priceIndex != -1 {
-		tmp = strings.TrimSpace(dlList[priceIndex].Children[0].NodeValue)
+	if dd, ok := details["Kaina mėn."]; ok {
+		tmp = detailValue(dd)
 		tmp = strings.ReplaceAll(tmp, " ", "")
 		tmp = strings.ReplaceAll(tmp, "€", "")
 		p.Price, err = strconv.Atoi(tmp)
 		if err != nil {
-			log.Println("failed to extract Price number from 'aruodas' post")
-			return
+			return nil, &website.LayoutError{URL: p.Link, Field: "Price", Err: err}
 		}
 	}
 
 	// Extract rooms:
-	if roomsIndex != -1 {
-		tmp = strings.TrimSpace(dlList[roomsIndex].Children[0].NodeValue)
-		p.Rooms, err = strconv.Atoi(tmp)
+	if dd, ok := details["Kambarių sk."]; ok {
+		p.Rooms, err = strconv.Atoi(detailValue(dd))
 		if err != nil {
-			log.Println("failed to extract Rooms number from 'aruodas' post")
-			return
+			return nil, &website.LayoutError{URL: p.Link, Field: "Rooms", Err: err}
 		}
 	}
 
 	// Extract year:
-	if yearIndex != -1 {
-		tmp = strings.TrimSpace(dlList[yearIndex].Children[0].NodeValue)
+	if dd, ok := details["Metai"]; ok {
+		tmp = detailValue(dd)
 		if strings.Contains(tmp, " ") {
 			tmp = strings.Split(tmp, " ")[0]
 		}
 		p.Year, err = strconv.Atoi(tmp)
 		if err != nil {
-			log.Println("failed to extract Year number from 'aruodas' post")
-			return
+			return nil, &website.LayoutError{URL: p.Link, Field: "Year", Err: err}
 		}
 	}
 
 	p.TrimFields()
-	posts = append(posts, p)
+	return p, nil
 }
 
-func (obj *Aruodas) Retrieve(db *database.Database) []*website.Post {
-	posts := make([]*website.Post, 0)
+func (obj *Aruodas) List(ctx context.Context) ([]*website.Stub, error) {
+	link := obj.settings.ListingURL + obj.settings.SearchPath
 
-	//res, err := website.GetResponse(LINK)
-	var chromeRes, err = website.GetResponseChrome(LINK, "ul.search-result-list-v2 > li.result-item-v3:not([style='display: none'])")
+	doc, err := obj.settings.Client.GetDocumentChrome(ctx, link)
 	if err != nil {
-		return posts
+		return make([]*website.Stub, 0), err
 	}
+	return obj.parseListing(doc, link)
+}
 
-	for _, node := range chromeRes {
-		processItem(node, posts, db)
+// parseListing extracts stubs of visible posts from rendered search page.
+func (obj *Aruodas) parseListing(doc *goquery.Document, link string) ([]*website.Stub, error) {
+	stubs := make([]*website.Stub, 0)
+
+	items := doc.Find("ul.search-result-list-v2 > li.result-item-v3:not([style='display: none'])")
+	if items.Length() == 0 {
+		return stubs, &website.LayoutError{URL: link, Field: "posts list"}
 	}
 
-	return posts
+	var lastErr error
+	items.Each(func(i int, s *goquery.Selection) {
+		upstreamID, ok := s.Attr("data-id")
+		if !ok {
+			lastErr = &website.LayoutError{URL: link, Field: "post ID"}
+			return
+		}
+		stub := &website.Stub{ID: strings.ReplaceAll(upstreamID, "loadObject", "")}
+		stub.Link = obj.settings.BaseURL + "/" + stub.ID // https://aruodas.lt/4-919937
+		stubs = append(stubs, stub)
+	})
+
+	return stubs, lastErr
 }
 
 func init() {
-	website.Add("aruodas", &Aruodas{})
+	website.Add("aruodas", website.Portal{
+		BaseURL:     BASE_URL,
+		ListingURL:  LISTING_URL,
+		SearchPaths: searchPaths,
+		New: func(s *website.Settings) website.Website {
+			return &Aruodas{settings: s}
+		},
+	})
 }
@@ -0,0 +1,45 @@
+//go:build chrome
+// +build chrome
+
+package aruodas
+
+import (
+	"bbtmvbot/website/websitetest"
+	"context"
+	"strings"
+	"testing"
+)
+
+// TestAruodasChrome retrieves recorded pages using headless browser, so it
+// needs Chrome installed:
+//
+//	go test -tags chrome ./website/aruodas
+func TestAruodasChrome(t *testing.T) {
+	srv := websitetest.Serve(t, map[string]string{
+		"/":         "listing.html",
+		"/4-919937": "post.html",
+	})
+	// Headless browser does not use HTTP client, so it has to reach the server
+	w := &Aruodas{settings: websitetest.Settings(srv, srv.URL, srv.URL, searchPaths["vilnius"])}
+
+	stubs, err := w.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stubs) == 0 {
+		t.Fatal("No stubs found")
+	}
+
+	post, err := w.Fetch(context.Background(), stubs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Server address changes on every run
+	for _, stub := range stubs {
+		stub.Link = strings.TrimPrefix(stub.Link, srv.URL)
+	}
+	post.Link = strings.TrimPrefix(post.Link, srv.URL)
+	websitetest.Golden(t, "listing", stubs)
+	websitetest.Golden(t, "post", post)
+}
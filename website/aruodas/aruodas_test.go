@@ -0,0 +1,30 @@
+package aruodas
+
+import (
+	"bbtmvbot/website"
+	"bbtmvbot/website/websitetest"
+	"testing"
+)
+
+// Pages are rendered by headless browser, which is not always installed, so
+// recorded pages are parsed directly. Run tests with -tags chrome to retrieve
+// them using the browser too.
+func TestAruodas(t *testing.T) {
+	// Links are relative, like in the golden files of the browser test
+	w := &Aruodas{settings: &website.Settings{City: website.Cities["vilnius"]}}
+
+	stubs, err := w.parseListing(websitetest.Document(t, "listing.html"), LISTING_URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stubs) == 0 {
+		t.Fatal("No stubs found")
+	}
+
+	post, err := w.parsePost(websitetest.Document(t, "post.html"), "/4-919937")
+	if err != nil {
+		t.Fatal(err)
+	}
+	websitetest.Golden(t, "listing", stubs)
+	websitetest.Golden(t, "post", post)
+}
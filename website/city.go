@@ -0,0 +1,17 @@
+package website
+
+// City is where posts are searched for.
+type City struct {
+	// ID is used in config and by websites to find search page of the city
+	ID string
+	// Name is used in addresses
+	Name string
+}
+
+var Cities = map[string]*City{
+	"vilnius":  {ID: "vilnius", Name: "Vilnius"},
+	"kaunas":   {ID: "kaunas", Name: "Kaunas"},
+	"klaipeda": {ID: "klaipeda", Name: "Klaipėda"},
+}
+
+const DefaultCity = "vilnius"
@@ -0,0 +1,174 @@
+package website
+
+import (
+	"bbtmvbot/config"
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/chromedp"
+)
+
+const (
+	defaultTimeout         = 10 * time.Second
+	defaultUserAgent       = "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/98.0.4758.80 Safari/537.36"
+	defaultChromeUserAgent = "WebScraper 1.0"
+)
+
+// Client retrieves pages of a single portal, either over plain HTTP or using
+// headless browser.
+type Client struct {
+	HTTPClient *http.Client
+	UserAgent  string
+	Proxy      string
+}
+
+// NewClient creates client with given settings. Zero values mean defaults.
+func NewClient(c config.HTTP) (*Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if c.Proxy != "" {
+		proxyURL, err := url.Parse(c.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+
+	return &Client{
+		HTTPClient: &http.Client{Transport: transport, Timeout: timeout},
+		UserAgent:  c.UserAgent,
+		Proxy:      c.Proxy,
+	}, nil
+}
+
+// newChromeContext creates headless browser context that respects client's
+// proxy settings.
+func (c *Client) newChromeContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	cancelAllocator := func() {}
+	if c.Proxy != "" {
+		opts := append(chromedp.DefaultExecAllocatorOptions[:], chromedp.ProxyServer(c.Proxy))
+		ctx, cancelAllocator = chromedp.NewExecAllocator(ctx, opts...)
+	}
+
+	ctx, cancelBrowser := chromedp.NewContext(
+		ctx,
+		chromedp.WithLogf(log.Printf),
+	)
+	return ctx, func() {
+		cancelBrowser()
+		cancelAllocator()
+	}
+}
+
+func (c *Client) chromeUserAgent() string {
+	if c.UserAgent != "" {
+		return c.UserAgent
+	}
+	return defaultChromeUserAgent
+}
+
+// GetDocumentChrome opens given link in headless browser and parses the
+// rendered page as HTML document, so it can be scraped like a plain HTTP one.
+func (c *Client) GetDocumentChrome(ctx context.Context, link string) (*goquery.Document, error) {
+	ctx, cancel := c.newChromeContext(ctx)
+	defer cancel()
+
+	// create a timeout
+	ctx, cancel = context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	var html string
+	var err = chromedp.Run(ctx,
+		emulation.SetUserAgentOverride(c.chromeUserAgent()),
+		chromedp.Navigate(link),
+		chromedp.ScrollIntoView(`footer`),
+		// wait for element to be visible (ie, page is loaded)
+		chromedp.WaitVisible("body > div"),
+		chromedp.OuterHTML("html", &html),
+	)
+	if err != nil {
+		return nil, &NetworkError{URL: link, Err: err}
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		return nil, &DecodeError{URL: link, Err: err}
+	}
+	return doc, nil
+}
+
+func (c *Client) GetResponse(ctx context.Context, link string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", link, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	myURL, err := url.Parse(link)
+	if err != nil {
+		return nil, err
+	}
+
+	userAgent := c.UserAgent
+	if userAgent == "" {
+		userAgent = defaultUserAgent
+	}
+
+	req.Header.Set("Host", myURL.Host)
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.9")
+	req.Header.Set("Accept-Encoding", "gzip, deflate, br")
+
+	//var path = URLRegex.FindAllStringSubmatch(link, -1)
+	req.Header.Set("cache-control", "max-age=0")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, &NetworkError{URL: link, Err: err}
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 && resp.StatusCode < 400 {
+		linkURL, err := url.Parse(link)
+		if err != nil {
+			return nil, err
+		}
+		redirectURL, err := url.Parse(resp.Header.Get("Location"))
+		if err != nil {
+			return nil, &StatusError{URL: link, StatusCode: resp.StatusCode}
+		}
+		newLink := linkURL.ResolveReference(redirectURL)
+		return c.GetResponse(ctx, newLink.String())
+	}
+
+	return nil, &StatusError{URL: link, StatusCode: resp.StatusCode}
+}
+
+// GetDocument retrieves given link and parses it as HTML document.
+func (c *Client) GetDocument(ctx context.Context, link string) (*goquery.Document, error) {
+	res, err := c.GetResponse(ctx, link)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	doc, err := goquery.NewDocumentFromReader(res.Body)
+	if err != nil {
+		return nil, &DecodeError{URL: link, Err: err}
+	}
+	return doc, nil
+}
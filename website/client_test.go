@@ -0,0 +1,87 @@
+package website
+
+import (
+	"bbtmvbot/config"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientGetResponse(t *testing.T) {
+	var userAgent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get("User-Agent")
+		switch r.URL.Path {
+		case "/old":
+			http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+		case "/new":
+			w.Write([]byte("ok"))
+		default:
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(config.HTTP{UserAgent: "TestAgent/1.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := c.GetResponse(context.Background(), srv.URL+"/old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.Request.URL.Path != "/new" {
+		t.Errorf("Redirect was not followed, got: '%s'.", res.Request.URL)
+	}
+	if userAgent != "TestAgent/1.0" {
+		t.Errorf("Incorrect User-Agent, got: '%s', want: '%s'.", userAgent, "TestAgent/1.0")
+	}
+
+	_, err = c.GetResponse(context.Background(), srv.URL+"/broken")
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected status error with code 503, got: '%v'.", err)
+	}
+}
+
+func TestClientProxy(t *testing.T) {
+	var requested string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.String()
+		w.Write([]byte("ok"))
+	}))
+	defer proxy.Close()
+
+	c, err := NewClient(config.HTTP{Proxy: proxy.URL, Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := c.GetResponse(context.Background(), "http://portal.invalid/search?page=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if requested != "http://portal.invalid/search?page=1" {
+		t.Errorf("Request was not sent through proxy, got: '%s'.", requested)
+	}
+}
+
+func TestLink(t *testing.T) {
+	var data = []struct {
+		Provided string
+		Expected string
+	}{
+		{"https://www.rinka.lt/skelbimas/butas-id-1", "http://localhost:8080/skelbimas/butas-id-1"},
+		{"/skelbimas/butas-id-1?ref=2", "http://localhost:8080/skelbimas/butas-id-1?ref=2"},
+	}
+	for _, v := range data {
+		if res := Link("http://localhost:8080/", v.Provided); res != v.Expected {
+			t.Errorf("Result is incorrect, got: '%s', want: '%s'.", res, v.Expected)
+		}
+	}
+}
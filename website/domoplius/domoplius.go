@@ -1,11 +1,10 @@
 package domoplius
 
 import (
-	"bbtmvbot/database"
 	"bbtmvbot/website"
+	"context"
 	"encoding/base64"
-	"fmt"
-	"log"
+	"errors"
 	"regexp"
 	"strconv"
 	"strings"
@@ -13,175 +12,193 @@ import (
 	"github.com/PuerkitoBio/goquery"
 )
 
-type Domoplius struct{}
+type Domoplius struct {
+	settings *website.Settings
+}
+
+const BASE_URL = "https://domoplius.lt"
+const LISTING_URL = "https://m.domoplius.lt"
 
-const LINK = "https://m.domoplius.lt/skelbimai/butai?action_type=3&address_1=461&sell_price_from=&sell_price_to=&qt="
+// searchPaths contains search page of every supported city, which is
+// identified by municipality code. Codes of other cities are not verified
+// yet, so they are skipped unless their search_paths are configured.
+var searchPaths = map[string]string{
+	"vilnius": searchPath("461"),
+}
+
+func searchPath(region string) string {
+	return "/skelbimai/butai?action_type=3&address_1=" + region + "&sell_price_from=&sell_price_to=&qt="
+}
 
 var reExtractFloors = regexp.MustCompile(`(\d+), (\d+) `)
 
-func (obj *Domoplius) Retrieve(db *database.Database) []*website.Post {
-	posts := make([]*website.Post, 0)
+func (obj *Domoplius) List(ctx context.Context) ([]*website.Stub, error) {
+	stubs := make([]*website.Stub, 0)
+	link := obj.settings.ListingURL + obj.settings.SearchPath
 
-	res, err := website.GetResponse(LINK)
-	if err != nil {
-		return posts
-	}
-	defer res.Body.Close()
-	doc, err := goquery.NewDocumentFromReader(res.Body)
+	doc, err := obj.settings.Client.GetDocument(ctx, link)
 	if err != nil {
-		return posts
+		return stubs, err
 	}
 
-	doc.Find("ul.list > li[id^='ann_']").Each(func(i int, s *goquery.Selection) {
-		p := &website.Post{}
+	items := doc.Find("ul.list > li[id^='ann_']")
+	if items.Length() == 0 {
+		return stubs, &website.LayoutError{URL: link, Field: "posts list"}
+	}
 
+	var lastErr error
+	items.Each(func(i int, s *goquery.Selection) {
 		upstreamID, ok := s.Attr("id")
 		if !ok {
-			log.Println("Post ID is not found in 'domoplius' website")
+			lastErr = &website.LayoutError{URL: link, Field: "post ID"}
 			return
 		}
-		p.Link = "https://domoplius.lt/skelbimai/-" + strings.ReplaceAll(upstreamID, "ann_", "") + ".html" // https://domoplius.lt/skelbimai/-5806213.html
+		stub := &website.Stub{ID: strings.ReplaceAll(upstreamID, "ann_", "")}
+		stub.Link = obj.settings.BaseURL + "/skelbimai/-" + stub.ID + ".html" // https://domoplius.lt/skelbimai/-5806213.html
+		stub.Price = website.ListingPrice(s.Find(".price").Text())
+		stubs = append(stubs, stub)
+	})
 
-		if db.InDatabase(p.Link) {
-			return
-		}
+	return stubs, lastErr
+}
 
-		postRes, err := website.GetResponse(p.Link)
-		if err != nil {
-			return
-		}
-		defer postRes.Body.Close()
-		postDoc, err := goquery.NewDocumentFromReader(postRes.Body)
-		if err != nil {
-			return
-		}
+func (obj *Domoplius) Fetch(ctx context.Context, stub *website.Stub) (*website.Post, error) {
+	p := &website.Post{Link: stub.Link}
+
+	postDoc, err := obj.settings.Client.GetDocument(ctx, p.Link)
+	if err != nil {
+		return nil, err
+	}
 
-		// Extract phone:
-		tmp, exists := postDoc.Find("#phone_button_4 > span").Attr("data-value")
-		if exists {
-			p.Phone = domopliusDecodeNumber(tmp)
+	// Extract phone:
+	tmp, exists := postDoc.Find("#phone_button_4 > span").Attr("data-value")
+	if exists {
+		p.Phone, err = domopliusDecodeNumber(tmp)
+		if err != nil {
+			return nil, &website.DecodeError{URL: p.Link, Err: err}
 		}
+	}
 
-		// Extract description:
-		p.Description = postDoc.Find("div.container > div.group-comments").Text()
+	// Extract description:
+	p.Description = postDoc.Find("div.container > div.group-comments").Text()
 
-		// Extract address:
-		tmp = ""
-		postDoc.Find(".breadcrumb-item > a > span[itemprop=name]").Each(func(i int, selection *goquery.Selection) {
-			if i != 0 {
-				tmp += ", "
-			}
-			tmp += selection.Text()
-		})
+	// Extract address:
+	tmp = ""
+	postDoc.Find(".breadcrumb-item > a > span[itemprop=name]").Each(func(i int, selection *goquery.Selection) {
+		if i != 0 {
+			tmp += ", "
+		}
+		tmp += selection.Text()
+	})
+	if tmp != "" {
 		if tmp != "" {
-			if tmp != "" {
-				p.Address = tmp
-			}
+			p.Address = tmp
 		}
+	}
 
-		// Extract heating:
-		el := postDoc.Find(".view-field-title:contains(\"Šildymas:\")")
-		if el.Length() != 0 {
-			el = el.Parent()
-			el.Find("span").Remove()
-			p.Heating = el.Text()
-		}
+	// Extract heating:
+	el := postDoc.Find(".view-field-title:contains(\"Šildymas:\")")
+	if el.Length() != 0 {
+		el = el.Parent()
+		el.Find("span").Remove()
+		p.Heating = el.Text()
+	}
 
-		// Extract floor and floor total:
-		el = postDoc.Find(".view-field-title:contains(\"Aukštas:\")")
-		if el.Length() != 0 {
-			el = el.Parent()
-			el.Find("span").Remove()
-			tmp = strings.TrimSpace(el.Text())
-			arr := reExtractFloors.FindStringSubmatch(tmp)
-			p.Floor, _ = strconv.Atoi(tmp) // will be 0 on failure, will be number if success
-			if len(arr) == 3 {
-				p.Floor, err = strconv.Atoi(arr[1])
-				if err != nil {
-					log.Println("failed to extract Floor number from 'domoplius' post")
-					return
-				}
-				p.FloorTotal, err = strconv.Atoi(arr[2])
-				if err != nil {
-					log.Println("failed to extract FloorTotal number from 'domoplius' post")
-					return
-				}
+	// Extract floor and floor total:
+	el = postDoc.Find(".view-field-title:contains(\"Aukštas:\")")
+	if el.Length() != 0 {
+		el = el.Parent()
+		el.Find("span").Remove()
+		tmp = strings.TrimSpace(el.Text())
+		arr := reExtractFloors.FindStringSubmatch(tmp)
+		p.Floor, _ = strconv.Atoi(tmp) // will be 0 on failure, will be number if success
+		if len(arr) == 3 {
+			p.Floor, err = strconv.Atoi(arr[1])
+			if err != nil {
+				return nil, &website.LayoutError{URL: p.Link, Field: "Floor", Err: err}
 			}
-		}
-
-		// Extract area:
-		el = postDoc.Find(".view-field-title:contains(\"Buto plotas (kv. m):\")")
-		if el.Length() != 0 {
-			el = el.Parent()
-			el.Find("span").Remove()
-			tmp = el.Text()
-			tmp = strings.TrimSpace(tmp)
-			tmp = strings.Split(tmp, ".")[0]
-			p.Area, err = strconv.Atoi(tmp)
+			p.FloorTotal, err = strconv.Atoi(arr[2])
 			if err != nil {
-				log.Println("failed to extract Area number from 'domoplius' post")
-				return
+				return nil, &website.LayoutError{URL: p.Link, Field: "FloorTotal", Err: err}
 			}
 		}
+	}
 
-		// Extract price:
-		tmp = postDoc.Find(".field-price > .price-column > .h1").Text()
-		if tmp != "" {
-			tmp = strings.TrimSpace(tmp)
-			tmp = strings.ReplaceAll(tmp, " ", "")
-			tmp = strings.ReplaceAll(tmp, "€", "")
-			p.Price, err = strconv.Atoi(tmp)
-			if err != nil {
-				log.Println("failed to extract Price number from 'domoplius' post")
-				return
-			}
+	// Extract area:
+	el = postDoc.Find(".view-field-title:contains(\"Buto plotas (kv. m):\")")
+	if el.Length() != 0 {
+		el = el.Parent()
+		el.Find("span").Remove()
+		tmp = el.Text()
+		tmp = strings.TrimSpace(tmp)
+		tmp = strings.Split(tmp, ".")[0]
+		p.Area, err = strconv.Atoi(tmp)
+		if err != nil {
+			return nil, &website.LayoutError{URL: p.Link, Field: "Area", Err: err}
 		}
+	}
 
-		// Extract rooms:
-		el = postDoc.Find(".view-field-title:contains(\"Kambarių skaičius:\")")
-		if el.Length() != 0 {
-			el = el.Parent()
-			el.Find("span").Remove()
-			tmp = el.Text()
-			tmp = strings.TrimSpace(tmp)
-			p.Rooms, err = strconv.Atoi(tmp)
-			if err != nil {
-				log.Println("failed to extract Rooms number from 'domoplius' post")
-				return
-			}
+	// Extract price:
+	tmp = postDoc.Find(".field-price > .price-column > .h1").Text()
+	if tmp != "" {
+		tmp = strings.TrimSpace(tmp)
+		tmp = strings.ReplaceAll(tmp, " ", "")
+		tmp = strings.ReplaceAll(tmp, "€", "")
+		p.Price, err = strconv.Atoi(tmp)
+		if err != nil {
+			return nil, &website.LayoutError{URL: p.Link, Field: "Price", Err: err}
 		}
+	}
 
-		// Extract year:
-		el = postDoc.Find(".view-field-title:contains(\"Statybos metai:\")")
-		if el.Length() != 0 {
-			el = el.Parent()
-			el.Find("span").Remove()
-			tmp = el.Text()
-			tmp = strings.TrimSpace(tmp)
-			p.Year, err = strconv.Atoi(tmp)
-			if err != nil {
-				log.Println("failed to extract Year number from 'domoplius' post")
-				return
-			}
+	// Extract rooms:
+	el = postDoc.Find(".view-field-title:contains(\"Kambarių skaičius:\")")
+	if el.Length() != 0 {
+		el = el.Parent()
+		el.Find("span").Remove()
+		tmp = el.Text()
+		tmp = strings.TrimSpace(tmp)
+		p.Rooms, err = strconv.Atoi(tmp)
+		if err != nil {
+			return nil, &website.LayoutError{URL: p.Link, Field: "Rooms", Err: err}
 		}
+	}
 
-		p.TrimFields()
-		posts = append(posts, p)
-	})
+	// Extract year:
+	el = postDoc.Find(".view-field-title:contains(\"Statybos metai:\")")
+	if el.Length() != 0 {
+		el = el.Parent()
+		el.Find("span").Remove()
+		tmp = el.Text()
+		tmp = strings.TrimSpace(tmp)
+		p.Year, err = strconv.Atoi(tmp)
+		if err != nil {
+			return nil, &website.LayoutError{URL: p.Link, Field: "Year", Err: err}
+		}
+	}
 
-	return posts
+	p.TrimFields()
+	return p, nil
 }
 
-func domopliusDecodeNumber(str string) string {
+func domopliusDecodeNumber(str string) (string, error) {
+	if len(str) < 2 {
+		return "", errors.New("phone number is too short")
+	}
 	msg, err := base64.StdEncoding.DecodeString(str[2:])
 	if err != nil {
-		fmt.Printf("Error decoding string: %s ", err.Error())
-		return ""
+		return "", err
 	}
 
-	return string(msg)
+	return string(msg), nil
 }
 
 func init() {
-	website.Add("domoplius", &Domoplius{})
+	website.Add("domoplius", website.Portal{
+		BaseURL:     BASE_URL,
+		ListingURL:  LISTING_URL,
+		SearchPaths: searchPaths,
+		New: func(s *website.Settings) website.Website {
+			return &Domoplius{settings: s}
+		},
+	})
 }
@@ -1,6 +1,10 @@
 package domoplius
 
-import "testing"
+import (
+	"bbtmvbot/website/websitetest"
+	"context"
+	"testing"
+)
 
 type DomopliusData struct {
 	Provided string
@@ -20,8 +24,40 @@ var DomopliusTestData = []DomopliusData{
 
 func TestDomopliusDecodeNumber(t *testing.T) {
 	for _, v := range DomopliusTestData {
-		if res := domopliusDecodeNumber(v.Provided); res != v.Expected {
+		res, err := domopliusDecodeNumber(v.Provided)
+		if err != nil {
+			t.Errorf("Unexpected error for '%s': %s.", v.Provided, err)
+		}
+		if res != v.Expected {
 			t.Errorf("Result is incorrect, got: '%s', want: '%s'.", res, v.Expected)
 		}
 	}
 }
+
+func TestDomopliusDecodeNumberInvalid(t *testing.T) {
+	for _, v := range []string{"", "z", "zz%%%"} {
+		if _, err := domopliusDecodeNumber(v); err == nil {
+			t.Errorf("Expected error for '%s', got nil.", v)
+		}
+	}
+}
+
+func TestDomoplius(t *testing.T) {
+	srv := websitetest.Serve(t, map[string]string{
+		"/skelbimai/butai":         "listing.html",
+		"/skelbimai/-5806213.html": "post.html",
+	})
+	w := &Domoplius{settings: websitetest.Settings(srv, BASE_URL, LISTING_URL, searchPaths["vilnius"])}
+
+	stubs, err := w.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	websitetest.Golden(t, "listing", stubs)
+
+	post, err := w.Fetch(context.Background(), stubs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	websitetest.Golden(t, "post", post)
+}
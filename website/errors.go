@@ -0,0 +1,87 @@
+package website
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// NetworkError is returned when portal could not be reached at all (DNS,
+// connection, timeout, headless browser failures and so on).
+type NetworkError struct {
+	URL string
+	Err error
+}
+
+func (e *NetworkError) Error() string {
+	return "unable to reach " + e.URL + ": " + e.Err.Error()
+}
+
+func (e *NetworkError) Unwrap() error {
+	return e.Err
+}
+
+// StatusError is returned when portal responds with non-successful HTTP code.
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return e.URL + " returned HTTP code " + strconv.Itoa(e.StatusCode)
+}
+
+// LayoutError is returned when page does not look like it used to, e.g.
+// listing is empty or some field can no longer be parsed.
+type LayoutError struct {
+	URL   string
+	Field string
+	Err   error
+}
+
+func (e *LayoutError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("unexpected layout of %s: %s not found", e.URL, e.Field)
+	}
+	return fmt.Sprintf("unexpected layout of %s: failed to extract %s: %s", e.URL, e.Field, e.Err)
+}
+
+func (e *LayoutError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeError is returned when response body could not be decoded (broken
+// HTML/JSON, undecodable phone number etc.).
+type DecodeError struct {
+	URL string
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return "unable to decode " + e.URL + ": " + e.Err.Error()
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorKind returns short name of the error type, suitable for logging and
+// counting errors. Unknown errors are reported as "other".
+func ErrorKind(err error) string {
+	var networkErr *NetworkError
+	var statusErr *StatusError
+	var layoutErr *LayoutError
+	var decodeErr *DecodeError
+	switch {
+	case errors.As(err, &networkErr):
+		return "network"
+	case errors.As(err, &statusErr):
+		return "http_status"
+	case errors.As(err, &layoutErr):
+		return "layout"
+	case errors.As(err, &decodeErr):
+		return "decode"
+	default:
+		return "other"
+	}
+}
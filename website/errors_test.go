@@ -0,0 +1,26 @@
+package website
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestErrorKind(t *testing.T) {
+	var data = []struct {
+		Provided error
+		Expected string
+	}{
+		{&NetworkError{URL: "https://example.com", Err: errors.New("timeout")}, "network"},
+		{&StatusError{URL: "https://example.com", StatusCode: 503}, "http_status"},
+		{&LayoutError{URL: "https://example.com", Field: "Price"}, "layout"},
+		{&DecodeError{URL: "https://example.com", Err: errors.New("bad json")}, "decode"},
+		{fmt.Errorf("wrapped: %w", &StatusError{URL: "https://example.com", StatusCode: 404}), "http_status"},
+		{errors.New("something else"), "other"},
+	}
+	for _, v := range data {
+		if res := ErrorKind(v.Provided); res != v.Expected {
+			t.Errorf("Result is incorrect for '%s', got: '%s', want: '%s'.", v.Provided, res, v.Expected)
+		}
+	}
+}
@@ -1,15 +1,18 @@
 package kampas
 
 import (
-	"bbtmvbot/database"
 	"bbtmvbot/website"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"strconv"
 	"strings"
 )
 
-type Kampas struct{}
+type Kampas struct {
+	settings *website.Settings
+}
 
 type kampasPosts struct {
 	Hits []struct {
@@ -26,33 +29,42 @@ type kampasPosts struct {
 	} `json:"hits"`
 }
 
-const LINK = "https://www.kampas.lt/api/classifieds/search-new?query={%22municipality%22%3A%2258%22%2C%22settlement%22%3A19220%2C%22page%22%3A1%2C%22sort%22%3A%22new%22%2C%22section%22%3A%22bustas-nuomai%22%2C%22type%22%3A%22flat%22}"
+const BASE_URL = "https://www.kampas.lt"
+
+// searchPaths contains search page of every supported city. Only Vilnius is
+// supported, other cities are skipped unless their search_paths are
+// configured.
+var searchPaths = map[string]string{
+	"vilnius": "/api/classifieds/search-new?query={%22municipality%22%3A%2258%22%2C%22settlement%22%3A19220%2C%22page%22%3A1%2C%22sort%22%3A%22new%22%2C%22section%22%3A%22bustas-nuomai%22%2C%22type%22%3A%22flat%22}",
+}
 
-func (obj *Kampas) Retrieve(db *database.Database) []*website.Post {
-	posts := make([]*website.Post, 0)
+func (obj *Kampas) List(ctx context.Context) ([]*website.Stub, error) {
+	stubs := make([]*website.Stub, 0)
+	link := obj.settings.ListingURL + obj.settings.SearchPath
 
-	res, err := website.GetResponse(LINK)
+	res, err := obj.settings.Client.GetResponse(ctx, link)
 	if err != nil {
-		return posts
+		return stubs, err
 	}
 	defer res.Body.Close()
 
 	contents, err := ioutil.ReadAll(res.Body)
 	if err != nil {
-		return posts
+		return stubs, &website.NetworkError{URL: link, Err: err}
 	}
 
 	var results kampasPosts
-	json.Unmarshal(contents, &results)
+	if err = json.Unmarshal(contents, &results); err != nil {
+		return stubs, &website.DecodeError{URL: link, Err: err}
+	}
+	if len(results.Hits) == 0 {
+		return stubs, &website.LayoutError{URL: link, Field: "hits"}
+	}
 
 	for _, v := range results.Hits {
 		p := &website.Post{}
 
-		p.Link = fmt.Sprintf("https://www.kampas.lt/skelbimai/%d", v.ID) // https://www.kampas.lt/skelbimai/504506
-
-		if db.InDatabase(p.Link) {
-			return posts
-		}
+		p.Link = fmt.Sprintf("%s/skelbimai/%d", obj.settings.BaseURL, v.ID) // https://www.kampas.lt/skelbimai/504506
 
 		// Extract heating
 		for _, feature := range v.Features {
@@ -76,12 +88,30 @@ func (obj *Kampas) Retrieve(db *database.Database) []*website.Post {
 		p.Year = v.Yearbuilt
 
 		p.TrimFields()
-		posts = append(posts, p)
+		stubs = append(stubs, &website.Stub{
+			ID:    strconv.Itoa(v.ID),
+			Link:  p.Link,
+			Price: p.Price,
+			Rooms: p.Rooms,
+			Post:  p,
+		})
 	}
 
-	return posts
+	return stubs, nil
+}
+
+// Fetch returns post parsed in List, because kampas API already returns all
+// the details in search results.
+func (obj *Kampas) Fetch(ctx context.Context, stub *website.Stub) (*website.Post, error) {
+	return stub.Post, nil
 }
 
 func init() {
-	website.Add("kampas", &Kampas{})
+	website.Add("kampas", website.Portal{
+		BaseURL:     BASE_URL,
+		SearchPaths: searchPaths,
+		New: func(s *website.Settings) website.Website {
+			return &Kampas{settings: s}
+		},
+	})
 }
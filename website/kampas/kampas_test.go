@@ -0,0 +1,26 @@
+package kampas
+
+import (
+	"bbtmvbot/website/websitetest"
+	"context"
+	"testing"
+)
+
+func TestKampas(t *testing.T) {
+	srv := websitetest.Serve(t, map[string]string{
+		"/api/classifieds/search-new": "search.json",
+	})
+	w := &Kampas{settings: websitetest.Settings(srv, BASE_URL, BASE_URL, searchPaths["vilnius"])}
+
+	stubs, err := w.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	websitetest.Golden(t, "listing", stubs)
+
+	post, err := w.Fetch(context.Background(), stubs[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	websitetest.Golden(t, "post", post)
+}
@@ -1,157 +1,166 @@
 package nuomininkai
 
 import (
-	"bbtmvbot/database"
 	"bbtmvbot/website"
-	"log"
+	"context"
 	"strconv"
 	"strings"
 
 	"github.com/PuerkitoBio/goquery"
 )
 
-type Nuomininkai struct{}
+type Nuomininkai struct {
+	settings *website.Settings
+}
 
-const LINK = "https://nuomininkai.lt/paieska/?propery_type=butu-nuoma&propery_contract_type=&propery_location=461&imic_property_district=&new_quartals=&min_price=&max_price=&min_price_meter=&max_price_meter=&min_area=&max_area=&rooms_from=&rooms_to=&high_from=&high_to=&floor_type=&irengimas=&building_type=&house_year_from=&house_year_to=&zm_skaicius=&lot_size_from=&lot_size_to=&by_date="
+const BASE_URL = "https://nuomininkai.lt"
 
-func (obj *Nuomininkai) Retrieve(db *database.Database) []*website.Post {
-	posts := make([]*website.Post, 0)
+// searchPaths contains search page of every supported city, which is
+// identified by municipality code. Codes of other cities are not verified
+// yet, so they are skipped unless their search_paths are configured.
+var searchPaths = map[string]string{
+	"vilnius": searchPath("461"),
+}
 
-	res, err := website.GetResponse(LINK)
-	if err != nil {
-		return posts
-	}
-	defer res.Body.Close()
-	doc, err := goquery.NewDocumentFromReader(res.Body)
+func searchPath(region string) string {
+	return "/paieska/?propery_type=butu-nuoma&propery_contract_type=&propery_location=" + region + "&imic_property_district=&new_quartals=&min_price=&max_price=&min_price_meter=&max_price_meter=&min_area=&max_area=&rooms_from=&rooms_to=&high_from=&high_to=&floor_type=&irengimas=&building_type=&house_year_from=&house_year_to=&zm_skaicius=&lot_size_from=&lot_size_to=&by_date="
+}
+
+func (obj *Nuomininkai) List(ctx context.Context) ([]*website.Stub, error) {
+	stubs := make([]*website.Stub, 0)
+	link := obj.settings.ListingURL + obj.settings.SearchPath
+
+	doc, err := obj.settings.Client.GetDocument(ctx, link)
 	if err != nil {
-		return posts
+		return stubs, err
 	}
 
-	doc.Find("div.property-listing > ul > li.property_element").Each(func(i int, s *goquery.Selection) {
-		p := &website.Post{}
+	items := doc.Find("div.property-listing > ul > li.property_element")
+	if items.Length() == 0 {
+		return stubs, &website.LayoutError{URL: link, Field: "posts list"}
+	}
 
+	var lastErr error
+	items.Each(func(i int, s *goquery.Selection) {
 		upstreamID, exists := s.Find("h3 > a").Attr("href")
 		if !exists {
-			log.Println("unable to find 'id' of the post in 'nuomininkai' portal")
+			lastErr = &website.LayoutError{URL: link, Field: "post ID"}
 			return
 		}
-		p.Link = upstreamID // https://nuomininkai.lt/skelbimas/vilniaus-m-sav-vilniaus-m-pilaite-i-kanto-al-isnuomojamas-1-kambario-butas-pilaiteje/
+		stub := &website.Stub{ID: upstreamID}
+		stub.Link = website.Link(obj.settings.BaseURL, upstreamID) // https://nuomininkai.lt/skelbimas/vilniaus-m-sav-vilniaus-m-pilaite-i-kanto-al-isnuomojamas-1-kambario-butas-pilaiteje/
+		stubs = append(stubs, stub)
+	})
 
-		if db.InDatabase(p.Link) {
-			return
-		}
+	return stubs, lastErr
+}
 
-		postRes, err := website.GetResponse(p.Link)
-		if err != nil {
-			return
-		}
-		defer postRes.Body.Close()
-		postDoc, err := goquery.NewDocumentFromReader(postRes.Body)
+func (obj *Nuomininkai) Fetch(ctx context.Context, stub *website.Stub) (*website.Post, error) {
+	p := &website.Post{Link: stub.Link}
+
+	postDoc, err := obj.settings.Client.GetDocument(ctx, p.Link)
+	if err != nil {
+		return nil, err
+	}
+
+	var tmp string
+
+	// Extract phone:
+	el := postDoc.Find("h4 > i.fa-mobile").Parent()
+	el.Find("i").Remove()
+	p.Phone = el.Text()
+
+	// Extract description:
+	// Extracts together with details table, but we dont care since
+	// we dont store description anyway...
+	p.Description = postDoc.Find("#description").Text()
+
+	// Extract address:
+	detailsElement := postDoc.Find("#description > table.table-details")
+	addrState := detailsElement.Find("td.table-details-name:contains(\"Mikrorajonas\")").Next().Text()
+	addrStreet := detailsElement.Find("td.table-details-name:contains(\"Adresas\")").Next().Text()
+	addrState = strings.TrimSpace(addrState)
+	addrStreet = strings.TrimSpace(addrStreet)
+	p.Address = website.CompileAddress(obj.settings.City.Name, addrState, addrStreet)
+
+	// Extract heating:
+	// Not possible
+
+	// Extract floor:
+	tmp = detailsElement.Find("td.table-details-name:contains(\"Aukštas\")").Next().Text()
+	if tmp != "" {
+		tmp = strings.TrimSpace(tmp)
+		p.Floor, err = strconv.Atoi(tmp)
 		if err != nil {
-			return
+			return nil, &website.LayoutError{URL: p.Link, Field: "Floor", Err: err}
 		}
+	}
 
-		var tmp string
-
-		// Extract phone:
-		el := postDoc.Find("h4 > i.fa-mobile").Parent()
-		el.Find("i").Remove()
-		p.Phone = el.Text()
-
-		// Extract description:
-		// Extracts together with details table, but we dont care since
-		// we dont store description anyway...
-		p.Description = postDoc.Find("#description").Text()
-
-		// Extract address:
-		detailsElement := postDoc.Find("#description > table.table-details")
-		addrState := detailsElement.Find("td.table-details-name:contains(\"Mikrorajonas\")").Next().Text()
-		addrStreet := detailsElement.Find("td.table-details-name:contains(\"Adresas\")").Next().Text()
-		addrState = strings.TrimSpace(addrState)
-		addrStreet = strings.TrimSpace(addrStreet)
-		p.Address = website.CompileAddress(addrState, addrStreet)
-
-		// Extract heating:
-		// Not possible
-
-		// Extract floor:
-		tmp = detailsElement.Find("td.table-details-name:contains(\"Aukštas\")").Next().Text()
-		if tmp != "" {
-			tmp = strings.TrimSpace(tmp)
-			p.Floor, err = strconv.Atoi(tmp)
-			if err != nil {
-				log.Println("failed to extract Floor number from 'nuomininkai' post")
-				return
-			}
+	// Extract floor total:
+	tmp = detailsElement.Find("td.table-details-name:contains(\"Aukštų sk.\")").Next().Text()
+	if tmp != "" {
+		tmp = strings.TrimSpace(tmp)
+		p.FloorTotal, err = strconv.Atoi(tmp)
+		if err != nil {
+			return nil, &website.LayoutError{URL: p.Link, Field: "FloorTotal", Err: err}
 		}
+	}
 
-		// Extract floor total:
-		tmp = detailsElement.Find("td.table-details-name:contains(\"Aukštų sk.\")").Next().Text()
-		if tmp != "" {
-			tmp = strings.TrimSpace(tmp)
-			p.FloorTotal, err = strconv.Atoi(tmp)
-			if err != nil {
-				log.Println("failed to extract FloorTotal number from 'nuomininkai' post")
-				return
-			}
+	// Extract area:
+	tmp = detailsElement.Find("td.table-details-name:contains(\"Plotas\")").Next().Text()
+	if tmp != "" {
+		tmp = strings.TrimSpace(tmp)
+		if strings.Contains(tmp, ".") {
+			tmp = strings.Split(tmp, ".")[0]
 		}
-
-		// Extract area:
-		tmp = detailsElement.Find("td.table-details-name:contains(\"Plotas\")").Next().Text()
-		if tmp != "" {
-			tmp = strings.TrimSpace(tmp)
-			if strings.Contains(tmp, ".") {
-				tmp = strings.Split(tmp, ".")[0]
-			}
-			p.Area, err = strconv.Atoi(tmp)
-			if err != nil {
-				log.Println("failed to extract Area number from 'nuomininkai' post")
-				return
-			}
+		p.Area, err = strconv.Atoi(tmp)
+		if err != nil {
+			return nil, &website.LayoutError{URL: p.Link, Field: "Area", Err: err}
 		}
+	}
 
-		// Extract price:
-		tmp = detailsElement.Find("td.table-details-name:contains(\"Kaina\")").Next().Text()
-		if tmp != "" {
-			tmp = strings.TrimSpace(tmp)
-			tmp = strings.ReplaceAll(tmp, " ", "")
-			tmp = strings.ReplaceAll(tmp, "€", "")
-			p.Price, err = strconv.Atoi(tmp)
-			if err != nil {
-				log.Println("failed to extract Price number from 'nuomininkai' post")
-				return
-			}
+	// Extract price:
+	tmp = detailsElement.Find("td.table-details-name:contains(\"Kaina\")").Next().Text()
+	if tmp != "" {
+		tmp = strings.TrimSpace(tmp)
+		tmp = strings.ReplaceAll(tmp, " ", "")
+		tmp = strings.ReplaceAll(tmp, "€", "")
+		p.Price, err = strconv.Atoi(tmp)
+		if err != nil {
+			return nil, &website.LayoutError{URL: p.Link, Field: "Price", Err: err}
 		}
+	}
 
-		// Extract rooms:
-		tmp = detailsElement.Find("td.table-details-name:contains(\"Kambarių skaičius\")").Next().Text()
-		if tmp != "" {
-			tmp = strings.TrimSpace(tmp)
-			p.Rooms, err = strconv.Atoi(tmp)
-			if err != nil {
-				log.Println("failed to extract Rooms number from 'nuomininkai' post")
-				return
-			}
+	// Extract rooms:
+	tmp = detailsElement.Find("td.table-details-name:contains(\"Kambarių skaičius\")").Next().Text()
+	if tmp != "" {
+		tmp = strings.TrimSpace(tmp)
+		p.Rooms, err = strconv.Atoi(tmp)
+		if err != nil {
+			return nil, &website.LayoutError{URL: p.Link, Field: "Rooms", Err: err}
 		}
+	}
 
-		// Extract year:
-		tmp = detailsElement.Find("td.table-details-name:contains(\"Metai\")").Next().Text()
-		if tmp != "" {
-			tmp = strings.TrimSpace(tmp)
-			p.Year, err = strconv.Atoi(tmp)
-			if err != nil {
-				log.Println("failed to extract Year number from 'nuomininkai' post")
-				return
-			}
+	// Extract year:
+	tmp = detailsElement.Find("td.table-details-name:contains(\"Metai\")").Next().Text()
+	if tmp != "" {
+		tmp = strings.TrimSpace(tmp)
+		p.Year, err = strconv.Atoi(tmp)
+		if err != nil {
+			return nil, &website.LayoutError{URL: p.Link, Field: "Year", Err: err}
 		}
+	}
 
-		p.TrimFields()
-		posts = append(posts, p)
-	})
-
-	return posts
+	p.TrimFields()
+	return p, nil
 }
 
 func init() {
-	website.Add("nuomininkai", &Nuomininkai{})
+	website.Add("nuomininkai", website.Portal{
+		BaseURL:     BASE_URL,
+		SearchPaths: searchPaths,
+		New: func(s *website.Settings) website.Website {
+			return &Nuomininkai{settings: s}
+		},
+	})
 }
@@ -0,0 +1,27 @@
+package nuomininkai
+
+import (
+	"bbtmvbot/website/websitetest"
+	"context"
+	"testing"
+)
+
+func TestNuomininkai(t *testing.T) {
+	srv := websitetest.Serve(t, map[string]string{
+		"/paieska/": "listing.html",
+		"/skelbimas/vilniaus-m-sav-vilniaus-m-zirmunai-kalvariju-g-isnuomojamas-2-kambariu-butas/": "post.html",
+	})
+	w := &Nuomininkai{settings: websitetest.Settings(srv, BASE_URL, BASE_URL, searchPaths["vilnius"])}
+
+	stubs, err := w.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	websitetest.Golden(t, "listing", stubs)
+
+	post, err := w.Fetch(context.Background(), stubs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	websitetest.Golden(t, "post", post)
+}
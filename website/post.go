@@ -19,6 +19,11 @@ type Post struct {
 	Price       int
 	Rooms       int
 	Year        int
+
+	// Source is name of the website post was found in
+	Source string
+	// City is ID of the city, whose search page the post was found in
+	City string
 }
 
 // Ensure these are lowercase
@@ -54,9 +59,14 @@ var lithuanianReplacer = strings.NewReplacer(
 	"y", "i", // Replace y with i, because some people are bad at writting
 )
 
+// Normalize lowercases text and replaces Lithuanian letters, so it can be
+// compared regardless of how it was written.
+func Normalize(text string) string {
+	return lithuanianReplacer.Replace(strings.ToLower(text))
+}
+
 func (p *Post) IsWithFee() bool {
-	processedDescription := strings.ToLower(p.Description)
-	processedDescription = lithuanianReplacer.Replace(processedDescription)
+	processedDescription := Normalize(p.Description)
 
 	// Check against keywords
 	for _, v := range feeKeywords {
@@ -127,6 +137,111 @@ func (p *Post) FormatTelegramMessage(IDInDatabase int64) string {
 	return sb.String()
 }
 
+// Street types used by portals, optionally followed by house number
+var reStreet = regexp.MustCompile(`(?i)\s(g|gatvė|al|alėja|pr|prospektas|pl|plentas|skg|skersgatvis|tak|takas|kel|kelias|krant|krantinė|a|aikštė)\.?(\s+\d+\S*)?$`)
+
+// District returns district part of the address, which follows the city, or
+// empty string if address does not contain it, e.g. "Vilnius, Kalvarijų g.".
+func (p *Post) District() string {
+	parts := strings.Split(p.Address, ",")
+	if len(parts) < 2 {
+		return ""
+	}
+	district := strings.TrimSpace(parts[1])
+	if reStreet.MatchString(district) {
+		return ""
+	}
+	return district
+}
+
+// FormatPriceDropMessage formats message about lowered price of the post
+// already stored in database.
+func (p *Post) FormatPriceDropMessage(IDInDatabase int64, oldPrice int) string {
+	return fmt.Sprintf("📉 *Price dropped from %d€ to %d€*\n", oldPrice, p.Price) + p.FormatTelegramMessage(IDInDatabase)
+}
+
+// FormatRelistedMessage formats message about post that was posted again
+// under a new link. Old price is included only if it changed.
+func (p *Post) FormatRelistedMessage(IDInDatabase, originalID int64, oldPrice int) string {
+	header := fmt.Sprintf("🔁 *Relisted, originally %d.*\n", originalID)
+	if oldPrice != 0 && oldPrice != p.Price {
+		header = fmt.Sprintf("🔁 *Relisted, originally %d. with price %d€*\n", originalID, oldPrice)
+	}
+	return header + p.FormatTelegramMessage(IDInDatabase)
+}
+
+var reHouseNumber = regexp.MustCompile(`\s\d+\S*$`)
+
+// HasHouseNumber reports whether address includes house number, so it
+// identifies a single building rather than the whole street.
+func (p *Post) HasHouseNumber() bool {
+	parts := strings.Split(p.Address, ",")
+	if len(parts) < 2 {
+		return false
+	}
+	return reHouseNumber.MatchString(strings.TrimSpace(parts[len(parts)-1]))
+}
+
+// NormalizedPhone returns phone number in international format, so numbers
+// written differently by websites can be compared.
+func (p *Post) NormalizedPhone() string {
+	return cleanupPhoneNumber(p.Phone)
+}
+
+var reNonLetters = regexp.MustCompile(`[^a-z]+`)
+
+// Fingerprint identifies the same flat posted in different websites. It is
+// built from normalized phone, area, rooms, floor and street, ignoring house
+// number, as websites format addresses differently. Empty fingerprint means
+// that post has too little details to be compared.
+func (p *Post) Fingerprint() string {
+	phone := cleanupPhoneNumber(p.Phone)
+	if phone == "" || p.Area == 0 {
+		return ""
+	}
+
+	// Street is the last part of address
+	parts := strings.Split(p.Address, ",")
+	street := Normalize(parts[len(parts)-1])
+	street = strings.TrimSuffix(strings.TrimSpace(reNonLetters.ReplaceAllString(street, " ")), " g")
+	street = strings.ReplaceAll(street, " ", "")
+
+	return fmt.Sprintf("%s|%d|%d|%d|%s", phone, p.Area, p.Rooms, p.Floor, street)
+}
+
+// FormatAlsoOn formats list of websites, where the same flat is posted too.
+func FormatAlsoOn(duplicates []*Post) string {
+	if len(duplicates) == 0 {
+		return ""
+	}
+	links := make([]string, 0, len(duplicates))
+	for _, d := range duplicates {
+		links = append(links, fmt.Sprintf("[%s](%s)", d.Source, d.Link))
+	}
+	return "» *Also on:* " + strings.Join(links, ", ") + "\n"
+}
+
+// FormatDigestLine formats post as a single line of a digest message.
+func (p *Post) FormatDigestLine(IDInDatabase int64) string {
+	title := p.Address
+	if title == "" {
+		title = p.Source
+	}
+	if title == "" {
+		title = "link"
+	}
+	details := []string{fmt.Sprintf("%d€", p.Price)}
+	if p.Rooms != 0 {
+		details = append(details, fmt.Sprintf("%d rooms", p.Rooms))
+	}
+	if p.Area != 0 {
+		details = append(details, fmt.Sprintf("%dm²", p.Area))
+	}
+	// Square brackets would break the link
+	title = strings.NewReplacer("[", "(", "]", ")").Replace(title)
+	return fmt.Sprintf("%d. [%s](%s) `%s`\n", IDInDatabase, title, p.Link, strings.Join(details, ", "))
+}
+
 func (p *Post) TrimFields() {
 	p.Address = strings.TrimSpace(p.Address)
 	p.Heating = strings.TrimSpace(p.Heating)
@@ -326,3 +326,114 @@ func TestPhoneCleanup(t *testing.T) {
 		}
 	}
 }
+
+func TestFormatRelistedMessage(t *testing.T) {
+	p := &Post{Link: "https://example.com/2", Price: 420}
+	var data = []struct {
+		OldPrice int
+		Expected string
+	}{
+		{420, "🔁 *Relisted, originally 7.*\n"},
+		{450, "🔁 *Relisted, originally 7. with price 450€*\n"},
+	}
+	for _, v := range data {
+		if res := p.FormatRelistedMessage(9, 7, v.OldPrice); !strings.HasPrefix(res, v.Expected+"9. https://example.com/2\n") {
+			t.Errorf("Result is incorrect, got: '%s', want prefix: '%s'.", res, v.Expected)
+		}
+	}
+}
+
+var FingerprintData = []struct {
+	Provided Post
+	Expected string
+}{
+	{
+		Provided: Post{Phone: "+37061234567", Area: 45, Rooms: 2, Floor: 3, Address: "Vilnius, Žirmūnai, Kalvarijų g."},
+		Expected: "+37061234567|45|2|3|kalvariju",
+	},
+	{
+		Provided: Post{Phone: "861234567", Area: 45, Rooms: 2, Floor: 3, Address: "Vilnius,  Žirmūnai,  Kalvarijų g. 12"},
+		Expected: "+37061234567|45|2|3|kalvariju",
+	},
+	{
+		Provided: Post{Phone: "+37060000000", Area: 30, Rooms: 1, Floor: 8, Address: "Vilnius, Fabijoniškės, S. Stanevičiaus g. 12"},
+		Expected: "+37060000000|30|1|8|sstaneviciaus",
+	},
+	{
+		Provided: Post{Area: 45, Rooms: 2, Floor: 3, Address: "Vilnius, Žirmūnai, Kalvarijų g."},
+		Expected: "",
+	},
+	{
+		Provided: Post{Phone: "+37061234567", Rooms: 2, Floor: 3, Address: "Vilnius, Žirmūnai, Kalvarijų g."},
+		Expected: "",
+	},
+}
+
+func TestFingerprint(t *testing.T) {
+	for _, v := range FingerprintData {
+		if res := v.Provided.Fingerprint(); res != v.Expected {
+			t.Errorf("Result is incorrect, got: '%s', want: '%s'.", res, v.Expected)
+		}
+	}
+}
+
+func TestDistrict(t *testing.T) {
+	var data = []struct {
+		Provided string
+		Expected string
+	}{
+		{"Vilnius, Žirmūnai, Kalvarijų g.", "Žirmūnai"},
+		{"Vilnius,  Žirmūnai,  Kalvarijų g. 12", "Žirmūnai"},
+		{"Vilnius, Žirmūnai", "Žirmūnai"},
+		{"Vilnius, Kalvarijų g.", ""},
+		{"Vilnius, Kalvarijų g. 12", ""},
+		{"Vilnius, Konstitucijos pr.", ""},
+		{"Vilnius, Naujamiestis, Vilniaus g.", "Naujamiestis"},
+		{"Vilnius", ""},
+		{"", ""},
+	}
+	for _, v := range data {
+		p := &Post{Address: v.Provided}
+		if res := p.District(); res != v.Expected {
+			t.Errorf("Result is incorrect, got: '%s', want: '%s'.", res, v.Expected)
+		}
+	}
+}
+
+func TestFormatDigestLine(t *testing.T) {
+	var data = []struct {
+		Provided Post
+		Expected string
+	}{
+		{Post{Link: "https://example.com/1", Address: "Vilnius, Žirmūnai, Kalvarijų g.", Price: 420, Rooms: 2, Area: 45}, "7. [Vilnius, Žirmūnai, Kalvarijų g.](https://example.com/1) `420€, 2 rooms, 45m²`\n"},
+		{Post{Link: "https://example.com/1", Source: "skelbiu", Price: 300}, "7. [skelbiu](https://example.com/1) `300€`\n"},
+		{Post{Link: "https://example.com/1", Address: "Vilnius [centras]", Price: 300, Area: 20}, "7. [Vilnius (centras)](https://example.com/1) `300€, 20m²`\n"},
+		{Post{Link: "https://example.com/1", Price: 300, Rooms: 1}, "7. [link](https://example.com/1) `300€, 1 rooms`\n"},
+	}
+	for _, v := range data {
+		if res := v.Provided.FormatDigestLine(7); res != v.Expected {
+			t.Errorf("Result is incorrect, got: '%s', want: '%s'.", res, v.Expected)
+		}
+	}
+}
+
+func TestHasHouseNumber(t *testing.T) {
+	var data = []struct {
+		Address  string
+		Expected bool
+	}{
+		{"Vilnius, Žirmūnai, Kalvarijų g. 12", true},
+		{"Vilnius, Žirmūnai, Kalvarijų g. 12A", true},
+		{"Vilnius, Naujamiestis, Naugarduko g. 3-5", true},
+		{"Vilnius, Žirmūnai, Kalvarijų g.", false},
+		{"Vilnius, Žirmūnai", false},
+		{"Vilnius", false},
+		{"", false},
+	}
+	for _, v := range data {
+		p := &Post{Address: v.Address}
+		if got := p.HasHouseNumber(); got != v.Expected {
+			t.Errorf("Result of '%s' is incorrect, got: '%t', want: '%t'.", v.Address, got, v.Expected)
+		}
+	}
+}
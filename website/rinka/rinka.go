@@ -1,9 +1,8 @@
 package rinka
 
 import (
-	"bbtmvbot/database"
 	"bbtmvbot/website"
-	"log"
+	"context"
 	"regexp"
 	"strconv"
 	"strings"
@@ -11,150 +10,157 @@ import (
 	"github.com/PuerkitoBio/goquery"
 )
 
-type Rinka struct{}
+type Rinka struct {
+	settings *website.Settings
+}
+
+const BASE_URL = "https://www.rinka.lt"
 
-const LINK = "https://www.rinka.lt/nekilnojamojo-turto-skelbimai/butu-nuoma?filter%5BKainaForAll%5D%5Bmin%5D=&filter%5BKainaForAll%5D%5Bmax%5D=&filter%5BNTnuomakambariuskaiciusButai%5D%5Bmin%5D=&filter%5BNTnuomakambariuskaiciusButai%5D%5Bmax%5D=&filter%5BNTnuomabendrasplotas%5D%5Bmin%5D=&filter%5BNTnuomabendrasplotas%5D%5Bmax%5D=&filter%5BNTnuomastatybosmetai%5D%5Bmin%5D=&filter%5BNTnuomastatybosmetai%5D%5Bmax%5D=&filter%5BNTnuomaaukstuskaicius%5D%5Bmin%5D=&filter%5BNTnuomaaukstuskaicius%5D%5Bmax%5D=&filter%5BNTnuomaaukstas%5D%5Bmin%5D=&filter%5BNTnuomaaukstas%5D%5Bmax%5D=&cities%5B0%5D=2&cities%5B1%5D=3"
+// searchPaths contains search page of every supported city. Only Vilnius is
+// supported, other cities are skipped unless their search_paths are
+// configured.
+var searchPaths = map[string]string{
+	"vilnius": "/nekilnojamojo-turto-skelbimai/butu-nuoma?filter%5BKainaForAll%5D%5Bmin%5D=&filter%5BKainaForAll%5D%5Bmax%5D=&filter%5BNTnuomakambariuskaiciusButai%5D%5Bmin%5D=&filter%5BNTnuomakambariuskaiciusButai%5D%5Bmax%5D=&filter%5BNTnuomabendrasplotas%5D%5Bmin%5D=&filter%5BNTnuomabendrasplotas%5D%5Bmax%5D=&filter%5BNTnuomastatybosmetai%5D%5Bmin%5D=&filter%5BNTnuomastatybosmetai%5D%5Bmax%5D=&filter%5BNTnuomaaukstuskaicius%5D%5Bmin%5D=&filter%5BNTnuomaaukstuskaicius%5D%5Bmax%5D=&filter%5BNTnuomaaukstas%5D%5Bmin%5D=&filter%5BNTnuomaaukstas%5D%5Bmax%5D=&cities%5B0%5D=2&cities%5B1%5D=3",
+}
 
 var rePrice = regexp.MustCompile(`Kaina: ([\d,]+),\d+ €`)
 
-func (obj *Rinka) Retrieve(db *database.Database) []*website.Post {
-	posts := make([]*website.Post, 0)
+func (obj *Rinka) List(ctx context.Context) ([]*website.Stub, error) {
+	stubs := make([]*website.Stub, 0)
+	link := obj.settings.ListingURL + obj.settings.SearchPath
 
-	res, err := website.GetResponse(LINK)
+	doc, err := obj.settings.Client.GetDocument(ctx, link)
 	if err != nil {
-		return posts
-	}
-	defer res.Body.Close()
-	doc, err := goquery.NewDocumentFromReader(res.Body)
-	if err != nil {
-		return posts
+		return stubs, err
 	}
 
-	doc.Find("[id='adsBlock']").First().Find(".ad").Each(func(i int, s *goquery.Selection) {
-		p := &website.Post{}
+	items := doc.Find("[id='adsBlock']").First().Find(".ad")
+	if items.Length() == 0 {
+		return stubs, &website.LayoutError{URL: link, Field: "posts list"}
+	}
 
+	var lastErr error
+	items.Each(func(i int, s *goquery.Selection) {
 		upstreamID, exists := s.Find("a[itemprop='url']").Attr("href")
 		if !exists {
+			lastErr = &website.LayoutError{URL: link, Field: "post ID"}
 			return
 		}
-		p.Link = upstreamID // https://www.rinka.lt/skelbimas/isnuomojamas-1-kambarys-3-kambariu-bute-id-4811032
+		stub := &website.Stub{ID: upstreamID}
+		stub.Link = website.Link(obj.settings.BaseURL, upstreamID) // https://www.rinka.lt/skelbimas/isnuomojamas-1-kambarys-3-kambariu-bute-id-4811032
+		stub.Price = website.ListingPrice(s.Find(".price").Text())
+		stubs = append(stubs, stub)
+	})
 
-		if db.InDatabase(p.Link) {
-			return
-		}
+	return stubs, lastErr
+}
 
-		postRes, err := website.GetResponse(p.Link)
-		if err != nil {
-			return
-		}
-		defer postRes.Body.Close()
-		postDoc, err := goquery.NewDocumentFromReader(postRes.Body)
-		if err != nil {
-			return
-		}
+func (obj *Rinka) Fetch(ctx context.Context, stub *website.Stub) (*website.Post, error) {
+	p := &website.Post{Link: stub.Link}
 
-		// Extract details element
-		detailsElement := postDoc.Find("#adFullBlock")
-		var tmp string
+	postDoc, err := obj.settings.Client.GetDocument(ctx, p.Link)
+	if err != nil {
+		return nil, err
+	}
 
-		// Extract phone:
-		tmp, exists = postDoc.Find("div.messageBlock.hidden-xs.hidden-sm button").Attr("data-number")
-		if exists {
-			p.Phone = tmp
-		} else {
-			p.Phone = ""
-		}
+	// Extract details element
+	detailsElement := postDoc.Find("#adFullBlock")
 
-		// Extract description:
-		p.Description = postDoc.Find("[itemprop=\"description\"]").Text()
+	// Extract phone:
+	tmp, exists := postDoc.Find("div.messageBlock.hidden-xs.hidden-sm button").Attr("data-number")
+	if exists {
+		p.Phone = tmp
+	} else {
+		p.Phone = ""
+	}
 
-		// Extract address:
-		addrState := detailsElement.Find("dt:contains(\"Mikrorajonas / Gyvenvietė:\")").Next().Text()
-		addrStreet := detailsElement.Find("dt:contains(\"Gatvė:\")").Next().Text()
-		addrState = strings.TrimSpace(addrState)
-		addrStreet = strings.TrimSpace(addrStreet)
-		p.Address = website.CompileAddress(addrState, addrStreet)
+	// Extract description:
+	p.Description = postDoc.Find("[itemprop=\"description\"]").Text()
 
-		// Extract heating:
-		p.Heating = detailsElement.Find("dt:contains(\"Šildymas:\")").Next().Text()
+	// Extract address:
+	addrState := detailsElement.Find("dt:contains(\"Mikrorajonas / Gyvenvietė:\")").Next().Text()
+	addrStreet := detailsElement.Find("dt:contains(\"Gatvė:\")").Next().Text()
+	addrState = strings.TrimSpace(addrState)
+	addrStreet = strings.TrimSpace(addrStreet)
+	p.Address = website.CompileAddress(obj.settings.City.Name, addrState, addrStreet)
 
-		// Extract floor:
-		tmp = detailsElement.Find("dt:contains(\"Kelintame aukšte:\")").Next().Text()
-		if tmp != "" {
-			tmp = strings.TrimSpace(tmp)
-			p.Floor, err = strconv.Atoi(tmp)
-			if err != nil {
-				log.Println("failed to extract Floor number from 'rinka' post")
-				return
-			}
-		}
+	// Extract heating:
+	p.Heating = detailsElement.Find("dt:contains(\"Šildymas:\")").Next().Text()
 
-		// Extract floor total:
-		tmp = detailsElement.Find("dt:contains(\"Pastato aukštų skaičius:\")").Next().Text()
-		if tmp != "" {
-			tmp = strings.TrimSpace(tmp)
-			p.FloorTotal, err = strconv.Atoi(tmp)
-			if err != nil {
-				log.Println("failed to extract FloorTotal number from 'rinka' post")
-				return
-			}
+	// Extract floor:
+	tmp = detailsElement.Find("dt:contains(\"Kelintame aukšte:\")").Next().Text()
+	if tmp != "" {
+		tmp = strings.TrimSpace(tmp)
+		p.Floor, err = strconv.Atoi(tmp)
+		if err != nil {
+			return nil, &website.LayoutError{URL: p.Link, Field: "Floor", Err: err}
 		}
+	}
 
-		// Extract area:
-		tmp = detailsElement.Find("dt:contains(\"Bendras plotas, m²:\")").Next().Text()
-		if tmp != "" {
-			tmp = strings.TrimSpace(tmp)
-			p.Area, err = strconv.Atoi(tmp)
-			if err != nil {
-				log.Println("failed to extract Area number from 'rinka' post")
-				return
-			}
+	// Extract floor total:
+	tmp = detailsElement.Find("dt:contains(\"Pastato aukštų skaičius:\")").Next().Text()
+	if tmp != "" {
+		tmp = strings.TrimSpace(tmp)
+		p.FloorTotal, err = strconv.Atoi(tmp)
+		if err != nil {
+			return nil, &website.LayoutError{URL: p.Link, Field: "FloorTotal", Err: err}
 		}
+	}
 
-		// Extract price:
-		tmp = postDoc.Find("span.price:contains(\"Kaina: \")").Text()
-		if tmp != "" {
-			arr := rePrice.FindStringSubmatch(tmp)
-			if len(arr) == 2 {
-				p.Price, err = strconv.Atoi(arr[1])
-				if err != nil {
-					log.Println("failed to extract Price number from 'rinka' post")
-					return
-				}
-			} else if strings.Contains(tmp, "Nenurodyta") {
-				p.Price = -1 // so it gets ignored
-			}
+	// Extract area:
+	tmp = detailsElement.Find("dt:contains(\"Bendras plotas, m²:\")").Next().Text()
+	if tmp != "" {
+		tmp = strings.TrimSpace(tmp)
+		p.Area, err = strconv.Atoi(tmp)
+		if err != nil {
+			return nil, &website.LayoutError{URL: p.Link, Field: "Area", Err: err}
 		}
+	}
 
-		// Extract rooms:
-		tmp = detailsElement.Find("dt:contains(\"Kambarių skaičius:\")").Next().Text()
-		if tmp != "" {
-			tmp = strings.TrimSpace(tmp)
-			p.Rooms, err = strconv.Atoi(tmp)
+	// Extract price:
+	tmp = postDoc.Find("span.price:contains(\"Kaina: \")").Text()
+	if tmp != "" {
+		arr := rePrice.FindStringSubmatch(tmp)
+		if len(arr) == 2 {
+			p.Price, err = strconv.Atoi(arr[1])
 			if err != nil {
-				log.Println("failed to extract Rooms number from 'rinka' post")
-				return
+				return nil, &website.LayoutError{URL: p.Link, Field: "Price", Err: err}
 			}
+		} else if strings.Contains(tmp, "Nenurodyta") {
+			p.Price = -1 // so it gets ignored
 		}
+	}
 
-		// Extract year:
-		tmp = detailsElement.Find("dt:contains(\"Statybos metai:\")").Next().Text()
-		if tmp != "" {
-			tmp = strings.TrimSpace(tmp)
-			p.Year, err = strconv.Atoi(tmp)
-			if err != nil {
-				log.Println("failed to extract Year number from 'rinka' post")
-				return
-			}
+	// Extract rooms:
+	tmp = detailsElement.Find("dt:contains(\"Kambarių skaičius:\")").Next().Text()
+	if tmp != "" {
+		tmp = strings.TrimSpace(tmp)
+		p.Rooms, err = strconv.Atoi(tmp)
+		if err != nil {
+			return nil, &website.LayoutError{URL: p.Link, Field: "Rooms", Err: err}
 		}
+	}
 
-		p.TrimFields()
-		posts = append(posts, p)
-	})
+	// Extract year:
+	tmp = detailsElement.Find("dt:contains(\"Statybos metai:\")").Next().Text()
+	if tmp != "" {
+		tmp = strings.TrimSpace(tmp)
+		p.Year, err = strconv.Atoi(tmp)
+		if err != nil {
+			return nil, &website.LayoutError{URL: p.Link, Field: "Year", Err: err}
+		}
+	}
 
-	return posts
+	p.TrimFields()
+	return p, nil
 }
 
 func init() {
-	website.Add("rinka", &Rinka{})
+	website.Add("rinka", website.Portal{
+		BaseURL:     BASE_URL,
+		SearchPaths: searchPaths,
+		New: func(s *website.Settings) website.Website {
+			return &Rinka{settings: s}
+		},
+	})
 }
@@ -0,0 +1,27 @@
+package rinka
+
+import (
+	"bbtmvbot/website/websitetest"
+	"context"
+	"testing"
+)
+
+func TestRinka(t *testing.T) {
+	srv := websitetest.Serve(t, map[string]string{
+		"/nekilnojamojo-turto-skelbimai/butu-nuoma":                         "listing.html",
+		"/skelbimas/isnuomojamas-2-kambariu-butas-naujamiestyje-id-4811032": "post.html",
+	})
+	w := &Rinka{settings: websitetest.Settings(srv, BASE_URL, BASE_URL, searchPaths["vilnius"])}
+
+	stubs, err := w.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	websitetest.Golden(t, "listing", stubs)
+
+	post, err := w.Fetch(context.Background(), stubs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	websitetest.Golden(t, "post", post)
+}
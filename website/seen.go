@@ -0,0 +1,45 @@
+package website
+
+import "sync"
+
+// SeenChecker tells whether post was already processed, so scrapers do not
+// need to retrieve its details again.
+type SeenChecker interface {
+	// Seen reports whether post with given link was already processed and
+	// its price did not change since then. Price is 0 when listing does not
+	// show it (aruodas and nuomininkai), then checker may still report post
+	// as not seen, so its details are retrieved again to notice price
+	// changes.
+	Seen(link string, price int) bool
+}
+
+// MemorySeen is in-memory SeenChecker, mostly useful for tests.
+type MemorySeen struct {
+	mu     sync.Mutex
+	prices map[string]int
+}
+
+// NewMemorySeen creates MemorySeen with given links seen at unknown price.
+func NewMemorySeen(links ...string) *MemorySeen {
+	m := &MemorySeen{prices: map[string]int{}}
+	for _, link := range links {
+		m.prices[link] = 0
+	}
+	return m
+}
+
+func (m *MemorySeen) Seen(link string, price int) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	known, ok := m.prices[link]
+	if !ok {
+		return false
+	}
+	return known == 0 || price == 0 || known == price
+}
+
+func (m *MemorySeen) Add(link string, price int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prices[link] = price
+}
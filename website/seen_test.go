@@ -0,0 +1,28 @@
+package website
+
+import "testing"
+
+func TestMemorySeen(t *testing.T) {
+	m := NewMemorySeen("https://example.com/1")
+	if !m.Seen("https://example.com/1", 300) {
+		t.Errorf("Expected initial link to be seen.")
+	}
+	if m.Seen("https://example.com/2", 0) {
+		t.Errorf("Expected unknown link to be unseen.")
+	}
+	m.Add("https://example.com/2", 300)
+
+	var data = []struct {
+		Price    int
+		Expected bool
+	}{
+		{300, true},
+		{0, true},
+		{280, false},
+	}
+	for _, v := range data {
+		if got := m.Seen("https://example.com/2", v.Price); got != v.Expected {
+			t.Errorf("Result is incorrect for price %d, got: '%t', want: '%t'.", v.Price, got, v.Expected)
+		}
+	}
+}
@@ -1,143 +1,152 @@
 package skelbiu
 
 import (
-	"bbtmvbot/database"
 	"bbtmvbot/website"
-	"log"
+	"context"
 	"strconv"
 	"strings"
 
 	"github.com/PuerkitoBio/goquery"
 )
 
-type Skelbiu struct{}
+type Skelbiu struct {
+	settings *website.Settings
+}
 
-const LINK = "https://www.skelbiu.lt/skelbimai/?cities=465&category_id=322&cities=465&district=0&cost_min=&cost_max=&status=0&space_min=&space_max=&rooms_min=&rooms_max=&building=0&year_min=&year_max=&floor_min=&floor_max=&floor_type=0&user_type=0&type=1&orderBy=1&import=2&keywords="
+const BASE_URL = "https://skelbiu.lt"
+const LISTING_URL = "https://www.skelbiu.lt"
 
-func (obj *Skelbiu) Retrieve(db *database.Database) []*website.Post {
-	posts := make([]*website.Post, 0)
+// searchPaths contains search page of every supported city. Only Vilnius is
+// supported, other cities are skipped unless their search_paths are
+// configured.
+var searchPaths = map[string]string{
+	"vilnius": "/skelbimai/?cities=465&category_id=322&cities=465&district=0&cost_min=&cost_max=&status=0&space_min=&space_max=&rooms_min=&rooms_max=&building=0&year_min=&year_max=&floor_min=&floor_max=&floor_type=0&user_type=0&type=1&orderBy=1&import=2&keywords=",
+}
 
-	res, err := website.GetResponse(LINK)
-	if err != nil {
-		return posts
-	}
-	defer res.Body.Close()
-	doc, err := goquery.NewDocumentFromReader(res.Body)
+func (obj *Skelbiu) List(ctx context.Context) ([]*website.Stub, error) {
+	stubs := make([]*website.Stub, 0)
+	link := obj.settings.ListingURL + obj.settings.SearchPath
+
+	doc, err := obj.settings.Client.GetDocument(ctx, link)
 	if err != nil {
-		return posts
+		return stubs, err
 	}
 
-	doc.Find("#itemsList > ul > li.simpleAds:not(.passivatedItem)").Each(func(i int, s *goquery.Selection) {
-		p := &website.Post{}
+	items := doc.Find("#itemsList > ul > li.simpleAds:not(.passivatedItem)")
+	if items.Length() == 0 {
+		return stubs, &website.LayoutError{URL: link, Field: "posts list"}
+	}
 
+	var lastErr error
+	items.Each(func(i int, s *goquery.Selection) {
 		upstreamID, exists := s.Find("a.adsImage[data-item-id]").Attr("data-item-id")
 		if !exists {
+			lastErr = &website.LayoutError{URL: link, Field: "post ID"}
 			return
 		}
-		p.Link = "https://skelbiu.lt/skelbimai/" + upstreamID + ".html" // https://skelbiu.lt/42588321.html
+		stub := &website.Stub{ID: upstreamID}
+		stub.Link = obj.settings.BaseURL + "/skelbimai/" + upstreamID + ".html" // https://skelbiu.lt/42588321.html
+		stub.Price = website.ListingPrice(s.Find(".adsPrice").Text())
+		stubs = append(stubs, stub)
+	})
 
-		if db.InDatabase(p.Link) {
-			return
-		}
+	return stubs, lastErr
+}
 
-		postRes, err := website.GetResponse(p.Link)
-		if err != nil {
-			return
-		}
-		defer postRes.Body.Close()
-		postDoc, err := goquery.NewDocumentFromReader(postRes.Body)
-		if err != nil {
-			return
-		}
+func (obj *Skelbiu) Fetch(ctx context.Context, stub *website.Stub) (*website.Post, error) {
+	p := &website.Post{Link: stub.Link}
 
-		var tmp string
+	postDoc, err := obj.settings.Client.GetDocument(ctx, p.Link)
+	if err != nil {
+		return nil, err
+	}
 
-		// Extract phone:
-		p.Phone = postDoc.Find("div.phone-button > div.primary").Text()
+	var tmp string
 
-		// Extract description:
-		p.Description = postDoc.Find("div[itemprop='description']").Text()
+	// Extract phone:
+	p.Phone = postDoc.Find("div.phone-button > div.primary").Text()
 
-		// Extract address:
-		addrState := postDoc.Find(".detail > .title:contains('Mikrorajonas:')").Next().Text()
-		addrStreet := postDoc.Find(".detail > .title:contains('Gatvė:')").Next().Text()
-		addrHouseNum := postDoc.Find(".detail > .title:contains('Namo numeris:')").Next().Text()
-		addrState = strings.TrimSpace(addrState)
-		addrStreet = strings.TrimSpace(addrStreet)
-		addrHouseNum = strings.TrimSpace(addrHouseNum)
-		p.Address = website.CompileAddressWithStreet(addrState, addrStreet, addrHouseNum)
+	// Extract description:
+	p.Description = postDoc.Find("div[itemprop='description']").Text()
 
-		// Extract heating:
-		p.Heating = postDoc.Find(".detail > .title:contains('Šildymas:')").Next().Text()
+	// Extract address:
+	addrState := postDoc.Find(".detail > .title:contains('Mikrorajonas:')").Next().Text()
+	addrStreet := postDoc.Find(".detail > .title:contains('Gatvė:')").Next().Text()
+	addrHouseNum := postDoc.Find(".detail > .title:contains('Namo numeris:')").Next().Text()
+	addrState = strings.TrimSpace(addrState)
+	addrStreet = strings.TrimSpace(addrStreet)
+	addrHouseNum = strings.TrimSpace(addrHouseNum)
+	p.Address = website.CompileAddressWithStreet(obj.settings.City.Name, addrState, addrStreet, addrHouseNum)
 
-		// Extract floor:
-		tmp = postDoc.Find(".detail > .title:contains('Aukštas:')").Next().Text()
-		p.Floor, err = strconv.Atoi(tmp)
-		if err != nil {
-			log.Println("failed to extract Floor number from 'skelbiu' post")
-			return
-		}
+	// Extract heating:
+	p.Heating = postDoc.Find(".detail > .title:contains('Šildymas:')").Next().Text()
 
-		// Extract floor total:
-		tmp = postDoc.Find(".detail > .title:contains('Aukštų skaičius:')").Next().Text()
-		p.FloorTotal, err = strconv.Atoi(tmp)
-		if err != nil {
-			log.Println("failed to extract FloorTotal number from 'skelbiu' post")
-			return
-		}
+	// Extract floor:
+	tmp = postDoc.Find(".detail > .title:contains('Aukštas:')").Next().Text()
+	p.Floor, err = strconv.Atoi(tmp)
+	if err != nil {
+		return nil, &website.LayoutError{URL: p.Link, Field: "Floor", Err: err}
+	}
 
-		// Extract area:
-		tmp = postDoc.Find(".detail > .title:contains('Plotas, m²:')").Next().Text()
-		if tmp != "" {
-			tmp = strings.TrimSpace(tmp)
-			if strings.Contains(tmp, ",") {
-				tmp = strings.Split(tmp, ",")[0]
-			} else {
-				tmp = strings.Split(tmp, " ")[0]
-			}
-			p.Area, err = strconv.Atoi(tmp)
-			if err != nil {
-				log.Println("failed to extract Area number from 'skelbiu' post")
-				return
-			}
-		}
+	// Extract floor total:
+	tmp = postDoc.Find(".detail > .title:contains('Aukštų skaičius:')").Next().Text()
+	p.FloorTotal, err = strconv.Atoi(tmp)
+	if err != nil {
+		return nil, &website.LayoutError{URL: p.Link, Field: "FloorTotal", Err: err}
+	}
 
-		// Extract price:
-		tmp = postDoc.Find("p.price:contains(' €')").Text()
-		if tmp != "" {
-			tmp = strings.TrimSpace(tmp)
-			tmp = strings.ReplaceAll(tmp, " ", "")
-			tmp = strings.ReplaceAll(tmp, "€", "")
-			p.Price, err = strconv.Atoi(tmp)
-			if err != nil {
-				log.Println("failed to extract Price number from 'skelbiu' post")
-				return
-			}
+	// Extract area:
+	tmp = postDoc.Find(".detail > .title:contains('Plotas, m²:')").Next().Text()
+	if tmp != "" {
+		tmp = strings.TrimSpace(tmp)
+		if strings.Contains(tmp, ",") {
+			tmp = strings.Split(tmp, ",")[0]
+		} else {
+			tmp = strings.Split(tmp, " ")[0]
 		}
-
-		// Extract rooms:
-		tmp = postDoc.Find(".detail > .title:contains('Kamb. sk.:')").Next().Text()
-		p.Rooms, err = strconv.Atoi(tmp)
+		p.Area, err = strconv.Atoi(tmp)
 		if err != nil {
-			log.Println("failed to extract Rooms number from 'skelbiu' post")
-			return
+			return nil, &website.LayoutError{URL: p.Link, Field: "Area", Err: err}
 		}
+	}
 
-		// Extract year:
-		tmp = postDoc.Find(".detail > .title:contains('Metai:')").Next().Text()
-		p.Year, err = strconv.Atoi(tmp)
+	// Extract price:
+	tmp = postDoc.Find("p.price:contains(' €')").Text()
+	if tmp != "" {
+		tmp = strings.TrimSpace(tmp)
+		tmp = strings.ReplaceAll(tmp, " ", "")
+		tmp = strings.ReplaceAll(tmp, "€", "")
+		p.Price, err = strconv.Atoi(tmp)
 		if err != nil {
-			log.Println("failed to extract Year number from 'skelbiu' post")
-			return
+			return nil, &website.LayoutError{URL: p.Link, Field: "Price", Err: err}
 		}
+	}
 
-		p.TrimFields()
-		posts = append(posts, p)
-	})
+	// Extract rooms:
+	tmp = postDoc.Find(".detail > .title:contains('Kamb. sk.:')").Next().Text()
+	p.Rooms, err = strconv.Atoi(tmp)
+	if err != nil {
+		return nil, &website.LayoutError{URL: p.Link, Field: "Rooms", Err: err}
+	}
 
-	return posts
+	// Extract year:
+	tmp = postDoc.Find(".detail > .title:contains('Metai:')").Next().Text()
+	p.Year, err = strconv.Atoi(tmp)
+	if err != nil {
+		return nil, &website.LayoutError{URL: p.Link, Field: "Year", Err: err}
+	}
+
+	p.TrimFields()
+	return p, nil
 }
 
 func init() {
-	website.Add("skelbiu", &Skelbiu{})
+	website.Add("skelbiu", website.Portal{
+		BaseURL:     BASE_URL,
+		ListingURL:  LISTING_URL,
+		SearchPaths: searchPaths,
+		New: func(s *website.Settings) website.Website {
+			return &Skelbiu{settings: s}
+		},
+	})
 }
@@ -0,0 +1,27 @@
+package skelbiu
+
+import (
+	"bbtmvbot/website/websitetest"
+	"context"
+	"testing"
+)
+
+func TestSkelbiu(t *testing.T) {
+	srv := websitetest.Serve(t, map[string]string{
+		"/skelbimai/":              "listing.html",
+		"/skelbimai/42588321.html": "post.html",
+	})
+	w := &Skelbiu{settings: websitetest.Settings(srv, BASE_URL, LISTING_URL, searchPaths["vilnius"])}
+
+	stubs, err := w.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	websitetest.Golden(t, "listing", stubs)
+
+	post, err := w.Fetch(context.Background(), stubs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	websitetest.Golden(t, "post", post)
+}
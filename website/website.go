@@ -1,15 +1,181 @@
 package website
 
 import (
-	"bbtmvbot/database"
+	"bbtmvbot/config"
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"strings"
 )
 
+// Stub is a post as it is shown in portal's listing page. Price and Rooms
+// are 0 when listing does not show them.
+type Stub struct {
+	ID    string
+	Link  string
+	Price int
+	Rooms int
+
+	// Post is set by scrapers whose listing already contains all the details
+	Post *Post
+}
+
 type Website interface {
-	Retrieve(db *database.Database) []*Post
+	// List returns stubs of the latest posts in portal's listing page.
+	// Successfully parsed stubs are returned even if error is not nil.
+	List(ctx context.Context) ([]*Stub, error)
+
+	// Fetch retrieves full details of the given stub.
+	Fetch(ctx context.Context, stub *Stub) (*Post, error)
+}
+
+// Settings are runtime settings of a single website.
+type Settings struct {
+	// BaseURL is used for links to posts
+	BaseURL string
+	// ListingURL is used for search page
+	ListingURL string
+	// SearchPath is path of the city's search page, relative to ListingURL
+	SearchPath string
+	City       *City
+	Client     *Client
+}
+
+// Portal describes website registered using Add.
+type Portal struct {
+	// BaseURL is default base URL of the website
+	BaseURL string
+	// ListingURL is default base URL of search page, BaseURL if empty
+	ListingURL string
+	// SearchPaths contains search page path of every supported city
+	SearchPaths map[string]string
+	New         func(s *Settings) Website
+}
+
+var portals = map[string]Portal{}
+
+func Add(name string, p Portal) {
+	portals[name] = p
+}
+
+// Site is a website searching for posts in a single city.
+type Site struct {
+	Name string
+	City *City
+	Website
 }
 
-var Websites = map[string]Website{}
+// ConfiguredCities returns cities that are configured to be searched, or the
+// default city if none are configured.
+func ConfiguredCities(c *config.Config) ([]*City, error) {
+	ids := c.CityIDs()
+	if len(ids) == 0 {
+		ids = []string{DefaultCity}
+	}
+
+	cities := make([]*City, 0, len(ids))
+	for _, id := range ids {
+		city, ok := Cities[id]
+		if !ok {
+			return nil, errors.New("unknown city '" + id + "'")
+		}
+		cities = append(cities, city)
+	}
+	return cities, nil
+}
+
+// New creates all registered websites for every configured city. Websites
+// that do not support some city are skipped for that city, unless its search
+// path is configured, and the skipped ones are logged.
+func New(c *config.Config) ([]*Site, error) {
+	cities, err := ConfiguredCities(c)
+	if err != nil {
+		return nil, err
+	}
+
+	sites := make([]*Site, 0, len(portals)*len(cities))
+	skipped := map[string][]string{}
+	for name, p := range portals {
+		client, err := NewClient(c.WebsiteHTTP(name))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, city := range cities {
+			searchPath, ok := p.SearchPaths[city.ID]
+			if configured, found := c.Websites[name].SearchPaths[city.ID]; found {
+				searchPath, ok = configured, true
+			}
+			if !ok {
+				skipped[city.ID] = append(skipped[city.ID], name)
+				continue
+			}
+
+			s := &Settings{
+				BaseURL:    p.BaseURL,
+				ListingURL: p.ListingURL,
+				SearchPath: searchPath,
+				City:       city,
+				Client:     client,
+			}
+			if s.ListingURL == "" {
+				s.ListingURL = s.BaseURL
+			}
+			if wc, ok := c.Websites[name]; ok {
+				if wc.BaseURL != "" {
+					s.BaseURL = wc.BaseURL
+				}
+				if wc.ListingURL != "" {
+					s.ListingURL = wc.ListingURL
+				} else if wc.BaseURL != "" {
+					s.ListingURL = wc.BaseURL
+				}
+			}
+			sites = append(sites, &Site{Name: name, City: city, Website: p.New(s)})
+		}
+	}
+
+	for _, city := range cities {
+		if names := skipped[city.ID]; len(names) > 0 {
+			sort.Strings(names)
+			log.Printf("city '%s' is not searched in %s, as they do not support it (configure search_paths to search them)", city.ID, strings.Join(names, ", "))
+		}
+	}
+	return sites, nil
+}
+
+// Retrieve lists posts of the given website and fetches details only of those
+// stubs that are not seen yet and are wanted. Successfully retrieved posts are
+// returned even if error is not nil, so a single broken post does not hide
+// the rest of them.
+func Retrieve(ctx context.Context, w Website, seen SeenChecker, wanted func(*Stub) bool) ([]*Post, error) {
+	posts := make([]*Post, 0)
+
+	stubs, err := w.List(ctx)
+	for _, stub := range stubs {
+		if seen.Seen(stub.Link, stub.Price) || !wanted(stub) {
+			continue
+		}
+
+		p, fetchErr := w.Fetch(ctx, stub)
+		if fetchErr != nil {
+			err = fetchErr
+			continue
+		}
+		posts = append(posts, p)
+	}
+
+	return posts, err
+}
 
-func Add(name string, w Website) {
-	Websites[name] = w
+// Retrieve retrieves posts of the site like Retrieve does and tags them with
+// site's name and city.
+func (s *Site) Retrieve(ctx context.Context, seen SeenChecker, wanted func(*Stub) bool) ([]*Post, error) {
+	posts, err := Retrieve(ctx, s.Website, seen, wanted)
+	for _, p := range posts {
+		p.Source = s.Name
+		p.City = s.City.ID
+	}
+	return posts, err
 }
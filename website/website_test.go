@@ -0,0 +1,175 @@
+package website
+
+import (
+	"bbtmvbot/config"
+	"context"
+	"errors"
+	"testing"
+)
+
+type fakeWebsite struct {
+	stubs   []*Stub
+	listErr error
+	fetched []string
+}
+
+func (w *fakeWebsite) List(ctx context.Context) ([]*Stub, error) {
+	return w.stubs, w.listErr
+}
+
+func (w *fakeWebsite) Fetch(ctx context.Context, stub *Stub) (*Post, error) {
+	w.fetched = append(w.fetched, stub.Link)
+	if stub.ID == "broken" {
+		return nil, &LayoutError{URL: stub.Link, Field: "Price"}
+	}
+	return &Post{Link: stub.Link, Price: stub.Price}, nil
+}
+
+func TestRetrieve(t *testing.T) {
+	w := &fakeWebsite{stubs: []*Stub{
+		{ID: "1", Link: "https://example.com/1", Price: 300},
+		{ID: "2", Link: "https://example.com/2", Price: 300},
+		{ID: "3", Link: "https://example.com/3", Price: 900},
+		{ID: "broken", Link: "https://example.com/broken", Price: 300},
+		{ID: "5", Link: "https://example.com/5"},
+		{ID: "6", Link: "https://example.com/6", Price: 350},
+	}}
+	seen := NewMemorySeen("https://example.com/2")
+	seen.Add("https://example.com/6", 400) // Price changed, so it is retrieved again
+	wanted := func(s *Stub) bool { return s.Price == 0 || s.Price < 500 }
+
+	posts, err := Retrieve(context.Background(), w, seen, wanted)
+
+	var layoutErr *LayoutError
+	if !errors.As(err, &layoutErr) {
+		t.Errorf("Expected layout error, got: '%v'.", err)
+	}
+	expectedFetched := []string{"https://example.com/1", "https://example.com/broken", "https://example.com/5", "https://example.com/6"}
+	if len(w.fetched) != len(expectedFetched) {
+		t.Fatalf("Fetched incorrect posts, got: '%v', want: '%v'.", w.fetched, expectedFetched)
+	}
+	for i := range expectedFetched {
+		if w.fetched[i] != expectedFetched[i] {
+			t.Errorf("Fetched incorrect posts, got: '%v', want: '%v'.", w.fetched, expectedFetched)
+		}
+	}
+	if len(posts) != 3 || posts[0].Link != "https://example.com/1" || posts[1].Link != "https://example.com/5" || posts[2].Link != "https://example.com/6" {
+		t.Errorf("Returned incorrect posts: '%v'.", posts)
+	}
+}
+
+func TestRetrieveListError(t *testing.T) {
+	w := &fakeWebsite{listErr: &StatusError{URL: "https://example.com", StatusCode: 503}}
+	posts, err := Retrieve(context.Background(), w, NewMemorySeen(), func(*Stub) bool { return true })
+	if ErrorKind(err) != "http_status" {
+		t.Errorf("Expected HTTP status error, got: '%v'.", err)
+	}
+	if len(posts) != 0 {
+		t.Errorf("Expected no posts, got: '%v'.", posts)
+	}
+}
+
+func TestListingPrice(t *testing.T) {
+	var data = []struct {
+		Provided string
+		Expected int
+	}{
+		{"350 €", 350},
+		{"  1 200 € / mėn.", 1200},
+		{"Kaina: 450,00 €", 450},
+		{"1\u00a0050\u00a0€", 1050},
+		{"499.99€", 499},
+		{"Kaina sutartinė", 0},
+		{"", 0},
+	}
+	for _, v := range data {
+		if res := ListingPrice(v.Provided); res != v.Expected {
+			t.Errorf("Result is incorrect for '%s', got: '%d', want: '%d'.", v.Provided, res, v.Expected)
+		}
+	}
+}
+
+type settingsWebsite struct {
+	*fakeWebsite
+	settings *Settings
+}
+
+func TestNew(t *testing.T) {
+	Add("test", Portal{
+		BaseURL:     "https://portal.invalid",
+		ListingURL:  "https://m.portal.invalid",
+		SearchPaths: map[string]string{"vilnius": "/search?city=1"},
+		New: func(s *Settings) Website {
+			return &settingsWebsite{&fakeWebsite{}, s}
+		},
+	})
+	defer delete(portals, "test")
+
+	var data = []struct {
+		Provided   *config.Config
+		BaseURL    string
+		ListingURL string
+		SearchPath string
+		City       string
+	}{
+		{&config.Config{}, "https://portal.invalid", "https://m.portal.invalid", "/search?city=1", "Vilnius"},
+		{&config.Config{Websites: map[string]config.Website{"test": {BaseURL: "http://localhost:8080"}}}, "http://localhost:8080", "http://localhost:8080", "/search?city=1", "Vilnius"},
+		{&config.Config{Websites: map[string]config.Website{"test": {ListingURL: "http://localhost:8081"}}}, "https://portal.invalid", "http://localhost:8081", "/search?city=1", "Vilnius"},
+		{&config.Config{City: "kaunas"}, "", "", "", ""},
+		{&config.Config{City: "kaunas", Websites: map[string]config.Website{"test": {SearchPaths: map[string]string{"kaunas": "/search?city=2"}}}}, "https://portal.invalid", "https://m.portal.invalid", "/search?city=2", "Kaunas"},
+	}
+	for _, v := range data {
+		sites, err := New(v.Provided)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var site *Site
+		for _, s := range sites {
+			if s.Name == "test" {
+				site = s
+			}
+		}
+		if site == nil {
+			if v.City != "" {
+				t.Errorf("Website was skipped for '%+v'.", v.Provided)
+			}
+			continue
+		}
+		s := site.Website.(*settingsWebsite).settings
+		if s.BaseURL != v.BaseURL || s.ListingURL != v.ListingURL || s.SearchPath != v.SearchPath || s.City.Name != v.City || site.City != s.City {
+			t.Errorf("Result is incorrect for '%+v', got: '%s' '%s' '%s' '%s', want: '%s' '%s' '%s' '%s'.", v.Provided, s.BaseURL, s.ListingURL, s.SearchPath, s.City.Name, v.BaseURL, v.ListingURL, v.SearchPath, v.City)
+		}
+	}
+
+	sites, err := New(&config.Config{Cities: []string{"vilnius", "kaunas"}, Websites: map[string]config.Website{"test": {SearchPaths: map[string]string{"kaunas": "/search?city=2"}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cities := map[string]string{}
+	for _, s := range sites {
+		if s.Name == "test" {
+			cities[s.City.ID] = s.Website.(*settingsWebsite).settings.SearchPath
+		}
+	}
+	if len(cities) != 2 || cities["vilnius"] != "/search?city=1" || cities["kaunas"] != "/search?city=2" {
+		t.Errorf("Result is incorrect, got: '%v', want: 'vilnius' and 'kaunas' sites.", cities)
+	}
+
+	if _, err := New(&config.Config{City: "atlantis"}); err == nil {
+		t.Errorf("Expected error for unknown city, got nil.")
+	}
+}
+
+func TestSiteRetrieve(t *testing.T) {
+	site := &Site{Name: "test", City: Cities["kaunas"], Website: &fakeWebsite{stubs: []*Stub{
+		{ID: "1", Link: "https://example.com/1", Price: 300},
+	}}}
+
+	posts, err := site.Retrieve(context.Background(), NewMemorySeen(), func(*Stub) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 1 || posts[0].Source != "test" || posts[0].City != "kaunas" {
+		t.Errorf("Result is incorrect, got: '%+v', want: post tagged with 'test' and 'kaunas'.", posts[0])
+	}
+}
@@ -0,0 +1,175 @@
+// Package websitetest serves recorded portal pages, so scrapers can be tested
+// without reaching the real portals.
+//
+// Pages are recorded by running tests with -record flag, which fetches every
+// requested page from the real portal and saves it to testdata directory.
+// Golden files should be updated at the same time:
+//
+//	go test ./website/... -record -update
+//
+// Aruodas pages are retrieved by headless browser, so they are recorded only
+// when tests are built with chrome tag and Chrome is installed:
+//
+//	go test -tags chrome ./website/aruodas -record -update
+package websitetest
+
+import (
+	"bbtmvbot/website"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+var (
+	update = flag.Bool("update", false, "update golden files in testdata directory")
+	record = flag.Bool("record", false, "record pages of the real portals to testdata directory")
+)
+
+// Header telling test server the host request was meant for
+const originalHostHeader = "X-Original-Host"
+
+// Serve starts HTTP server that serves files from testdata directory until the
+// end of the test. Requests are matched by URL path only (host and query are
+// ignored). Keys of routes are paths and values are file names, other paths
+// respond with 404. With -record flag files are fetched from the real portal
+// first.
+func Serve(t *testing.T, routes map[string]string) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, ok := routes[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		path := filepath.Join("testdata", name)
+		if *record {
+			if err := recordPage(r, path); err != nil {
+				t.Errorf("failed to record %s: %s", path, err)
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+		}
+		http.ServeFile(w, r, path)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+// Settings returns website settings of Vilnius with client that sends every
+// request to the given server, so scrapers can keep using real portal links.
+func Settings(srv *httptest.Server, baseURL, listingURL, searchPath string) *website.Settings {
+	target, err := url.Parse(srv.URL)
+	if err != nil {
+		panic(err)
+	}
+	return &website.Settings{
+		BaseURL:    baseURL,
+		ListingURL: listingURL,
+		SearchPath: searchPath,
+		City:       website.Cities["vilnius"],
+		Client: &website.Client{
+			HTTPClient: &http.Client{Transport: &rewriteTransport{target: target}},
+		},
+	}
+}
+
+// rewriteTransport sends every request to target host.
+type rewriteTransport struct {
+	target *url.URL
+}
+
+func (rt *rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := req.Clone(req.Context())
+	r.Header.Set(originalHostHeader, req.URL.Host)
+	r.URL.Scheme = rt.target.Scheme
+	r.URL.Host = rt.target.Host
+	r.Host = rt.target.Host
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+// recordPage fetches page requested from the test server from the real portal
+// and saves it to the given path.
+func recordPage(r *http.Request, path string) error {
+	u := *r.URL
+	u.Scheme = "https"
+	u.Host = r.Header.Get(originalHostHeader)
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, u.String(), r.Body)
+	if err != nil {
+		return err
+	}
+	for k, v := range r.Header {
+		// Response is decompressed by client only if it asked for compression
+		if k != originalHostHeader && k != "Accept-Encoding" {
+			req.Header[k] = v
+		}
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded with %s", u.String(), res.Status)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, body, 0644)
+}
+
+// Document parses testdata/<name> as HTML document. It is used for pages
+// rendered by headless browser, so their parsing is tested without Chrome.
+func Document(t *testing.T, name string) *goquery.Document {
+	t.Helper()
+
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	doc, err := goquery.NewDocumentFromReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+// Golden compares JSON representation of got with testdata/<name>.golden.json.
+// Run tests with -update flag to overwrite golden files with current results.
+func Golden(t *testing.T, name string, got interface{}) {
+	t.Helper()
+
+	actual, err := json.MarshalIndent(got, "", "\t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual = append(actual, '\n')
+
+	path := filepath.Join("testdata", name+".golden.json")
+	if *update {
+		if err = ioutil.WriteFile(path, actual, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(actual) != string(expected) {
+		t.Errorf("Result does not match '%s', got:\n%s\nwant:\n%s", path, actual, expected)
+	}
+}
@@ -0,0 +1,268 @@
+package bbtmvbot
+
+import (
+	"bbtmvbot/database"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+
+	telebot "gopkg.in/tucnak/telebot.v2"
+)
+
+// Config wizard asks for config values one by one using inline keyboards.
+// Values can also be typed in as a message.
+
+type wizardOption struct {
+	Text  string
+	Value string
+}
+
+type wizardStep struct {
+	Name     string
+	Question string
+	Options  []wizardOption
+	// Set parses value and stores it into filter
+	Set func(f *database.Filter, value string) error
+}
+
+func options(values ...string) []wizardOption {
+	o := make([]wizardOption, 0, len(values))
+	for _, v := range values {
+		o = append(o, wizardOption{Text: v, Value: v})
+	}
+	return o
+}
+
+// numberStep returns a step that sets a number field of the filter.
+func numberStep(name, question string, field func(f *database.Filter) *int, options []wizardOption) wizardStep {
+	return wizardStep{
+		Name:     name,
+		Question: question,
+		Options:  options,
+		Set: func(f *database.Filter, value string) error {
+			n, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return &database.FieldError{Field: name, Message: "must be a number"}
+			}
+			*field(f) = n
+			return nil
+		},
+	}
+}
+
+var anyOption = wizardOption{Text: "any", Value: "0"}
+
+var wizardSteps = []wizardStep{
+	numberStep("price_from", "What is the *minimum price* in €?", func(f *database.Filter) *int { return &f.PriceFrom },
+		options("0", "100", "200", "300", "400", "500")),
+	numberStep("price_to", "What is the *maximum price* in €?", func(f *database.Filter) *int { return &f.PriceTo },
+		options("300", "400", "500", "600", "800", "1000")),
+	numberStep("rooms_from", "What is the *minimum number of rooms*?", func(f *database.Filter) *int { return &f.RoomsFrom },
+		options("1", "2", "3", "4")),
+	numberStep("rooms_to", "What is the *maximum number of rooms*?", func(f *database.Filter) *int { return &f.RoomsTo },
+		options("1", "2", "3", "4", "5")),
+	numberStep("year_from", "What is the *minimum construction year*?", func(f *database.Filter) *int { return &f.YearFrom },
+		append([]wizardOption{anyOption}, options("1960", "1980", "2000", "2010")...)),
+	numberStep("min_floor", "What is the *minimum floor*?", func(f *database.Filter) *int { return &f.MinFloor },
+		append([]wizardOption{anyOption}, options("1", "2", "3")...)),
+	{
+		Name:     "show_with_fee",
+		Question: "Show posts with *extra fees*?",
+		Options:  options("yes", "no"),
+		Set: func(f *database.Filter, value string) error {
+			switch strings.ToLower(strings.TrimSpace(value)) {
+			case "yes":
+				f.ShowWithFees = true
+			case "no":
+				f.ShowWithFees = false
+			default:
+				return &database.FieldError{Field: "show_with_fee", Message: "must be yes or no"}
+			}
+			return nil
+		},
+	},
+}
+
+// configWizard is state of the config wizard of a single chat. Only the user
+// who started it can answer, so other members of a group do not interfere.
+type configWizard struct {
+	step      int
+	filter    database.Filter
+	messageID int
+	ownerID   int
+}
+
+var wizards = struct {
+	sync.Mutex
+	chats map[int64]*configWizard
+}{chats: map[int64]*configWizard{}}
+
+// Unique name of the wizard callback buttons
+const wizardUnique = "config"
+
+func (w *configWizard) question() (string, *telebot.ReplyMarkup) {
+	step := wizardSteps[w.step]
+	row := make([]telebot.InlineButton, 0, len(step.Options))
+	for _, o := range step.Options {
+		row = append(row, telebot.InlineButton{Unique: wizardUnique, Text: o.Text, Data: step.Name + "|" + o.Value})
+	}
+	cancel := []telebot.InlineButton{{Unique: wizardUnique, Text: "✖ Cancel", Data: "cancel"}}
+
+	text := "*Config " + strconv.Itoa(w.step+1) + "/" + strconv.Itoa(len(wizardSteps)) + "* " + step.Question + "\n\nPress a button or type a value."
+	return text, &telebot.ReplyMarkup{InlineKeyboard: [][]telebot.InlineButton{row, cancel}}
+}
+
+// answer stores answer of the current step and reports whether it was the
+// last one.
+func (w *configWizard) answer(value string) (bool, error) {
+	step := wizardSteps[w.step]
+	filter := w.filter
+	if err := step.Set(&filter, value); err != nil {
+		return false, err
+	}
+	// Fields of later steps are not answered yet
+	if err := filter.Validate(); err != nil {
+		if e := err.(database.ValidationError).Field(step.Name); e != nil {
+			return false, e
+		}
+	}
+	w.filter = filter
+	w.step++
+	return w.step == len(wizardSteps), nil
+}
+
+// callbackValue returns value of the button data, if it answers the current
+// question. Buttons of earlier questions are outdated.
+func (w *configWizard) callbackValue(data string) (string, bool) {
+	parts := strings.SplitN(data, "|", 2)
+	if len(parts) != 2 || w.step >= len(wizardSteps) || wizardSteps[w.step].Name != parts[0] {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// senderID returns ID of the user, or 0 if message was sent on behalf of a
+// channel.
+func senderID(u *telebot.User) int {
+	if u == nil {
+		return 0
+	}
+	return u.ID
+}
+
+func startConfigWizard(chatID int64, ownerID int) {
+	w := &configWizard{ownerID: ownerID}
+	text, markup := w.question()
+	m := sendTelegram(chatID, text, markup)
+	if m == nil {
+		return
+	}
+	w.messageID = m.ID
+
+	wizards.Lock()
+	wizards.chats[chatID] = w
+	wizards.Unlock()
+}
+
+// wizardAnswer is answer of a chat member to the current question.
+type wizardAnswer struct {
+	Sender int
+	Value  string
+	// Button data contains name of the question, so answers of outdated
+	// questions are ignored
+	Button bool
+}
+
+var (
+	errWizardInactive   = errors.New("this config is no longer active, use /config to start again")
+	errWizardOwner      = errors.New("this config was started by someone else")
+	errQuestionOutdated = errors.New("this question is outdated, use /config to start again")
+)
+
+// continueConfigWizard handles answer to the current question and returns
+// message that should replace the question and ID of the question message.
+// Only the user who started the wizard can answer.
+func continueConfigWizard(chatID int64, a wizardAnswer) (string, *telebot.ReplyMarkup, int, error) {
+	wizards.Lock()
+	defer wizards.Unlock()
+
+	w := wizards.chats[chatID]
+	if w == nil {
+		return "", nil, 0, errWizardInactive
+	}
+	if w.ownerID != a.Sender {
+		return "", nil, 0, errWizardOwner
+	}
+	value := a.Value
+	if value == "cancel" {
+		delete(wizards.chats, chatID)
+		return "Config was not changed.", nil, w.messageID, nil
+	}
+	if a.Button {
+		var current bool
+		if value, current = w.callbackValue(a.Value); !current {
+			return "", nil, 0, errQuestionOutdated
+		}
+	}
+
+	done, err := w.answer(value)
+	if err != nil {
+		return "", nil, 0, err
+	}
+	if !done {
+		text, markup := w.question()
+		return text, markup, w.messageID, nil
+	}
+
+	delete(wizards.chats, chatID)
+	db.UpdateUser(&database.User{TelegramID: chatID, Enabled: true, Filter: w.filter})
+	return "Config updated!\n\n" + activeSettings(chatID), nil, w.messageID, nil
+}
+
+func handleConfigCallback(c *telebot.Callback) {
+	chatID := c.Message.Chat.ID
+
+	text, markup, _, err := continueConfigWizard(chatID, wizardAnswer{Sender: senderID(c.Sender), Value: c.Data, Button: true})
+	switch {
+	case err == errWizardInactive || err == errWizardOwner || err == errQuestionOutdated:
+		tb.Respond(c, &telebot.CallbackResponse{Text: err.Error()})
+		return
+	case err != nil:
+		tb.Respond(c, &telebot.CallbackResponse{Text: err.Error(), ShowAlert: true})
+		return
+	}
+	tb.Respond(c)
+	if markup != nil {
+		editTelegram(chatID, c.Message.ID, text, markup)
+	} else {
+		editTelegram(chatID, c.Message.ID, text, &telebot.ReplyMarkup{})
+	}
+}
+
+// handleText handles typed in answers to config wizard and post links. Other
+// commands and messages of other group members are ignored.
+func handleText(m *telebot.Message) {
+	// Commands with arguments in their name are not routed by telebot
+	if id, ok := linkedPostID(m.Text); ok {
+		showPost(m.Chat.ID, id)
+		return
+	}
+	if strings.HasPrefix(m.Text, "/") {
+		return
+	}
+
+	text, markup, messageID, err := continueConfigWizard(m.Chat.ID, wizardAnswer{Sender: senderID(m.Sender), Value: m.Text})
+	switch {
+	case err == errWizardInactive || err == errWizardOwner:
+		return
+	case err != nil:
+		sendTelegram(m.Chat.ID, "Wrong input! "+err.Error())
+		return
+	}
+	if markup != nil {
+		editTelegram(m.Chat.ID, messageID, text, markup)
+	} else {
+		editTelegram(m.Chat.ID, messageID, text, &telebot.ReplyMarkup{})
+	}
+}
@@ -0,0 +1,111 @@
+package bbtmvbot
+
+import (
+	"bbtmvbot/database"
+	"reflect"
+	"testing"
+)
+
+func TestConfigWizardAnswer(t *testing.T) {
+	var data = []struct {
+		Answers  []string
+		Step     int
+		Done     bool
+		Err      string
+		Expected database.Filter
+	}{
+		{[]string{"200"}, 1, false, "", database.Filter{PriceFrom: 200}},
+		{[]string{" 200 ", "400"}, 2, false, "", database.Filter{PriceFrom: 200, PriceTo: 400}},
+		{[]string{"abc"}, 0, false, "price_from must be a number", database.Filter{}},
+		{[]string{"-1"}, 0, false, "price_from must be between 0 and 100000", database.Filter{}},
+		{[]string{"400", "300"}, 1, false, "price_to must be ≥ price_from", database.Filter{PriceFrom: 400}},
+		{[]string{"200", "400", "2", "1"}, 3, false, "rooms_to must be ≥ rooms_from", database.Filter{PriceFrom: 200, PriceTo: 400, RoomsFrom: 2}},
+		{[]string{"200", "400", "1", "2", "1800"}, 4, false, "year_from must be between 1900 and ", database.Filter{PriceFrom: 200, PriceTo: 400, RoomsFrom: 1, RoomsTo: 2}},
+		{[]string{"200", "400", "1", "2", "0", "2", "maybe"}, 6, false, "show_with_fee must be yes or no", database.Filter{PriceFrom: 200, PriceTo: 400, RoomsFrom: 1, RoomsTo: 2, MinFloor: 2}},
+		{[]string{"200", "400", "1", "2", "2000", "2", "Yes"}, 7, true, "", database.Filter{PriceFrom: 200, PriceTo: 400, RoomsFrom: 1, RoomsTo: 2, YearFrom: 2000, MinFloor: 2, ShowWithFees: true}},
+	}
+
+	for i, v := range data {
+		w := &configWizard{}
+		var done bool
+		var err error
+		for _, a := range v.Answers {
+			if done, err = w.answer(a); err != nil {
+				break
+			}
+		}
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		// Error of year_from contains the current year
+		if len(got) > len(v.Err) {
+			got = got[:len(v.Err)]
+		}
+		if w.step != v.Step || done != v.Done || got != v.Err || !reflect.DeepEqual(w.filter, v.Expected) {
+			t.Errorf("Result of case %d is incorrect, got: '%d %t %s %+v', want: '%d %t %s %+v'.", i, w.step, done, got, w.filter, v.Step, v.Done, v.Err, v.Expected)
+		}
+	}
+}
+
+func TestConfigWizardCallbackValue(t *testing.T) {
+	var data = []struct {
+		Step     int
+		Data     string
+		Expected string
+		Current  bool
+	}{
+		{0, "price_from|200", "200", true},
+		{1, "price_to|0", "0", true},
+		{6, "show_with_fee|yes", "yes", true},
+		{1, "price_from|200", "", false}, // Button of the previous question
+		{0, "price_from", "", false},
+		{0, "", "", false},
+		{len(wizardSteps), "show_with_fee|yes", "", false},
+	}
+
+	for i, v := range data {
+		w := &configWizard{step: v.Step}
+		got, current := w.callbackValue(v.Data)
+		if got != v.Expected || current != v.Current {
+			t.Errorf("Result of case %d is incorrect, got: '%s %t', want: '%s %t'.", i, got, current, v.Expected, v.Current)
+		}
+	}
+}
+
+func TestContinueConfigWizard(t *testing.T) {
+	wizards.Lock()
+	wizards.chats[-1] = &configWizard{ownerID: 10, messageID: 5}
+	wizards.Unlock()
+	t.Cleanup(func() {
+		wizards.Lock()
+		delete(wizards.chats, -1)
+		wizards.Unlock()
+	})
+
+	var data = []struct {
+		Answer    wizardAnswer
+		Expected  string
+		MessageID int
+		Err       error
+		Markup    bool
+	}{
+		{wizardAnswer{Sender: 11, Value: "200"}, "", 0, errWizardOwner, false}, // Other member of the group
+		{wizardAnswer{Value: "200"}, "", 0, errWizardOwner, false},
+		{wizardAnswer{Sender: 10, Value: "abc"}, "", 0, &database.FieldError{Field: "price_from", Message: "must be a number"}, false},
+		{wizardAnswer{Sender: 10, Value: "200"}, "*Config 2/7* What is the *maximum price* in €?\n\nPress a button or type a value.", 5, nil, true},
+		{wizardAnswer{Sender: 10, Value: "price_from|300", Button: true}, "", 0, errQuestionOutdated, false},
+		{wizardAnswer{Sender: 10, Value: "price_to", Button: true}, "", 0, errQuestionOutdated, false},
+		{wizardAnswer{Sender: 10, Value: "price_to|400", Button: true}, "*Config 3/7* What is the *minimum number of rooms*?\n\nPress a button or type a value.", 5, nil, true},
+		{wizardAnswer{Sender: 11, Value: "cancel", Button: true}, "", 0, errWizardOwner, false},
+		{wizardAnswer{Sender: 10, Value: "cancel", Button: true}, "Config was not changed.", 5, nil, false},
+		{wizardAnswer{Sender: 10, Value: "400"}, "", 0, errWizardInactive, false},
+	}
+
+	for i, v := range data {
+		text, markup, messageID, err := continueConfigWizard(-1, v.Answer)
+		if text != v.Expected || messageID != v.MessageID || !reflect.DeepEqual(err, v.Err) || (markup != nil) != v.Markup {
+			t.Errorf("Result of case %d is incorrect, got: '%s %d %v %t', want: '%s %d %v %t'.", i, text, messageID, err, markup != nil, v.Expected, v.MessageID, v.Err, v.Markup)
+		}
+	}
+}